@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/paulbuckley/mdmu/internal/annotate"
+	"github.com/paulbuckley/mdmu/internal/config"
+	"github.com/paulbuckley/mdmu/internal/output"
+	"github.com/paulbuckley/mdmu/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export the comments on a file inline or for editors",
+	Long: "Write a copy of the markdown file with its saved comments woven into the text " +
+		"as CriticMarkup or HTML comments, for handing back an annotated document, or " +
+		"write the comments as vim quickfix lines, LSP diagnostics or SARIF for editors.",
+	Args: cobra.ExactArgs(1),
+	RunE: runExport,
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Save the inline comments in a file as comments",
+	Long: "Extract CriticMarkup and mdmu HTML comment annotations from the markdown file and " +
+		"save them as comments. The document without the annotations is written to stdout, " +
+		"or over the file with --in-place. With --to the comments are saved on that " +
+		"document instead, and nothing is written.",
+	Args: cobra.ExactArgs(1),
+	RunE: runImport,
+}
+
+var (
+	formatFlag       string
+	exportOutputFlag string
+	toFlag           string
+	inPlaceFlag      bool
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&formatFlag, "format", "critic",
+		"format: "+strings.Join(exportFormats(), ", "))
+	exportCmd.Flags().StringVarP(&exportOutputFlag, "output", "o", "-",
+		`file to write, or "-" for stdout`)
+	importCmd.Flags().StringVar(&toFlag, "to", "",
+		"document to save the comments on, leaving the annotated file untouched")
+	importCmd.Flags().BoolVar(&inPlaceFlag, "in-place", false,
+		"remove the annotations from the file, rather than writing the result to stdout")
+	rootCmd.AddCommand(exportCmd, importCmd)
+}
+
+// exportFormats lists the annotation styles and diagnostic formats.
+func exportFormats() []string {
+	return append(slices.Clone(annotate.Styles), output.DiagnosticFormats...)
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if !slices.Contains(exportFormats(), formatFlag) {
+		return fmt.Errorf("unknown export format %q (available: %s)", formatFlag, strings.Join(exportFormats(), ", "))
+	}
+	cmd.SilenceUsage = true
+
+	path, source, err := readSource(args[0])
+	if err != nil {
+		return err
+	}
+	storePath, persisted, err := persistedStore(path)
+	if err != nil {
+		return err
+	}
+	if !persisted {
+		fmt.Fprintln(os.Stderr, "mdmu: persistence is disabled, so only comments saved while it was enabled are exported")
+	}
+	cf, err := store.Load(storePath)
+	if err != nil {
+		return err
+	}
+
+	if style, err := annotate.ParseStyle(formatFlag); err == nil {
+		return writeOutput(exportOutputFlag, string(annotate.Export(source, cf, style)))
+	}
+	// Diagnostics name the file as given, so editors resolve it like the user
+	out, err := output.Diagnostics(formatFlag, cf, source, args[0])
+	if err != nil {
+		return err
+	}
+	return writeOutput(exportOutputFlag, out)
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	path, source, err := readSource(args[0])
+	if err != nil {
+		return err
+	}
+	doc, imported := annotate.Import(source)
+	target := path
+	if toFlag != "" {
+		if target, err = filepath.Abs(toFlag); err != nil {
+			return fmt.Errorf("resolving path: %w", err)
+		}
+	}
+
+	added := 0
+	if len(imported.Comments) > 0 {
+		storePath, persisted, err := persistedStore(target)
+		if err != nil {
+			return err
+		}
+		if !persisted {
+			fmt.Fprintln(os.Stderr, "mdmu: persistence is disabled, so the TUI won't load these comments; "+
+				"enable persistence in the config or run mdmu with --persist")
+		}
+		_, err = store.Update(storePath, func(cf *store.CommentFile) error {
+			// Importing the same annotations again adds nothing
+			for _, c := range imported.Comments {
+				if !slices.ContainsFunc(cf.Comments, func(saved store.Comment) bool { return sameComment(saved, c) }) {
+					cf.Comments = append(cf.Comments, c)
+					added++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case toFlag != "":
+	case inPlaceFlag:
+		if len(imported.Comments) > 0 {
+			if err := replaceFile(path, doc); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := writeOutput("-", string(doc)); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case len(imported.Comments) == 0:
+		fmt.Fprintf(os.Stderr, "No annotations in %s\n", filepath.Base(path))
+	case added < len(imported.Comments):
+		fmt.Fprintf(os.Stderr, "Imported %d of %d comments on %s; %d were already saved\n",
+			added, len(imported.Comments), filepath.Base(target), len(imported.Comments)-added)
+	default:
+		noun := "comments"
+		if added == 1 {
+			noun = "comment"
+		}
+		fmt.Fprintf(os.Stderr, "Imported %d %s on %s\n", added, noun, filepath.Base(target))
+	}
+	return nil
+}
+
+// sameComment reports whether two comments are on the same text and say
+// the same thing.
+func sameComment(a, b store.Comment) bool {
+	return a.SourceStart == b.SourceStart && a.SourceEnd == b.SourceEnd &&
+		a.StartCol == b.StartCol && a.EndCol == b.EndCol && a.Comment == b.Comment
+}
+
+// replaceFile replaces the contents of the file at path atomically, through
+// a temporary file renamed over it, keeping its permissions.
+func replaceFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mdmu-*.md")
+	if err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing file: %w", err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing file: %w", err)
+	}
+	return nil
+}
+
+// persistedStore returns the comment file for a document, as configured for
+// its directory, and whether persistence is enabled there. The file is used
+// either way, since these commands exist to read or write it.
+func persistedStore(path string) (string, bool, error) {
+	if path == "" {
+		return "", false, errors.New("comments can only be saved for files, not stdin")
+	}
+	cfg, err := config.Load(config.UserPath(), config.FindProject(filepath.Dir(path)))
+	if err != nil {
+		return "", false, err
+	}
+	return store.PathFor(path, cfg.Persistence.Dir), cfg.Persistence.Enabled, nil
+}
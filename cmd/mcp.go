@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/paulbuckley/mdmu/internal/config"
+	"github.com/paulbuckley/mdmu/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp [file...]",
+	Short: "Serve review comments to coding agents over MCP",
+	Long: "Run a Model Context Protocol server on stdin and stdout that lets agents list, " +
+		"fetch, resolve and reply to the comments saved for markdown files. Files given " +
+		"as arguments are also offered as resources.",
+	RunE: runMCP,
+}
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	// The files given are checked up front; without any, the working
+	// directory stands in for the documents an agent will name.
+	dirs := []string{"."}
+	if len(args) > 0 {
+		dirs = nil
+		for _, file := range args {
+			dirs = append(dirs, filepath.Dir(file))
+		}
+	}
+	for _, dir := range dirs {
+		cfg, err := documentConfig(dir)
+		if err != nil {
+			return err
+		}
+		if !cfg.Persistence.Enabled {
+			// stdout carries the protocol, so notes go to stderr
+			fmt.Fprintln(os.Stderr, "mdmu: persistence is disabled, so comments made in the TUI won't reach this server; "+
+				"enable persistence in the config or run mdmu with --persist")
+			break
+		}
+	}
+
+	server := &mcp.Server{
+		Version:  rootCmd.Version,
+		Files:    args,
+		Settings: mcpSettings,
+	}
+	return server.Serve(os.Stdin, os.Stdout)
+}
+
+// documentConfig reads the user config and the project config that applies
+// to documents in dir.
+func documentConfig(dir string) (config.Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return config.Config{}, err
+	}
+	return config.Load(config.UserPath(), config.FindProject(dir))
+}
+
+// mcpSettings returns the settings configured for a document, as the TUI
+// would load them for it.
+func mcpSettings(doc string) (mcp.Settings, error) {
+	cfg, err := documentConfig(filepath.Dir(doc))
+	if err != nil {
+		return mcp.Settings{}, err
+	}
+	out, err := outputOptions(cfg.Output)
+	if err != nil {
+		return mcp.Settings{}, err
+	}
+	return mcp.Settings{StoreDir: cfg.Persistence.Dir, Output: out}, nil
+}
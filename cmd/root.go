@@ -1,23 +1,91 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+	"github.com/paulbuckley/mdmu/internal/clipboard"
+	"github.com/paulbuckley/mdmu/internal/config"
 	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/output"
 	"github.com/paulbuckley/mdmu/internal/store"
+	"github.com/paulbuckley/mdmu/internal/theme"
 	"github.com/paulbuckley/mdmu/internal/tui"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "mdmu <file>",
+	Use:   "mdmu <file | ->",
 	Short: "Annotate markdown files with comments",
 	Long:  "A terminal UI for navigating rendered markdown files and adding line-level comments.",
 	Args:  cobra.ExactArgs(1),
 	RunE:  runTUI,
+
+	// main reports errors, so that outcomes carried by ExitError stay quiet
+	SilenceErrors: true,
+}
+
+var (
+	configFlag    string
+	promptFlag    string
+	presetFlag    string
+	themeFlag     string
+	linksFlag     string
+	splitFlag     float64
+	panesFlag     string
+	clipboardFlag string
+	keymapFlag    string
+	persistFlag   bool
+	outputFlag    string
+	hookFlag      bool
+	quotesFlag    string
+	contextFlag   int
+	layoutFlag    string
+	maxTokensFlag int
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&configFlag, "config", "",
+		"user config file (default $XDG_CONFIG_HOME/mdmu/config.yaml)")
+	rootCmd.Flags().StringVar(&promptFlag, "prompt", "",
+		"template for the instruction opening the output (fields: .Filename, .Count, .Meta)")
+	rootCmd.Flags().StringVar(&presetFlag, "preset", "default",
+		"output preset: "+strings.Join(output.PresetNames(), ", "))
+	rootCmd.Flags().StringVar(&quotesFlag, "quotes", "full",
+		`quote the text comments target ("full"), or give line references only ("none")`)
+	rootCmd.Flags().StringVar(&layoutFlag, "layout", "flat",
+		`list comments in source order ("flat"), or group them by heading ("sections")`)
+	rootCmd.Flags().IntVar(&contextFlag, "context", 0,
+		"lines of surrounding source to quote before and after each comment")
+	rootCmd.Flags().IntVar(&maxTokensFlag, "max-tokens", 0,
+		"size budget for the output in estimated tokens, abbreviating quotes to fit (0 means none)")
+	rootCmd.Flags().StringVar(&themeFlag, "theme", "auto",
+		"color theme: auto, dark, light, high-contrast, no-color, or a user theme name or file")
+	rootCmd.Flags().StringVar(&linksFlag, "hyperlinks", "auto",
+		`show links as terminal hyperlinks ("on"), with their destination after them ("off"), or as the terminal supports ("auto")`)
+	rootCmd.Flags().Float64Var(&splitFlag, "split", 0.65,
+		"fraction of the width used by the markdown pane")
+	rootCmd.Flags().StringVar(&panesFlag, "panes", "auto",
+		`put the comments pane beside the markdown ("side"), below it ("stacked"), or below it on narrow terminals ("auto")`)
+	rootCmd.Flags().StringVar(&clipboardFlag, "clipboard", "auto",
+		"clipboard backend: "+strings.Join(clipboard.Backends, ", "))
+	rootCmd.Flags().StringVar(&keymapFlag, "keymap", "default",
+		"key bindings: "+strings.Join(tui.Keymaps, ", "))
+	rootCmd.Flags().BoolVar(&persistFlag, "persist", false,
+		"save comments between sessions")
+	rootCmd.Flags().StringVarP(&outputFlag, "output", "o", "",
+		`write the formatted review to this file, or "-" for stdout, on exit`)
+	rootCmd.Flags().BoolVar(&hookFlag, "hook", false,
+		"blocking review for scripts: write the review only when submitted and exit 0 (submitted), 2 (approved) or 3 (aborted)")
 }
 
 func SetVersion(v string) {
@@ -29,22 +97,36 @@ func Execute() error {
 }
 
 func runTUI(cmd *cobra.Command, args []string) error {
-	filePath := args[0]
+	// Errors past this point are about the input, not the command line
+	cmd.SilenceUsage = true
 
-	// Resolve to absolute path
-	if !filepath.IsAbs(filePath) {
-		wd, err := os.Getwd()
-		if err != nil {
+	// Read the document from the file, or from stdin for "-"
+	filePath, source, err := readSource(args[0])
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(filePath)
+	dir := filepath.Dir(filePath)
+	if filePath == "" {
+		name = "stdin"
+		if dir, err = os.Getwd(); err != nil {
 			return fmt.Errorf("getting working directory: %w", err)
 		}
-		filePath = filepath.Join(wd, filePath)
 	}
 
-	// Read the source file
-	source, err := os.ReadFile(filePath)
+	cfg, err := loadConfig(cmd, dir)
+	if err != nil {
+		return err
+	}
+	opts, err := resolveConfig(cfg)
 	if err != nil {
-		return fmt.Errorf("reading file: %w", err)
+		return err
 	}
+	opts.Submits = hookFlag || outputFlag != ""
+	opts.Open = reviewCommand(cmd)
+	opts.Path = filePath
+	opts.Hyperlinks = cfg.Hyperlinks == "on" ||
+		cfg.Hyperlinks == "auto" && markdown.SupportsHyperlinks(os.Getenv)
 
 	// Parse and render the markdown
 	doc, err := markdown.ParseAndRender(source, 80)
@@ -52,17 +134,283 @@ func runTUI(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("parsing markdown: %w", err)
 	}
 
-	// Create empty in-memory comment store
+	// Comments live in memory unless persistence is enabled for a file
 	cf := &store.CommentFile{}
+	if cfg.Persistence.Enabled && filePath != "" {
+		opts.StorePath = store.PathFor(filePath, cfg.Persistence.Dir)
+		if cf, err = store.Load(opts.StorePath); err != nil {
+			return err
+		}
+	}
 
 	// Initialize the TUI model
-	model := tui.NewModel(doc, cf, source, filepath.Base(filePath))
+	model := tui.NewModel(doc, cf, source, name, opts)
+
+	// Keep the interface on the terminal when stdin or stdout is redirected
+	progOpts := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	if !isatty.IsTerminal(os.Stdin.Fd()) {
+		progOpts = append(progOpts, tea.WithInputTTY())
+	}
+	if !isatty.IsTerminal(os.Stdout.Fd()) {
+		tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+		if err != nil {
+			return fmt.Errorf("opening terminal: %w", err)
+		}
+		defer tty.Close()
+		progOpts = append(progOpts, tea.WithOutput(tty))
+	}
 
 	// Run Bubble Tea
-	p := tea.NewProgram(model, tea.WithAltScreen(), tea.WithMouseCellMotion())
-	if _, err := p.Run(); err != nil {
+	p := tea.NewProgram(model, progOpts...)
+	final, err := p.Run()
+	if err != nil {
 		return fmt.Errorf("running TUI: %w", err)
 	}
 
+	m := final.(tui.Model)
+	// Only what keys changed is remembered, not flags meant for this session
+	if l, changed := m.Layout(); changed != (tui.LayoutChanges{}) {
+		var state config.LayoutState
+		if changed.Split {
+			state.Split = &l.Split
+		}
+		if changed.Panes {
+			state.Panes = &l.Panes
+		}
+		if changed.HideComments {
+			state.HideComments = &l.HideComments
+		}
+		if err := config.SaveLayout(config.LayoutPath(), state); err != nil {
+			fmt.Fprintln(os.Stderr, "mdmu:", err)
+		}
+	}
+	if hookFlag {
+		return hookOutcome(m)
+	}
+	if outputFlag != "" {
+		return writeOutput(outputFlag, m.Output())
+	}
 	return nil
 }
+
+// reviewCommand returns a function making the command that reviews a
+// linked file in a nested session. It passes on the flags this session was
+// started with, except those about where this session's review goes.
+func reviewCommand(cmd *cobra.Command) func(path string) *exec.Cmd {
+	var args []string
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if f.Name != "output" && f.Name != "hook" {
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+	return func(path string) *exec.Cmd {
+		self, err := os.Executable()
+		if err != nil {
+			self = os.Args[0]
+		}
+		return exec.Command(self, append(args, path)...)
+	}
+}
+
+// Exit statuses in --hook mode. Errors exit with ExitFailure.
+const (
+	ExitSubmitted = 0
+	ExitFailure   = 1
+	ExitApproved  = 2
+	ExitAborted   = 3
+)
+
+// ExitError ends the process with a status describing the outcome of a
+// review rather than a failure.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// hookOutcome writes a submitted review and reports how the session ended
+// through the exit status. Reviews go to stdout unless --output is set.
+func hookOutcome(m tui.Model) error {
+	switch m.Result() {
+	case tui.ResultSubmitted:
+		path := outputFlag
+		if path == "" {
+			path = "-"
+		}
+		return writeOutput(path, m.Output())
+	case tui.ResultApproved:
+		return &ExitError{Code: ExitApproved}
+	default:
+		return &ExitError{Code: ExitAborted}
+	}
+}
+
+// readSource reads the document at path, or stdin when path is "-". It
+// returns the absolute path of the file, or "" for stdin.
+func readSource(path string) (string, []byte, error) {
+	if path == "-" {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return "", nil, fmt.Errorf("reading stdin: %w", err)
+		}
+		return "", source, nil
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolving path: %w", err)
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading file: %w", err)
+	}
+	return path, source, nil
+}
+
+// writeOutput writes the formatted review to path, or to stdout for "-".
+// Nothing is written when there are no comments.
+func writeOutput(path, content string) error {
+	if content == "" {
+		return nil
+	}
+	if path == "-" {
+		_, err := io.WriteString(os.Stdout, content)
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+	return nil
+}
+
+// loadConfig reads the user and project config files and applies the flags
+// set on the command line. Flags take precedence over the project file
+// (.mdmu.yaml in the document's directory or a parent), which takes
+// precedence over the user file, which takes precedence over the pane
+// layout last chosen in the TUI.
+func loadConfig(cmd *cobra.Command, dir string) (config.Config, error) {
+	userPath := config.UserPath()
+	if configFlag != "" {
+		if _, err := os.Stat(configFlag); err != nil {
+			return config.Config{}, fmt.Errorf("reading config: %w", err)
+		}
+		userPath = configFlag
+	}
+
+	cfg, err := config.Load(config.LayoutPath(), userPath, config.FindProject(dir))
+	if err != nil {
+		return cfg, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("theme") {
+		cfg.Theme = themeFlag
+	}
+	if flags.Changed("hyperlinks") {
+		cfg.Hyperlinks = linksFlag
+	}
+	if flags.Changed("preset") {
+		cfg.Output.Preset = presetFlag
+	}
+	if flags.Changed("prompt") {
+		cfg.Output.Prompt = promptFlag
+	}
+	if flags.Changed("quotes") {
+		cfg.Output.Quotes = quotesFlag
+	}
+	if flags.Changed("layout") {
+		cfg.Output.Layout = layoutFlag
+	}
+	if flags.Changed("context") {
+		cfg.Output.Context = contextFlag
+	}
+	if flags.Changed("max-tokens") {
+		cfg.Output.MaxTokens = maxTokensFlag
+	}
+	if flags.Changed("split") {
+		cfg.Layout.Split = splitFlag
+	}
+	if flags.Changed("panes") {
+		cfg.Layout.Panes = panesFlag
+	}
+	if flags.Changed("clipboard") {
+		cfg.Clipboard.Backend = clipboardFlag
+	}
+	if flags.Changed("keymap") {
+		cfg.Keymap = keymapFlag
+	}
+	if flags.Changed("persist") {
+		cfg.Persistence.Enabled = persistFlag
+	}
+	return cfg, nil
+}
+
+// outputOptions returns the output options a configuration selects.
+func outputOptions(cfg config.Output) (output.Options, error) {
+	out, err := output.Preset(cfg.Preset)
+	errs := []error{err}
+	if cfg.Prompt != "" {
+		out.Prompt = cfg.Prompt
+	}
+	if _, err := output.ParsePrompt(out.Prompt); err != nil {
+		errs = append(errs, fmt.Errorf("invalid prompt template: %w", err))
+	}
+	if !slices.Contains(output.QuoteStyles, cfg.Quotes) {
+		errs = append(errs, fmt.Errorf("unknown quote style %q (available: %s)",
+			cfg.Quotes, strings.Join(output.QuoteStyles, ", ")))
+	}
+	out.Quotes = cfg.Quotes
+	if !slices.Contains(output.Layouts, cfg.Layout) {
+		errs = append(errs, fmt.Errorf("unknown output layout %q (available: %s)",
+			cfg.Layout, strings.Join(output.Layouts, ", ")))
+	}
+	out.Layout = cfg.Layout
+	out.Untouched = cfg.Untouched
+	out.Context = cfg.Context
+	out.MaxChars = cfg.MaxChars
+	out.MaxTokens = cfg.MaxTokens
+	return out, errors.Join(errs...)
+}
+
+// resolveConfig turns a configuration into TUI options, reporting every
+// invalid setting together.
+func resolveConfig(cfg config.Config) (tui.Options, error) {
+	errs := []error{cfg.Validate()}
+
+	out, err := outputOptions(cfg.Output)
+	errs = append(errs, err)
+
+	cb, err := clipboard.New(cfg.Clipboard.Backend, cfg.Clipboard.Command)
+	errs = append(errs, err)
+
+	keys, err := tui.NewKeyMap(cfg.Keymap, cfg.Keys)
+	errs = append(errs, err)
+
+	if !slices.Contains(tui.PaneLayouts, cfg.Layout.Panes) {
+		errs = append(errs, fmt.Errorf("unknown pane layout %q (available: %s)",
+			cfg.Layout.Panes, strings.Join(tui.PaneLayouts, ", ")))
+	}
+
+	t, err := theme.Resolve(cfg.Theme)
+	errs = append(errs, err)
+
+	if err := errors.Join(errs...); err != nil {
+		return tui.Options{}, fmt.Errorf("invalid configuration:\n  %s",
+			strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+
+	return tui.Options{
+		Output:        out,
+		Clipboard:     cb,
+		CommentHeight: cfg.Layout.CommentHeight,
+		KeyMap:        &keys,
+		Theme:         t,
+		Layout: tui.Layout{
+			Split:        cfg.Layout.Split,
+			Panes:        cfg.Layout.Panes,
+			HideComments: cfg.Layout.HideComments,
+		},
+	}, nil
+}
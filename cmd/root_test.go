@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/store"
+	"github.com/paulbuckley/mdmu/internal/tui"
+)
+
+// redirect replaces *f, such as os.Stdin, with a file holding content for
+// the rest of the test, and returns the file.
+func redirect(t *testing.T, f **os.File, content string) *os.File {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stream")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := *f
+	*f = file
+	t.Cleanup(func() {
+		*f = old
+		file.Close()
+	})
+	return file
+}
+
+// written returns what has been written to a file from redirect.
+func written(t *testing.T, f *os.File) string {
+	t.Helper()
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReadSource(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("plan.md", []byte("# From a file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	redirect(t, &os.Stdin, "# From stdin\n")
+
+	tests := []struct {
+		name       string
+		arg        string
+		wantPath   string
+		wantSource string
+		wantErr    string
+	}{
+		{"stdin", "-", "", "# From stdin\n", ""},
+		{"relative path", "plan.md", filepath.Join(dir, "plan.md"), "# From a file\n", ""},
+		{"missing file", "missing.md", "", "", "reading file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, source, err := readSource(tt.arg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readSource(%q) error = %v, want %q", tt.arg, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readSource(%q) failed: %v", tt.arg, err)
+			}
+			if path != tt.wantPath || string(source) != tt.wantSource {
+				t.Errorf("readSource(%q) = %q, %q, want %q, %q", tt.arg, path, source, tt.wantPath, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestWriteOutput(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		path       string
+		content    string
+		wantStdout string
+		wantFile   bool
+		wantErr    bool
+	}{
+		{"stdout", "-", "review\n", "review\n", false, false},
+		{"file", filepath.Join(dir, "review.md"), "review\n", "", true, false},
+		{"nothing to write", filepath.Join(dir, "empty.md"), "", "", false, false},
+		{"missing directory", filepath.Join(dir, "missing", "review.md"), "review\n", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := redirect(t, &os.Stdout, "")
+			err := writeOutput(tt.path, tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeOutput(%q) error = %v, want error %v", tt.path, err, tt.wantErr)
+			}
+			if got := written(t, stdout); got != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", got, tt.wantStdout)
+			}
+			data, err := os.ReadFile(tt.path)
+			switch {
+			case tt.wantFile && string(data) != tt.content:
+				t.Errorf("file = %q (%v), want %q", data, err, tt.content)
+			case !tt.wantFile && tt.path != "-" && err == nil:
+				t.Errorf("%s should not have been written", tt.path)
+			}
+		})
+	}
+}
+
+// endedModel returns a hook session on a one-line document that ended
+// after keys.
+func endedModel(comments []store.Comment, keys string) tui.Model {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	m := tui.NewModel(doc, &store.CommentFile{Comments: comments}, []byte("one\n"), "plan.md", tui.Options{Submits: true})
+	var model tea.Model = m
+	model, _ = model.Update(tea.WindowSizeMsg{Width: 100, Height: 20})
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)})
+	return model.(tui.Model)
+}
+
+func TestHookOutcome(t *testing.T) {
+	comment := []store.Comment{{ID: "1", SourceStart: 1, SourceEnd: 1, SelectedText: "one", Comment: "fix"}}
+	file := filepath.Join(t.TempDir(), "review.md")
+	tests := []struct {
+		name       string
+		model      tui.Model
+		output     string // the --output flag
+		wantCode   int    // exit status, ExitSubmitted for no error
+		wantStdout bool
+	}{
+		{"submitted", endedModel(comment, "S"), "", ExitSubmitted, true},
+		{"submitted to a file", endedModel(comment, "S"), file, ExitSubmitted, false},
+		{"approved", endedModel(nil, "A"), "", ExitApproved, false},
+		{"aborted", endedModel(comment, "q"), "", ExitAborted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := redirect(t, &os.Stdout, "")
+			outputFlag = tt.output
+			t.Cleanup(func() { outputFlag = "" })
+
+			err := hookOutcome(tt.model)
+			code := ExitSubmitted
+			var exit *ExitError
+			if errors.As(err, &exit) {
+				code = exit.Code
+			} else if err != nil {
+				t.Fatalf("hookOutcome failed: %v", err)
+			}
+			if code != tt.wantCode {
+				t.Errorf("exit status = %d, want %d", code, tt.wantCode)
+			}
+
+			review := "**Comment:** fix"
+			if got := written(t, stdout); strings.Contains(got, review) != tt.wantStdout {
+				t.Errorf("stdout = %q, want the review there: %v", got, tt.wantStdout)
+			}
+			if tt.output != "" {
+				if data, _ := os.ReadFile(tt.output); !strings.Contains(string(data), review) {
+					t.Errorf("--output file = %q, want the review", data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/paulbuckley/mdmu/internal/config"
+	"github.com/paulbuckley/mdmu/internal/server"
+	"github.com/paulbuckley/mdmu/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve <file>",
+	Short: "Review a markdown file in the browser",
+	Long: "Serve the markdown file as a web page for selecting lines and adding comments. " +
+		"Comments are saved to the persisted comment file, so a TUI session on the same " +
+		"file (with persistence enabled) shares them.",
+	Args: cobra.ExactArgs(1),
+	RunE: runServe,
+}
+
+var addrFlag string
+
+func init() {
+	serveCmd.Flags().StringVar(&addrFlag, "addr", "localhost:4040", "address to listen on")
+	rootCmd.AddCommand(serveCmd)
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		return fmt.Errorf("resolving path: %w", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+
+	cfg, err := config.Load(config.UserPath(), config.FindProject(filepath.Dir(path)))
+	if err != nil {
+		return err
+	}
+	out, err := outputOptions(cfg.Output)
+	if err != nil {
+		return err
+	}
+	if !cfg.Persistence.Enabled {
+		fmt.Fprintln(os.Stderr, "mdmu: persistence is disabled, so comments made here won't reach the TUI; "+
+			"enable persistence in the config or run mdmu with --persist")
+	}
+
+	s := &server.Server{
+		Path:      path,
+		StorePath: store.PathFor(path, cfg.Persistence.Dir),
+		Output:    out,
+	}
+
+	// Listen first, so the address printed is one that works
+	ln, err := net.Listen("tcp", addrFlag)
+	if err != nil {
+		return err
+	}
+	s.Addr = ln.Addr().String()
+	fmt.Fprintf(os.Stderr, "Reviewing %s at http://%s/\n", filepath.Base(path), ln.Addr())
+	if err := http.Serve(ln, s.Handler()); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+// Package annotate weaves comments into a copy of a markdown document as
+// inline annotations, and extracts them again.
+//
+// Each open comment marks the text it refers to and follows it with its
+// body. With CriticMarkup:
+//
+//	The {==quick brown==}{>>Why brown?<<} fox.
+//
+// With HTML comments, which stay invisible when the document is rendered:
+//
+//	The <!-- mdmu -->quick brown<!-- mdmu: Why brown? --> fox.
+//
+// Comments on whole lines mark the content of the lines, after any heading,
+// list or blockquote marker, so the document's structure is kept.
+// Overlapping comments share one mark.
+package annotate
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// Style is a syntax for annotations.
+type Style string
+
+const (
+	CriticMarkup Style = "critic"
+	HTMLComments Style = "html"
+)
+
+// Styles lists the annotation styles.
+var Styles = []string{string(CriticMarkup), string(HTMLComments)}
+
+// ParseStyle returns the style with the given name.
+func ParseStyle(name string) (Style, error) {
+	switch s := Style(name); s {
+	case CriticMarkup, HTMLComments:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown annotation style %q (available: %s)", name, strings.Join(Styles, ", "))
+}
+
+type syntax struct {
+	markOpen, markClose     string
+	commentOpen, commentEnd string
+	escapes                 *strings.Replacer // keeps bodies from closing their comment
+	unescapes               *strings.Replacer
+}
+
+var syntaxes = map[Style]syntax{
+	CriticMarkup: {
+		markOpen: "{==", markClose: "==}",
+		commentOpen: "{>>", commentEnd: "<<}",
+		escapes:   strings.NewReplacer("&", "&amp;", "<<}", "&lt;&lt;}"),
+		unescapes: strings.NewReplacer("&lt;&lt;}", "<<}", "&amp;", "&"),
+	},
+	HTMLComments: {
+		markOpen: "<!-- mdmu -->", markClose: "",
+		commentOpen: "<!-- mdmu: ", commentEnd: " -->",
+		escapes:   strings.NewReplacer("&", "&amp;", "-->", "--&gt;"),
+		unescapes: strings.NewReplacer("--&gt;", "-->", "&amp;", "&"),
+	},
+}
+
+// span is a range of source bytes with the comments that mark it.
+type span struct {
+	start, end int // end is exclusive; start == end for a comment without a mark
+	comments   []string
+}
+
+// Export returns source with the open comments in cf written into it.
+func Export(source []byte, cf *store.CommentFile, style Style) []byte {
+	syn := syntaxes[style]
+	lines := lineOffsets(source)
+
+	var spans []span
+	for _, c := range cf.Comments {
+		if c.Resolved() {
+			continue
+		}
+		start, end := commentSpan(source, lines, c)
+		spans = append(spans, span{start: start, end: end, comments: []string{c.Comment}})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	// Merge overlapping spans, since marks can't nest
+	var merged []span
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start < merged[n-1].end {
+			last := &merged[n-1]
+			last.end = max(last.end, s.end)
+			last.comments = append(last.comments, s.comments...)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	var out bytes.Buffer
+	pos := 0
+	for _, s := range merged {
+		out.Write(source[pos:s.start])
+		if s.start < s.end {
+			out.WriteString(syn.markOpen)
+			out.Write(source[s.start:s.end])
+			out.WriteString(syn.markClose)
+		}
+		for _, body := range s.comments {
+			out.WriteString(syn.commentOpen + syn.escapes.Replace(body) + syn.commentEnd)
+		}
+		pos = s.end
+	}
+	out.Write(source[pos:])
+	return out.Bytes()
+}
+
+// commentSpan returns the source bytes a comment marks: its columns, or the
+// content of its lines. Blank lines at either end are left out, and a
+// comment on blank lines only gets no mark, at the end of its first line.
+func commentSpan(source []byte, lines []int, c store.Comment) (int, int) {
+	first := clamp(c.SourceStart, 1, len(lines))
+	last := clamp(c.SourceEnd, first, len(lines))
+	if c.HasColumns() {
+		start := min(lines[first-1]+c.StartCol-1, len(source))
+		end := min(lines[last-1]+c.EndCol, len(source))
+		return start, max(start, end)
+	}
+
+	for first < last && isBlank(lineContent(source, lines, first)) {
+		first++
+	}
+	for last > first && isBlank(lineContent(source, lines, last)) {
+		last--
+	}
+	start := lines[first-1] + contentStart(lineContent(source, lines, first))
+	end := lines[last-1] + len(lineContent(source, lines, last))
+	return start, max(start, end)
+}
+
+// blockMarker matches the indentation and block markers before the content
+// of a line.
+var blockMarker = regexp.MustCompile(`^[ \t]*(?:>[ \t]?)*[ \t]*(?:#{1,6}[ \t]+|[-*+][ \t]+(?:\[[ xX]\][ \t]+)?|\d{1,9}[.)][ \t]+)?`)
+
+func contentStart(line string) int {
+	return len(blockMarker.FindString(line))
+}
+
+func isBlank(line string) bool {
+	return contentStart(line) == len(line)
+}
+
+// lineOffsets returns the byte offset at which each line starts.
+func lineOffsets(source []byte) []int {
+	offsets := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return offsets
+}
+
+// lineContent returns 1-indexed line n without its line ending.
+func lineContent(source []byte, lines []int, n int) string {
+	end := len(source)
+	if n < len(lines) {
+		end = lines[n] - 1
+	}
+	return strings.TrimSuffix(string(source[lines[n-1]:end]), "\r")
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+// annotation matches an annotation in either style: a mark with its
+// comments, or comments alone.
+var annotation = regexp.MustCompile(`(?s)` +
+	`\{==(.*?)==\}((?:\{>>.*?<<\})*)|((?:\{>>.*?<<\})+)|` +
+	`<!-- mdmu -->(.*?)((?:<!-- mdmu: .*? -->)+)|` +
+	`((?:<!-- mdmu: .*? -->)+)`)
+
+var (
+	criticComment = regexp.MustCompile(`(?s)\{>>(.*?)<<\}`)
+	htmlComment   = regexp.MustCompile(`(?s)<!-- mdmu: (.*?) -->`)
+)
+
+// Import removes the annotations in either style from source. It returns
+// the document without them and the comments they held, positioned in that
+// document.
+func Import(source []byte) ([]byte, *store.CommentFile) {
+	var clean bytes.Buffer
+	var spans []span
+	pos := 0
+	for _, m := range annotation.FindAllSubmatchIndex(source, -1) {
+		clean.Write(source[pos:m[0]])
+		pos = m[1]
+
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return string(source[m[2*i]:m[2*i+1]])
+		}
+
+		s := span{start: clean.Len()}
+		switch {
+		case m[2] >= 0: // CriticMarkup mark
+			clean.WriteString(group(1))
+			s.comments = bodies(criticComment, syntaxes[CriticMarkup], group(2))
+		case m[6] >= 0: // CriticMarkup comments alone
+			s.comments = bodies(criticComment, syntaxes[CriticMarkup], group(3))
+		case m[8] >= 0: // HTML mark
+			clean.WriteString(group(4))
+			s.comments = bodies(htmlComment, syntaxes[HTMLComments], group(5))
+		default: // HTML comments alone
+			s.comments = bodies(htmlComment, syntaxes[HTMLComments], group(6))
+		}
+		s.end = clean.Len()
+		spans = append(spans, s)
+	}
+	clean.Write(source[pos:])
+
+	doc := clean.Bytes()
+	lines := lineOffsets(doc)
+	cf := &store.CommentFile{}
+	for _, s := range spans {
+		c := spanComment(doc, lines, s.start, s.end)
+		for _, body := range s.comments {
+			c.ID = uuid.New().String()
+			c.Comment = body
+			c.CreatedAt = time.Now()
+			cf.Comments = append(cf.Comments, c)
+		}
+	}
+	return doc, cf
+}
+
+func bodies(re *regexp.Regexp, syn syntax, s string) []string {
+	var out []string
+	for _, m := range re.FindAllStringSubmatch(s, -1) {
+		out = append(out, syn.unescapes.Replace(m[1]))
+	}
+	return out
+}
+
+// spanComment returns a comment on the marked bytes start to end: a comment
+// on whole lines when they cover the content of their lines, as Export
+// writes them, or on columns otherwise.
+func spanComment(doc []byte, lines []int, start, end int) store.Comment {
+	first, startCol := markdown.Position(doc, start)
+	if start == end {
+		return store.Comment{SourceStart: first, SourceEnd: first, SelectedText: lineContent(doc, lines, first)}
+	}
+	last, endCol := markdown.Position(doc, end-1)
+
+	if startCol-1 == contentStart(lineContent(doc, lines, first)) && endCol == len(lineContent(doc, lines, last)) {
+		var selected []string
+		for n := first; n <= last; n++ {
+			selected = append(selected, lineContent(doc, lines, n))
+		}
+		return store.Comment{SourceStart: first, SourceEnd: last, SelectedText: strings.Join(selected, "\n")}
+	}
+	return store.Comment{
+		SourceStart:  first,
+		SourceEnd:    last,
+		StartCol:     startCol,
+		EndCol:       endCol,
+		SelectedText: string(doc[start:end]),
+	}
+}
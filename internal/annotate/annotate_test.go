@@ -0,0 +1,81 @@
+package annotate
+
+import (
+	"testing"
+
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+const source = "# Plan\n\n- Use sessions\n- Store tokens\n\nThe quick brown fox.\n"
+
+var comments = &store.CommentFile{Comments: []store.Comment{
+	{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "Rename this"},
+	{ID: "2", SourceStart: 3, SourceEnd: 4, Comment: "Which one?"},
+	{ID: "3", SourceStart: 6, SourceEnd: 6, StartCol: 5, EndCol: 15, Comment: "Why brown? --> {>> <<} & more"},
+	{ID: "4", SourceStart: 6, SourceEnd: 6, Comment: "Done", Status: store.StatusResolved},
+}}
+
+func TestExport(t *testing.T) {
+	tests := []struct {
+		style Style
+		want  string
+	}{
+		{CriticMarkup, "# {==Plan==}{>>Rename this<<}\n\n- {==Use sessions\n- Store tokens==}{>>Which one?<<}\n\n" +
+			"The {==quick brown==}{>>Why brown? --> {>> &lt;&lt;} &amp; more<<} fox.\n"},
+		{HTMLComments, "# <!-- mdmu -->Plan<!-- mdmu: Rename this -->\n\n" +
+			"- <!-- mdmu -->Use sessions\n- Store tokens<!-- mdmu: Which one? -->\n\n" +
+			"The <!-- mdmu -->quick brown<!-- mdmu: Why brown? --&gt; {>> <<} &amp; more --> fox.\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			if got := string(Export([]byte(source), comments, tt.style)); got != tt.want {
+				t.Errorf("Export() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	for _, style := range []Style{CriticMarkup, HTMLComments} {
+		t.Run(string(style), func(t *testing.T) {
+			doc, cf := Import(Export([]byte(source), comments, style))
+			if string(doc) != source {
+				t.Errorf("document = %q, want the original", doc)
+			}
+			if len(cf.Comments) != 3 {
+				t.Fatalf("got %d comments, want the 3 open ones", len(cf.Comments))
+			}
+			for i, want := range comments.Comments[:3] {
+				got := cf.Comments[i]
+				if got.SourceStart != want.SourceStart || got.SourceEnd != want.SourceEnd ||
+					got.StartCol != want.StartCol || got.EndCol != want.EndCol || got.Comment != want.Comment {
+					t.Errorf("comment %d = %+v, want %+v", i, got, want)
+				}
+			}
+			if got := cf.Comments[2].SelectedText; got != "quick brown" {
+				t.Errorf("SelectedText = %q, want %q", got, "quick brown")
+			}
+		})
+	}
+}
+
+func TestExportMergesOverlapping(t *testing.T) {
+	cf := &store.CommentFile{Comments: []store.Comment{
+		{SourceStart: 1, SourceEnd: 1, Comment: "a"},
+		{SourceStart: 1, SourceEnd: 1, StartCol: 1, EndCol: 3, Comment: "b"},
+	}}
+	want := "{==one two==}{>>a<<}{>>b<<}\n"
+	if got := string(Export([]byte("one two\n"), cf, CriticMarkup)); got != want {
+		t.Errorf("Export() = %q, want %q", got, want)
+	}
+}
+
+func TestImportCommentAlone(t *testing.T) {
+	doc, cf := Import([]byte("one\ntwo <!-- mdmu: note -->\n"))
+	if string(doc) != "one\ntwo \n" {
+		t.Errorf("document = %q", doc)
+	}
+	if len(cf.Comments) != 1 || cf.Comments[0].SourceStart != 2 || cf.Comments[0].Comment != "note" {
+		t.Errorf("comments = %+v, want a note on line 2", cf.Comments)
+	}
+}
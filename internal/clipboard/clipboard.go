@@ -1,12 +1,93 @@
 package clipboard
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
 )
 
+// Backends lists the clipboard backends that can be configured.
+var Backends = []string{"auto", "pbcopy", "xclip", "xsel", "wl-copy", "clip", "osc52", "command", "none"}
+
+// Backend copies text using one clipboard mechanism.
+type Backend struct {
+	name    string
+	command []string
+}
+
+// New returns the named backend. The "command" backend runs command with the
+// text on its standard input.
+func New(name string, command []string) (Backend, error) {
+	switch name {
+	case "", "auto":
+		return Backend{name: "auto"}, nil
+	case "command":
+		if len(command) == 0 {
+			return Backend{}, fmt.Errorf("clipboard backend %q needs a command", name)
+		}
+		return Backend{name: name, command: command}, nil
+	}
+	for _, b := range Backends {
+		if b == name {
+			return Backend{name: name}, nil
+		}
+	}
+	return Backend{}, fmt.Errorf("unknown clipboard backend %q (available: %s)", name, strings.Join(Backends, ", "))
+}
+
+// Name returns the backend's name.
+func (b Backend) Name() string {
+	if b.name == "" {
+		return "auto"
+	}
+	return b.name
+}
+
+// Copy copies text to the clipboard.
+func (b Backend) Copy(text string) error {
+	var cmd *exec.Cmd
+	switch b.Name() {
+	case "auto":
+		return Copy(text)
+	case "osc52":
+		return copyOSC52(text)
+	case "none":
+		return errors.New("clipboard is disabled")
+	case "pbcopy":
+		cmd = exec.Command("pbcopy")
+	case "xclip":
+		cmd = exec.Command("xclip", "-selection", "clipboard")
+	case "xsel":
+		cmd = exec.Command("xsel", "--clipboard", "--input")
+	case "wl-copy":
+		cmd = exec.Command("wl-copy")
+	case "clip":
+		cmd = exec.Command("clip.exe")
+	case "command":
+		cmd = exec.Command(b.command[0], b.command[1:]...)
+	}
+
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
+
+// copyOSC52 asks the terminal to set the clipboard, which also works over
+// SSH when the terminal supports it.
+func copyOSC52(text string) error {
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("opening terminal: %w", err)
+	}
+	defer tty.Close()
+
+	_, err = fmt.Fprintf(tty, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	return err
+}
+
 // Copy copies text to the system clipboard.
 func Copy(text string) error {
 	var cmd *exec.Cmd
@@ -19,8 +100,10 @@ func Copy(text string) error {
 			cmd = exec.Command("xclip", "-selection", "clipboard")
 		} else if _, err := exec.LookPath("xsel"); err == nil {
 			cmd = exec.Command("xsel", "--clipboard", "--input")
+		} else if _, err := exec.LookPath("wl-copy"); err == nil {
+			cmd = exec.Command("wl-copy")
 		} else {
-			return fmt.Errorf("no clipboard command found (install xclip or xsel)")
+			return fmt.Errorf("no clipboard command found (install xclip, xsel or wl-clipboard)")
 		}
 	case "windows":
 		cmd = exec.Command("clip.exe")
@@ -1,6 +1,7 @@
 package clipboard
 
 import (
+	"os"
 	"os/exec"
 	"runtime"
 	"testing"
@@ -43,3 +44,33 @@ func TestCopyDoesNotPanic(t *testing.T) {
 		}
 	}
 }
+
+func TestCommandBackend(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	out := t.TempDir() + "/out"
+	b, err := New("command", []string{"sh", "-c", "cat > " + out})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := b.Copy("hello"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("copied %q, want %q", data, "hello")
+	}
+}
+
+func TestNewRejectsUnknownBackend(t *testing.T) {
+	if _, err := New("carrier-pigeon", nil); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+	if _, err := New("command", nil); err == nil {
+		t.Error("expected an error for a command backend without a command")
+	}
+}
@@ -0,0 +1,261 @@
+// Package config loads mdmu's user and per-project configuration files.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/paulbuckley/mdmu/internal/xdg"
+)
+
+// ProjectFile is the name of the per-project configuration file, looked up
+// in the document's directory and its parents. Project files come with the
+// documents, which may not be trusted, so they can't set the clipboard,
+// which can run a command, or where comment files are written.
+const ProjectFile = ".mdmu.yaml"
+
+// HyperlinkModes are the values of the hyperlinks setting: whether links
+// are terminal hyperlinks, "auto" guessing from the terminal.
+var HyperlinkModes = []string{"auto", "on", "off"}
+
+// Config holds the settings that can be set in configuration files.
+type Config struct {
+	Theme       string              `yaml:"theme"`
+	Hyperlinks  string              `yaml:"hyperlinks"` // "auto", "on" or "off"
+	Layout      Layout              `yaml:"layout"`
+	Output      Output              `yaml:"output"`
+	Clipboard   Clipboard           `yaml:"clipboard"`
+	Persistence Persistence         `yaml:"persistence"`
+	Keymap      string              `yaml:"keymap"` // key binding preset
+	Keys        map[string][]string `yaml:"keys"`   // action -> keys, replacing the preset's
+}
+
+// Layout controls the size of the interface elements.
+type Layout struct {
+	Split         float64 `yaml:"split"`          // fraction of the width, or height when stacked, for the markdown pane
+	Panes         string  `yaml:"panes"`          // "auto", "side" or "stacked"
+	HideComments  bool    `yaml:"hide_comments"`  // collapse the comments pane to a count
+	CommentHeight int     `yaml:"comment_height"` // rows of the comment input
+}
+
+// Output controls how comments are formatted.
+type Output struct {
+	Preset    string `yaml:"preset"`     // named output preset
+	Prompt    string `yaml:"prompt"`     // overrides the preset's prompt template
+	Quotes    string `yaml:"quotes"`     // "full", or "none" for line references only
+	Context   int    `yaml:"context"`    // lines quoted around each comment, 0-20
+	Layout    string `yaml:"layout"`     // "flat", or "sections" to group by heading
+	Untouched bool   `yaml:"untouched"`  // list sections without comments, with "sections"
+	MaxChars  int    `yaml:"max_chars"`  // size budget in characters; 0 means none
+	MaxTokens int    `yaml:"max_tokens"` // size budget in estimated tokens; 0 means none
+}
+
+// Clipboard selects how output is copied.
+type Clipboard struct {
+	Backend string   `yaml:"backend"`
+	Command []string `yaml:"command"` // program and arguments for the "command" backend
+}
+
+// Persistence controls whether comments outlive the session.
+type Persistence struct {
+	Enabled bool   `yaml:"enabled"`
+	Dir     string `yaml:"dir"` // absolute, or relative to the document's directory
+}
+
+// Default returns the configuration used when no file sets a value.
+func Default() Config {
+	return Config{
+		Theme:      "auto",
+		Hyperlinks: "auto",
+		Layout:     Layout{Split: 0.65, Panes: "auto", CommentHeight: 3},
+		Output:     Output{Preset: "default", Quotes: "full", Layout: "flat"},
+		Clipboard:  Clipboard{Backend: "auto"},
+		Keymap:     "default",
+		Persistence: Persistence{
+			Dir: filepath.Join(xdg.StateDir(), "comments"),
+		},
+	}
+}
+
+// UserPath returns the location of the user configuration file.
+func UserPath() string {
+	return filepath.Join(xdg.ConfigDir(), "config.yaml")
+}
+
+// LayoutPath returns the location of the file remembering the pane layout
+// last chosen in the TUI.
+func LayoutPath() string {
+	return filepath.Join(xdg.StateDir(), "layout.yaml")
+}
+
+// LayoutState is the part of the layout the TUI remembers between sessions.
+// Nil fields are left as they were.
+type LayoutState struct {
+	Split        *float64 `yaml:"split,omitempty"`
+	Panes        *string  `yaml:"panes,omitempty"`
+	HideComments *bool    `yaml:"hide_comments,omitempty"`
+}
+
+// SaveLayout remembers the layout settings set in state at path, as a config
+// file setting only the layout keys the TUI changed.
+func SaveLayout(path string, state LayoutState) error {
+	var file struct {
+		Layout LayoutState `yaml:"layout"`
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("saving layout: %w", err)
+	}
+	// A state file that can't be read is replaced
+	_ = yaml.Unmarshal(data, &file)
+	if state.Split != nil {
+		file.Layout.Split = state.Split
+	}
+	if state.Panes != nil {
+		file.Layout.Panes = state.Panes
+	}
+	if state.HideComments != nil {
+		file.Layout.HideComments = state.HideComments
+	}
+
+	data, err = yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("saving layout: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("saving layout: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("saving layout: %w", err)
+	}
+	return nil
+}
+
+// FindProject returns the nearest project configuration file in dir or its
+// parents, or "" when there is none.
+func FindProject(dir string) string {
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Load returns the defaults overlaid with each existing file in order, so
+// later files take precedence. Missing files are skipped.
+func Load(paths ...string) (Config, error) {
+	cfg := Default()
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := loadFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	if filepath.Base(path) == ProjectFile {
+		if err := checkProject(data); err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+
+	if dir := cfg.Persistence.Dir; strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			cfg.Persistence.Dir = filepath.Join(home, dir[2:])
+		}
+	}
+	return nil
+}
+
+// checkProject reports the settings a project file may not make.
+func checkProject(data []byte) error {
+	var file struct {
+		Clipboard   yaml.Node `yaml:"clipboard"`
+		Persistence struct {
+			Dir yaml.Node `yaml:"dir"`
+		} `yaml:"persistence"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil // reported when the file is decoded
+	}
+	var errs []error
+	if !file.Clipboard.IsZero() {
+		errs = append(errs, errors.New("clipboard can only be set in the user config or with flags"))
+	}
+	if !file.Persistence.Dir.IsZero() {
+		errs = append(errs, errors.New("persistence.dir can only be set in the user config"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports every invalid setting at once. Names of themes, presets,
+// clipboard backends and key actions are checked by the packages that own
+// them.
+func (c Config) Validate() error {
+	var errs []error
+	if !slices.Contains(HyperlinkModes, c.Hyperlinks) {
+		errs = append(errs, fmt.Errorf("hyperlinks must be one of %s, got %q", strings.Join(HyperlinkModes, ", "), c.Hyperlinks))
+	}
+	if c.Layout.Split < 0.2 || c.Layout.Split > 0.9 {
+		errs = append(errs, fmt.Errorf("layout.split must be between 0.2 and 0.9, got %g", c.Layout.Split))
+	}
+	if c.Layout.CommentHeight < 1 || c.Layout.CommentHeight > 20 {
+		errs = append(errs, fmt.Errorf("layout.comment_height must be between 1 and 20, got %d", c.Layout.CommentHeight))
+	}
+	if c.Output.Context < 0 || c.Output.Context > 20 {
+		errs = append(errs, fmt.Errorf("output.context must be between 0 and 20, got %d", c.Output.Context))
+	}
+	if c.Output.MaxChars < 0 {
+		errs = append(errs, fmt.Errorf("output.max_chars must not be negative, got %d", c.Output.MaxChars))
+	}
+	if c.Output.MaxTokens < 0 {
+		errs = append(errs, fmt.Errorf("output.max_tokens must not be negative, got %d", c.Output.MaxTokens))
+	}
+	if c.Clipboard.Backend == "command" && len(c.Clipboard.Command) == 0 {
+		errs = append(errs, errors.New(`clipboard.command is required with the "command" backend`))
+	}
+	if c.Persistence.Enabled && c.Persistence.Dir == "" {
+		errs = append(errs, errors.New("persistence.dir must not be empty"))
+	}
+	for _, action := range slices.Sorted(maps.Keys(c.Keys)) {
+		for _, k := range c.Keys[action] {
+			if strings.TrimSpace(k) == "" {
+				errs = append(errs, fmt.Errorf("keys.%s: empty key", action))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	project := filepath.Join(dir, "project", ProjectFile)
+	writeFile(t, user, "theme: light\nlayout:\n  split: 0.5\n  comment_height: 5\n")
+	writeFile(t, project, "layout:\n  split: 0.7\n")
+
+	cfg, err := Load(user, project, filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Theme != "light" {
+		t.Errorf("Theme = %q, want light", cfg.Theme)
+	}
+	if cfg.Layout.Split != 0.7 {
+		t.Errorf("Split = %g, want the project value 0.7", cfg.Layout.Split)
+	}
+	if cfg.Layout.CommentHeight != 5 {
+		t.Errorf("CommentHeight = %d, want the user value 5", cfg.Layout.CommentHeight)
+	}
+	if cfg.Output.Preset != "default" {
+		t.Errorf("Preset = %q, want the default", cfg.Output.Preset)
+	}
+}
+
+func TestSaveLayout(t *testing.T) {
+	dir := t.TempDir()
+	user := filepath.Join(dir, "user.yaml")
+	saved := filepath.Join(dir, "state", "layout.yaml")
+	writeFile(t, user, "layout:\n  panes: side\n  comment_height: 5\n")
+
+	split, panes := 0.4, "stacked"
+	if err := SaveLayout(saved, LayoutState{Split: &split, Panes: &panes}); err != nil {
+		t.Fatalf("SaveLayout failed: %v", err)
+	}
+	hide := true
+	if err := SaveLayout(saved, LayoutState{HideComments: &hide}); err != nil {
+		t.Fatalf("SaveLayout failed: %v", err)
+	}
+
+	// The remembered layout is a default the user file overrides
+	cfg, err := Load(saved, user)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := Layout{Split: 0.4, Panes: "side", HideComments: true, CommentHeight: 5}
+	if cfg.Layout != want {
+		t.Errorf("Layout = %+v, want %+v, keeping earlier saves and the user's settings", cfg.Layout, want)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "layout:\n  splitt: 0.5\n")
+
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "splitt") || !strings.Contains(err.Error(), path) {
+		t.Errorf("expected an error naming the field and file, got %v", err)
+	}
+}
+
+func TestLoadRejectsProjectClipboardAndStore(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, ProjectFile)
+	writeFile(t, project, "clipboard:\n  backend: command\n  command: [sh, -c, 'echo pwned']\n"+
+		"persistence:\n  enabled: true\n  dir: /tmp/elsewhere\n")
+
+	_, err := Load(project)
+	if err == nil {
+		t.Fatal("a project file setting the clipboard and persistence.dir should be rejected")
+	}
+	for _, want := range []string{"clipboard", "persistence.dir"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should name %s", err, want)
+		}
+	}
+
+	// The same settings are allowed in the user file, and the project file
+	// may still turn persistence on
+	user := filepath.Join(dir, "user.yaml")
+	writeFile(t, user, "clipboard:\n  backend: command\n  command: [pbcopy]\npersistence:\n  dir: /tmp/comments\n")
+	writeFile(t, project, "persistence:\n  enabled: true\n")
+	cfg, err := Load(user, project)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Clipboard.Backend != "command" || cfg.Persistence.Dir != "/tmp/comments" || !cfg.Persistence.Enabled {
+		t.Errorf("config = %+v, want the user's clipboard and directory with persistence on", cfg)
+	}
+}
+
+func TestFindProject(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, ProjectFile), "theme: dark\n")
+	nested := filepath.Join(root, "docs", "plans")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := FindProject(nested), filepath.Join(root, ProjectFile); got != want {
+		t.Errorf("FindProject = %q, want %q", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("defaults should be valid: %v", err)
+	}
+
+	cfg := Default()
+	cfg.Layout.Split = 1.5
+	cfg.Clipboard.Backend = "command"
+	cfg.Output.MaxTokens = -1
+	cfg.Output.Context = 50
+	cfg.Hyperlinks = "sometimes"
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{"layout.split", "clipboard.command", "output.max_tokens", "output.context", "hyperlinks"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should mention %s", err, want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package markdown
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/paulbuckley/mdmu/internal/theme"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Document is a parsed markdown document. It renders each top-level block
+// separately and keeps what it renders, so rendering again at a recent width
+// with the same colors and link settings costs nothing, and after Update only
+// the blocks that changed are rendered.
+type Document struct {
+	source      []byte
+	parseSource []byte // source with any front matter masked
+	frontMatter *FrontMatter
+	blocks      []block
+	references  string // link reference definitions, which any block may use
+	hyperlinks  bool   // links render as terminal hyperlinks
+	linkBase    string // directory relative links are resolved against
+	colors      palette
+
+	widths []*widthCache // most recently rendered first
+}
+
+// block is a top-level block of a document.
+type block struct {
+	node ast.Node
+
+	// first and last are the 1-indexed source lines that can affect how the
+	// block renders: from the end of the previous block to the start of the
+	// next, so fences and setext underlines are included. Zero for blocks
+	// without source lines, such as thematic breaks, which aren't cached.
+	first, last int
+}
+
+// renderedBlock is a block rendered at one width, with source positions as
+// they were when it was rendered.
+type renderedBlock struct {
+	r        *ansiRenderer
+	line     int  // first line of the block's source text
+	offset   int  // byte offset of that line
+	portable bool // mappings stay within the block, so they can be shifted when it moves
+}
+
+// widthCache holds the renderings of a document at one width, with the
+// colors and link settings they were rendered with.
+type widthCache struct {
+	key    renderKey
+	doc    *RenderedDocument         // nil after an Update
+	source *RenderedDocument         // from RenderSource; nil after an Update
+	blocks map[string]*renderedBlock // by the block's source text
+}
+
+// renderKey is what a rendering depends on besides the source.
+type renderKey struct {
+	width      int
+	hyperlinks bool
+	linkBase   string
+	colors     palette
+}
+
+// maxCachedWidths bounds the widths a Document keeps renderings for.
+const maxCachedWidths = 4
+
+// Parse parses markdown source, ready to render.
+func Parse(source []byte) *Document {
+	d := &Document{colors: defaultPalette}
+	d.parse(source)
+	return d
+}
+
+// Source returns the document's markdown source.
+func (d *Document) Source() []byte {
+	return d.source
+}
+
+// SetHyperlinks sets whether links render as OSC 8 terminal hyperlinks, for
+// terminals that support them, rather than followed by their destination.
+func (d *Document) SetHyperlinks(on bool) {
+	d.hyperlinks = on
+}
+
+// SetLinkBase sets the directory that relative link destinations are
+// resolved against when links render as terminal hyperlinks.
+func (d *Document) SetLinkBase(dir string) {
+	d.linkBase = dir
+}
+
+// SetTheme sets the colors the document renders with.
+func (d *Document) SetTheme(t *theme.Theme) {
+	d.colors = newPalette(t)
+}
+
+// Update replaces the document's source with an edited version. Blocks
+// whose source text is unchanged are reused by the next Render, even where
+// the edit moved them.
+func (d *Document) Update(source []byte) {
+	references := d.references
+	d.parse(source)
+	for _, wc := range d.widths {
+		wc.doc, wc.source = nil, nil
+		if d.references != references {
+			wc.blocks = nil
+		}
+	}
+}
+
+func (d *Document) parse(source []byte) {
+	d.source = source
+	d.parseSource = source
+	d.frontMatter = ParseFrontMatter(source)
+	if d.frontMatter != nil {
+		// Front matter is rendered as a metadata panel; hide it from goldmark,
+		// which would otherwise read it as a thematic break and paragraph.
+		d.parseSource = maskFrontMatter(source, d.frontMatter)
+	}
+
+	ctx := parser.NewContext()
+	doc := newGoldmark().Parser().Parse(text.NewReader(d.parseSource), parser.WithContext(ctx))
+	d.references = referenceKey(ctx)
+
+	lines := newANSIRenderer(d.parseSource, 0) // for its source line lookups
+	d.blocks = d.blocks[:0]
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		d.blocks = append(d.blocks, block{node: node})
+	}
+
+	// Each block's text runs from after the previous block to before the next
+	prevEnd := 0
+	for i := range d.blocks {
+		start, end, ok := blockLines(lines, d.blocks[i].node)
+		if !ok || start <= prevEnd {
+			continue
+		}
+		d.blocks[i].first = prevEnd + 1
+		d.blocks[i].last = len(lines.lineOffsets)
+		for j := i - 1; j >= 0; j-- {
+			if d.blocks[j].first > 0 {
+				d.blocks[j].last = start - 1
+				break
+			}
+		}
+		prevEnd = end
+	}
+}
+
+// blockLines returns the source lines of a block's own text, and false when
+// it has none.
+func blockLines(r *ansiRenderer, node ast.Node) (int, int, bool) {
+	if l := node.Lines(); l != nil && l.Len() > 0 {
+		start, end := r.sourceLineRange(node)
+		return start, end, true
+	}
+	start, end := r.sourceLineRangeFromChildren(node)
+	return start, end, start > 0
+}
+
+// referenceKey describes the link reference definitions of a parse.
+func referenceKey(ctx parser.Context) string {
+	var refs []string
+	for _, ref := range ctx.References() {
+		refs = append(refs, string(ref.Label())+"\x00"+string(ref.Destination())+"\x00"+string(ref.Title()))
+	}
+	sort.Strings(refs)
+	return strings.Join(refs, "\x00\x00")
+}
+
+// Render renders the document with ANSI styling at a width, tracking the
+// mapping from rendered lines to source lines. Rendering isn't lazy: at a
+// width not cached every block is rendered, and only renders kept from
+// before, at this width or of blocks Update left alone, are reused.
+func (d *Document) Render(width int) *RenderedDocument {
+	wc := d.widthCache(width)
+	if wc.doc != nil {
+		return wc.doc
+	}
+
+	r := newANSIRenderer(d.parseSource, width)
+	r.hyperlinks, r.linkBase, r.colors = d.hyperlinks, d.linkBase, d.colors
+	if d.frontMatter != nil {
+		r.renderFrontMatter(d.frontMatter, d.source)
+	}
+	blocks := map[string]*renderedBlock{}
+	for _, b := range d.blocks {
+		if b.first == 0 {
+			r.appendBlock(r.renderBlock(b), 0, 0)
+			continue
+		}
+
+		key := string(d.parseSource[r.lineOffsets[b.first-1]:r.lineEnd(b.last)])
+		offset := r.lineOffsets[b.first-1]
+		rb := wc.blocks[key]
+		if rb == nil || !rb.portable && (rb.line != b.first || rb.offset != offset) {
+			rb = r.renderBlock(b)
+		}
+		r.appendBlock(rb, b.first-rb.line, offset-rb.offset)
+
+		// Footnotes are numbered across the document, so blocks with them
+		// are rendered afresh
+		if len(rb.r.footnoteRefs) == 0 && len(rb.r.footnoteDefs) == 0 {
+			blocks[key] = rb
+		}
+	}
+
+	wc.blocks = blocks
+	wc.doc = &RenderedDocument{
+		Lines:        r.lines,
+		Mappings:     r.mappings,
+		FrontMatter:  d.frontMatter,
+		FootnoteRefs: r.footnoteRefs,
+		FootnoteDefs: r.footnoteDefs,
+		colors:       r.colors,
+	}
+	return wc.doc
+}
+
+// widthCache returns the cache for a width with the current colors and link
+// settings, making it the most recent and dropping the least recent beyond
+// maxCachedWidths.
+func (d *Document) widthCache(width int) *widthCache {
+	key := renderKey{width: width, hyperlinks: d.hyperlinks, linkBase: d.linkBase, colors: d.colors}
+	for i, wc := range d.widths {
+		if wc.key == key {
+			copy(d.widths[1:i+1], d.widths[:i])
+			d.widths[0] = wc
+			return wc
+		}
+	}
+	wc := &widthCache{key: key}
+	d.widths = append([]*widthCache{wc}, d.widths[:min(len(d.widths), maxCachedWidths-1)]...)
+	return wc
+}
+
+// lineEnd returns the byte offset just past 1-indexed line n, including its
+// line ending.
+func (r *ansiRenderer) lineEnd(n int) int {
+	if n < len(r.lineOffsets) {
+		return r.lineOffsets[n]
+	}
+	return len(r.source)
+}
+
+// renderBlock renders a top-level block on its own.
+func (r *ansiRenderer) renderBlock(b block) *renderedBlock {
+	sub := r.subRenderer(r.width)
+	sub.renderNode(b.node, 0)
+	rb := &renderedBlock{r: sub, line: b.first, portable: b.first > 0}
+	if b.first > 0 {
+		rb.offset = r.lineOffsets[b.first-1]
+	}
+	for _, m := range sub.mappings {
+		if m.SourceStart < b.first || m.SourceEnd > b.last {
+			rb.portable = false
+		}
+	}
+	return rb
+}
+
+// appendBlock appends the lines of a rendered block, moved down the source
+// by lines and bytes.
+func (r *ansiRenderer) appendBlock(rb *renderedBlock, lines, bytes int) {
+	base := len(r.lines)
+	for i, line := range rb.r.lines {
+		m := rb.r.mappings[i]
+		r.addLine(line, m.SourceStart+lines, m.SourceEnd+lines)
+		segments := m.Segments
+		if bytes != 0 && len(segments) > 0 {
+			segments = make([]Segment, len(m.Segments))
+			for j, seg := range m.Segments {
+				seg.SourceStart += bytes
+				seg.SourceEnd += bytes
+				segments[j] = seg
+			}
+		}
+		r.mappings[len(r.mappings)-1].Segments = segments
+	}
+	for _, ref := range rb.r.footnoteRefs {
+		ref.RenderedLine += base
+		r.footnoteRefs = append(r.footnoteRefs, ref)
+	}
+	for index, line := range rb.r.footnoteDefs {
+		r.footnoteDefs[index] = line + base
+	}
+}
+
+// newGoldmark returns the parser configuration shared by the terminal and
+// HTML renderings, with any further options on top.
+func newGoldmark(opts ...goldmark.Option) goldmark.Markdown {
+	return goldmark.New(append([]goldmark.Option{
+		goldmark.WithExtensions(
+			extension.Footnote,
+			extension.DefinitionList,
+			extension.NewTypographer(
+				extension.WithTypographicSubstitutions(typographicSubstitutions),
+			),
+		),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+	}, opts...)...)
+}
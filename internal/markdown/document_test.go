@@ -0,0 +1,162 @@
+package markdown
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/paulbuckley/mdmu/internal/markdown/markdowntest"
+	"github.com/paulbuckley/mdmu/internal/theme"
+)
+
+const editedSource = `---
+title: Plan
+---
+Intro with a [link][ref] and a note[^n].
+
+Setext heading
+==============
+
+` + "```go\nfunc main() {}\n```" + `
+
+- one
+- two
+
+---
+
+> quoted
+
+[ref]: https://example.com
+[^n]: The note.
+`
+
+// assertSameRender checks that a document renders as a fresh parse does.
+func assertSameRender(t *testing.T, got *RenderedDocument, source string, width int) {
+	t.Helper()
+	want, _ := ParseAndRender([]byte(source), width)
+	if !reflect.DeepEqual(got.Lines, want.Lines) {
+		t.Errorf("lines differ from a fresh render:\ngot  %q\nwant %q", got.Lines, want.Lines)
+	}
+	if !reflect.DeepEqual(got.Mappings, want.Mappings) {
+		t.Errorf("mappings differ from a fresh render:\ngot  %+v\nwant %+v", got.Mappings, want.Mappings)
+	}
+	if !reflect.DeepEqual(got.FootnoteRefs, want.FootnoteRefs) || !reflect.DeepEqual(got.FootnoteDefs, want.FootnoteDefs) {
+		t.Errorf("footnotes differ from a fresh render")
+	}
+}
+
+func TestDocumentUpdate(t *testing.T) {
+	d := Parse([]byte(editedSource))
+	first := d.Render(40)
+
+	edits := []string{
+		strings.Replace(editedSource, "Intro", "A new paragraph.\n\nIntro", 1),
+		strings.Replace(editedSource, "==============", "--------------", 1),
+		strings.Replace(editedSource, "```go", "```python", 1),
+		strings.Replace(editedSource, "https://example.com", "https://example.org", 1),
+		strings.Replace(editedSource, "title: Plan", "title: Plan\nstatus: draft", 1),
+		strings.Replace(editedSource, "Intro", "Intro[^m]", 1) + "[^m]: Another.\n",
+	}
+	for _, source := range edits {
+		d.Update([]byte(source))
+		assertSameRender(t, d.Render(40), source, 40)
+		assertSameRender(t, d.Render(60), source, 60)
+	}
+
+	d.Update([]byte(editedSource))
+	if again := d.Render(40); &again.Lines[0] == &first.Lines[0] {
+		t.Error("Update should drop the rendered document")
+	}
+	if d.Render(40) != d.Render(40) {
+		t.Error("rendering again at the same width should reuse the rendering")
+	}
+}
+
+func TestDocumentUpdateReusesBlocks(t *testing.T) {
+	source := "# Title\n\nFirst paragraph.\n\nSecond paragraph.\n"
+	d := Parse([]byte(source))
+	d.Render(40)
+	before := d.widths[0].blocks["\nSecond paragraph.\n"]
+	if before == nil {
+		t.Fatal("the second paragraph should be cached")
+	}
+
+	edited := "# Title\n\nFirst paragraph,\nnow longer.\n\nSecond paragraph.\n"
+	d.Update([]byte(edited))
+	assertSameRender(t, d.Render(40), edited, 40)
+	if d.widths[0].blocks["\nSecond paragraph.\n"] != before {
+		t.Error("the unchanged paragraph should be reused where the edit moved it")
+	}
+}
+
+func TestDocumentCacheFollowsSettings(t *testing.T) {
+	d := Parse([]byte("# Title\n\nA [link](guide.md).\n"))
+	dark := d.Render(40)
+
+	d.SetTheme(theme.Light())
+	light := d.Render(40)
+	if reflect.DeepEqual(light.Lines, dark.Lines) {
+		t.Error("changing the theme should render in its colors")
+	}
+
+	d.SetHyperlinks(true)
+	linked := d.Render(40)
+	if !strings.Contains(linked.Lines[2], "\033]8;") {
+		t.Errorf("turning hyperlinks on should render them, got %q", linked.Lines[2])
+	}
+	d.SetLinkBase("/docs")
+	if d.Render(40) == linked {
+		t.Error("changing the link base should render the links again")
+	}
+
+	d.SetHyperlinks(false)
+	d.SetLinkBase("")
+	d.SetTheme(theme.Dark())
+	if d.Render(40) != dark {
+		t.Error("going back to the first settings should reuse their rendering")
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	source := markdowntest.Large(100_000)
+	for b.Loop() {
+		Parse(source).Render(80)
+	}
+}
+
+func BenchmarkRenderRecentWidth(b *testing.B) {
+	d := Parse(markdowntest.Large(100_000))
+	d.Render(80)
+	d.Render(100)
+	b.ResetTimer()
+	for i := 0; b.Loop(); i++ {
+		d.Render(80 + 20*(i%2))
+	}
+}
+
+// BenchmarkRenderNewWidth measures resizing to widths not rendered before,
+// cycling through more than are cached.
+func BenchmarkRenderNewWidth(b *testing.B) {
+	d := Parse(markdowntest.Large(100_000))
+	d.Render(80)
+	b.ResetTimer()
+	for i := 0; b.Loop(); i++ {
+		d.Render(81 + i%(maxCachedWidths+1))
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	source := markdowntest.Large(100_000)
+	edited := []byte(strings.Replace(string(source), "Section 5000", "Section five thousand", 1))
+	d := Parse(source)
+	d.Render(80)
+	b.ResetTimer()
+	for i := 0; b.Loop(); i++ {
+		if i%2 == 0 {
+			d.Update(edited)
+		} else {
+			d.Update(source)
+		}
+		d.Render(80)
+	}
+}
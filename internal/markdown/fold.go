@@ -0,0 +1,161 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/yuin/goldmark/ast"
+)
+
+// RegionKind is the kind of part of a document a Region covers.
+type RegionKind int
+
+const (
+	RegionSection RegionKind = iota // a heading and the text up to the next heading at its level or above
+	RegionCode                      // a code block, fences included
+	RegionList                      // a list
+)
+
+// Region is a part of a document that can be folded away.
+type Region struct {
+	Kind       RegionKind
+	Level      int // heading level, for sections
+	Start, End int // 1-indexed source lines
+}
+
+// Contains reports whether a source line is within the region.
+func (g Region) Contains(line int) bool {
+	return g.Start <= line && line <= g.End
+}
+
+// Regions returns the sections, code blocks and lists of the document's
+// top level, in source order. Each spans more than one line.
+func (d *Document) Regions() []Region {
+	r := newANSIRenderer(d.parseSource, 0) // for its source line lookups
+	lineCount := len(r.lineOffsets)
+	text := func(n int) []byte {
+		return bytes.TrimSpace(d.parseSource[r.lineOffsets[n-1]:r.lineEnd(n)])
+	}
+
+	var regions []Region
+	for i, b := range d.blocks {
+		start, end, ok := blockLines(r, b.node)
+		if !ok {
+			continue
+		}
+		switch n := b.node.(type) {
+		case *ast.Heading:
+			// The section runs to the next heading at its level or above,
+			// without the blank lines before it
+			end = lineCount
+			for _, next := range d.blocks[i+1:] {
+				if h, ok := next.node.(*ast.Heading); ok && h.Level <= n.Level {
+					end, _, _ = blockLines(r, h)
+					end--
+					break
+				}
+			}
+			for end > start && len(text(end)) == 0 {
+				end--
+			}
+			regions = append(regions, Region{Kind: RegionSection, Level: n.Level, Start: start, End: end})
+		case *ast.FencedCodeBlock:
+			start--
+			if end < lineCount && (bytes.HasPrefix(text(end+1), []byte("```")) || bytes.HasPrefix(text(end+1), []byte("~~~"))) {
+				end++
+			}
+			regions = append(regions, Region{Kind: RegionCode, Start: max(start, 1), End: end})
+		case *ast.CodeBlock:
+			regions = append(regions, Region{Kind: RegionCode, Start: start, End: end})
+		case *ast.List:
+			regions = append(regions, Region{Kind: RegionList, Start: start, End: end})
+		}
+	}
+
+	multiLine := regions[:0]
+	for _, g := range regions {
+		if g.End > g.Start {
+			multiLine = append(multiLine, g)
+		}
+	}
+	return multiLine
+}
+
+// Fold returns the document with the rows of each folded region replaced
+// by its first row, which maps to the whole region and notes how many lines
+// are hidden. Rows of regions inside a folded region stay hidden with it.
+func (doc *RenderedDocument) Fold(folded []Region, width int) *RenderedDocument {
+	if len(folded) == 0 {
+		return doc
+	}
+
+	out := &RenderedDocument{
+		FrontMatter:  doc.FrontMatter,
+		FootnoteDefs: map[int]int{},
+		colors:       doc.colors,
+	}
+	rowOf := make([]int, len(doc.Lines)) // new row of each old row
+	for i := 0; i < len(doc.Lines); {
+		m := doc.Mappings[i]
+		g, ok := outermost(folded, m)
+		if !ok {
+			rowOf[i] = len(out.Lines)
+			m.RenderedLine = len(out.Lines)
+			out.Lines = append(out.Lines, doc.Lines[i])
+			out.Mappings = append(out.Mappings, m)
+			i++
+			continue
+		}
+
+		// Rows in the region's run that map elsewhere, such as thematic
+		// breaks, which map to the first line, are folded with it
+		j := i
+		for j < len(doc.Lines) && doc.Mappings[j].SourceEnd <= g.End {
+			rowOf[j] = len(out.Lines)
+			j++
+		}
+		suffix := fmt.Sprintf(" ⋯ %d lines", g.End-g.Start+1)
+		line := ansi.Truncate(doc.Lines[i], max(width-VisibleLen(suffix), 0), "")
+		line = strings.TrimRight(line, " ") + doc.colors.muted + suffix + reset
+		out.Lines = append(out.Lines, line)
+		out.Mappings = append(out.Mappings, LineMapping{
+			RenderedLine: len(out.Mappings),
+			SourceStart:  g.Start,
+			SourceEnd:    g.End,
+			Segments:     m.Segments,
+		})
+		i = j
+	}
+
+	// Footnotes in folded regions lead to the region's row
+	for _, ref := range doc.FootnoteRefs {
+		ref.RenderedLine = rowOf[ref.RenderedLine]
+		if n := len(out.FootnoteRefs); n > 0 && out.FootnoteRefs[n-1] == ref {
+			continue
+		}
+		out.FootnoteRefs = append(out.FootnoteRefs, ref)
+	}
+	for index, line := range doc.FootnoteDefs {
+		out.FootnoteDefs[index] = rowOf[line]
+	}
+	return out
+}
+
+// outermost returns the largest folded region a row is within.
+func outermost(folded []Region, m LineMapping) (Region, bool) {
+	var best Region
+	found := false
+	for _, g := range folded {
+		if within(g, m) && (!found || g.End-g.Start > best.End-best.Start) {
+			best, found = g, true
+		}
+	}
+	return best, found
+}
+
+// within reports whether a row shows only lines of a region.
+func within(g Region, m LineMapping) bool {
+	return m.SourceStart > 0 && g.Contains(m.SourceStart) && g.Contains(m.SourceEnd)
+}
@@ -0,0 +1,57 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestRegions(t *testing.T) {
+	source := []byte("# Plan\n\nIntro.\n\n## Steps\n\n- one\n- two\n\n```go\nx := 1\n```\n\n---\n\n# Notes\n\nLast.\n")
+	want := []Region{
+		{Kind: RegionSection, Level: 1, Start: 1, End: 14},
+		{Kind: RegionSection, Level: 2, Start: 5, End: 14},
+		{Kind: RegionList, Start: 7, End: 8},
+		{Kind: RegionCode, Start: 10, End: 12},
+		{Kind: RegionSection, Level: 1, Start: 16, End: 18},
+	}
+	got := Parse(source).Regions()
+	if len(got) != len(want) {
+		t.Fatalf("got %d regions, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("region %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	source := []byte("# Plan\n\nIntro.\n\n## Steps\n\n- one\n- two\n\n---\n\n# Notes\n\nLast.\n")
+	doc := Parse(source).Render(40)
+	folded := doc.Fold([]Region{{Kind: RegionSection, Level: 2, Start: 5, End: 10}, {Kind: RegionList, Start: 7, End: 8}}, 40)
+
+	var rows []string
+	for _, line := range folded.Lines {
+		rows = append(rows, ansi.Strip(line))
+	}
+	text := strings.Join(rows, "\n")
+	if !strings.Contains(text, "Steps ⋯ 6 lines") || strings.Contains(text, "one") || strings.Contains(text, "───") {
+		t.Errorf("the section should fold to its heading, with the list and rule inside it:\n%s", text)
+	}
+	if !strings.Contains(text, "Last.") {
+		t.Errorf("text after the fold should be shown:\n%s", text)
+	}
+	for i, m := range folded.Mappings {
+		if m.RenderedLine != i {
+			t.Errorf("row %d has RenderedLine %d", i, m.RenderedLine)
+		}
+		if strings.Contains(rows[i], "Steps") && (m.SourceStart != 5 || m.SourceEnd != 10) {
+			t.Errorf("folded row maps to %d-%d, want the whole section 5-10", m.SourceStart, m.SourceEnd)
+		}
+	}
+	if len(folded.Lines) >= len(doc.Lines) {
+		t.Errorf("folding should remove rows: %d of %d left", len(folded.Lines), len(doc.Lines))
+	}
+}
@@ -0,0 +1,275 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mattn/go-runewidth"
+	"go.yaml.in/yaml/v3"
+)
+
+// FrontMatter is the YAML or TOML metadata block at the top of a document.
+type FrontMatter struct {
+	Format      string // "yaml" or "toml"
+	SourceStart int    // 1-indexed line of the opening delimiter
+	SourceEnd   int    // 1-indexed line of the closing delimiter
+	Fields      []FrontMatterField
+	Data        map[string]any
+	Err         error // set when the block could not be parsed
+}
+
+// FrontMatterField is a top-level key of the front matter.
+type FrontMatterField struct {
+	Key         string
+	Value       string // compact single-line rendering of the value
+	SourceStart int    // 1-indexed
+	SourceEnd   int
+}
+
+var tomlKeyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[A-Za-z0-9_.-]+)\s*=`)
+
+// ParseFrontMatter detects front matter delimited by "---" (YAML) or "+++"
+// (TOML) at the very start of source. It returns nil when there is none.
+// Blocks that fail to parse are still returned, with Err set.
+func ParseFrontMatter(source []byte) *FrontMatter {
+	lines := strings.Split(string(source), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+
+	var format string
+	var closers []string
+	switch strings.TrimRight(lines[0], " \t\r") {
+	case "---":
+		format, closers = "yaml", []string{"---", "..."}
+	case "+++":
+		format, closers = "toml", []string{"+++"}
+	default:
+		return nil
+	}
+
+	closing := -1
+	for i := 1; i < len(lines) && closing < 0; i++ {
+		for _, c := range closers {
+			if strings.TrimRight(lines[i], " \t\r") == c {
+				closing = i
+				break
+			}
+		}
+	}
+	if closing < 0 {
+		return nil
+	}
+
+	fm := &FrontMatter{
+		Format:      format,
+		SourceStart: 1,
+		SourceEnd:   closing + 1,
+		Data:        map[string]any{},
+	}
+	body := strings.Join(lines[1:closing], "\n")
+
+	var starts map[string]int // key -> 0-indexed line within body
+	var keys []string
+	if format == "yaml" {
+		keys, starts, fm.Err = parseYAMLFrontMatter(body, fm.Data)
+	} else {
+		keys, starts, fm.Err = parseTOMLFrontMatter(body, fm.Data)
+	}
+	if fm.Err != nil {
+		// A leading thematic break followed by prose is not front matter
+		if !looksLikeKeys(body, format) {
+			return nil
+		}
+		return fm
+	}
+
+	// Each field runs until the line before the next one starts. Indexes
+	// below are 0-indexed body lines; body line k is source line k+2.
+	sort.SliceStable(keys, func(i, j int) bool { return starts[keys[i]] < starts[keys[j]] })
+	for i, key := range keys {
+		end := closing - 2
+		if i+1 < len(keys) {
+			end = starts[keys[i+1]] - 1
+		}
+		// Trailing blank lines belong to no field
+		for end > starts[key] && strings.TrimSpace(lines[end+1]) == "" {
+			end--
+		}
+		fm.Fields = append(fm.Fields, FrontMatterField{
+			Key:         key,
+			Value:       compactValue(fm.Data[key]),
+			SourceStart: starts[key] + 2,
+			SourceEnd:   end + 2,
+		})
+	}
+	return fm
+}
+
+var yamlKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_"'-][^:]*:(\s|$)`)
+
+// looksLikeKeys reports whether a block that failed to parse was still meant
+// as metadata, judging by its first non-blank line.
+func looksLikeKeys(body, format string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if format == "toml" {
+			return tomlKeyPattern.MatchString(line) || strings.HasPrefix(line, "[")
+		}
+		return yamlKeyPattern.MatchString(line)
+	}
+	return false
+}
+
+func parseYAMLFrontMatter(body string, data map[string]any) ([]string, map[string]int, error) {
+	if strings.TrimSpace(body) == "" {
+		return nil, nil, nil
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(body), &node); err != nil {
+		return nil, nil, fmt.Errorf("invalid yaml front matter: %w", err)
+	}
+	if len(node.Content) == 0 || node.Content[0].Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("yaml front matter is not a mapping")
+	}
+	if err := node.Decode(&data); err != nil {
+		return nil, nil, fmt.Errorf("invalid yaml front matter: %w", err)
+	}
+
+	var keys []string
+	starts := map[string]int{}
+	mapping := node.Content[0]
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		key := mapping.Content[i]
+		keys = append(keys, key.Value)
+		starts[key.Value] = key.Line - 1
+	}
+	return keys, starts, nil
+}
+
+func parseTOMLFrontMatter(body string, data map[string]any) ([]string, map[string]int, error) {
+	if _, err := toml.Decode(body, &data); err != nil {
+		return nil, nil, fmt.Errorf("invalid toml front matter: %w", err)
+	}
+
+	// TOML positions aren't exposed by the decoder, so find the lines that
+	// introduce top-level keys and tables directly.
+	var keys []string
+	starts := map[string]int{}
+	inTable := false
+	for i, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		var key string
+		if strings.HasPrefix(line, "[") {
+			inTable = true
+			name := strings.Trim(line, "[] ")
+			key = strings.Trim(strings.SplitN(name, ".", 2)[0], `"'`)
+		} else if m := tomlKeyPattern.FindStringSubmatch(line); m != nil && !inTable {
+			key = strings.Trim(strings.SplitN(m[1], ".", 2)[0], `"'`)
+		}
+		if _, seen := starts[key]; key == "" || seen {
+			continue
+		}
+		if _, ok := data[key]; !ok {
+			continue
+		}
+		keys = append(keys, key)
+		starts[key] = i
+	}
+	return keys, starts, nil
+}
+
+// compactValue renders a front matter value on a single line.
+func compactValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.Join(strings.Fields(val), " ")
+	case []any:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = compactValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case []map[string]any:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = compactValue(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + ": " + compactValue(val[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// maskFrontMatter returns a copy of source with the front matter lines
+// blanked out, so the markdown parser skips them while byte offsets and line
+// numbers stay unchanged.
+func maskFrontMatter(source []byte, fm *FrontMatter) []byte {
+	masked := bytes.Clone(source)
+	line := 1
+	for i, b := range masked {
+		if b == '\n' {
+			line++
+			continue
+		}
+		if line > fm.SourceEnd {
+			break
+		}
+		if line >= fm.SourceStart {
+			masked[i] = ' '
+		}
+	}
+	return masked
+}
+
+// renderFrontMatter renders front matter as a compact panel with one line per
+// top-level key, each mapped to the source lines of that key.
+func (r *ansiRenderer) renderFrontMatter(fm *FrontMatter, source []byte) {
+	title := "metadata (" + fm.Format + ")"
+	if fm.Err != nil {
+		title = fm.Err.Error()
+	}
+	border := r.colors.muted + "│ " + reset
+	r.addLine(r.colors.muted+"┌ "+runewidth.Truncate(title, r.width-2, "…")+reset, fm.SourceStart, fm.SourceStart)
+
+	if fm.Err != nil {
+		// Show the raw block so it can still be read and commented on
+		lines := strings.Split(string(source), "\n")
+		for i := fm.SourceStart; i < fm.SourceEnd-1 && i < len(lines); i++ {
+			r.addLine(border+dim+runewidth.Truncate(lines[i], r.width-2, "…")+reset, i+1, i+1)
+		}
+	}
+
+	keyWidth := 0
+	for _, f := range fm.Fields {
+		keyWidth = max(keyWidth, runewidth.StringWidth(f.Key))
+	}
+	for _, f := range fm.Fields {
+		key := runewidth.FillRight(f.Key, keyWidth)
+		value := runewidth.Truncate(f.Value, r.width-keyWidth-4, "…")
+		r.addLine(border+r.colors.link+key+reset+"  "+value, f.SourceStart, f.SourceEnd)
+	}
+
+	r.addLine(r.colors.muted+"└"+reset, fm.SourceEnd, fm.SourceEnd)
+	r.addBlankLine(fm.SourceEnd, fm.SourceEnd)
+}
@@ -0,0 +1,90 @@
+package markdown
+
+import "testing"
+
+func TestParseFrontMatter_YAML(t *testing.T) {
+	source := []byte("---\ntitle: Auth plan\ntags:\n  - auth\n  - security\n\nstatus: draft\n---\n\n# Plan\n")
+	fm := ParseFrontMatter(source)
+	if fm == nil {
+		t.Fatal("expected front matter")
+	}
+	if fm.Err != nil {
+		t.Fatalf("unexpected error: %v", fm.Err)
+	}
+	if fm.Format != "yaml" || fm.SourceStart != 1 || fm.SourceEnd != 8 {
+		t.Errorf("front matter = %s lines %d-%d, want yaml lines 1-8", fm.Format, fm.SourceStart, fm.SourceEnd)
+	}
+
+	want := []FrontMatterField{
+		{Key: "title", Value: "Auth plan", SourceStart: 2, SourceEnd: 2},
+		{Key: "tags", Value: "[auth, security]", SourceStart: 3, SourceEnd: 5},
+		{Key: "status", Value: "draft", SourceStart: 7, SourceEnd: 7},
+	}
+	if len(fm.Fields) != len(want) {
+		t.Fatalf("got %d fields, want %d", len(fm.Fields), len(want))
+	}
+	for i := range want {
+		if fm.Fields[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, fm.Fields[i], want[i])
+		}
+	}
+	if fm.Data["title"] != "Auth plan" {
+		t.Errorf("Data[title] = %v, want %q", fm.Data["title"], "Auth plan")
+	}
+}
+
+func TestParseFrontMatter_TOML(t *testing.T) {
+	source := []byte("+++\ntitle = \"Plan\"\ndraft = true\n\n[owner]\nname = \"sam\"\n+++\nBody\n")
+	fm := ParseFrontMatter(source)
+	if fm == nil || fm.Err != nil {
+		t.Fatalf("expected parsed toml front matter, got %+v", fm)
+	}
+
+	want := []FrontMatterField{
+		{Key: "title", Value: "Plan", SourceStart: 2, SourceEnd: 2},
+		{Key: "draft", Value: "true", SourceStart: 3, SourceEnd: 3},
+		{Key: "owner", Value: "{name: sam}", SourceStart: 5, SourceEnd: 6},
+	}
+	if len(fm.Fields) != len(want) {
+		t.Fatalf("got %d fields, want %d", len(fm.Fields), len(want))
+	}
+	for i := range want {
+		if fm.Fields[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, fm.Fields[i], want[i])
+		}
+	}
+}
+
+func TestParseFrontMatter_ThematicBreakIsNotFrontMatter(t *testing.T) {
+	source := []byte("---\nJust some prose.\n---\n")
+	if fm := ParseFrontMatter(source); fm != nil {
+		t.Errorf("expected no front matter, got %+v", fm)
+	}
+}
+
+func TestParseAndRender_FrontMatterPanel(t *testing.T) {
+	source := []byte("---\ntitle: Auth plan\nstatus: draft\n---\n\n# Plan\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	if doc.FrontMatter == nil {
+		t.Fatal("expected FrontMatter on the rendered document")
+	}
+
+	found := false
+	for i, line := range doc.Lines {
+		if containsVisible(line, "status") {
+			found = true
+			if m := doc.Mappings[i]; m.SourceStart != 3 || m.SourceEnd != 3 {
+				t.Errorf("status key maps to %d-%d, want 3-3", m.SourceStart, m.SourceEnd)
+			}
+		}
+		if containsVisible(line, "─") {
+			t.Errorf("front matter rendered as a thematic break: %q", stripANSI(line))
+		}
+	}
+	if !found {
+		t.Error("expected the status key in the metadata panel")
+	}
+}
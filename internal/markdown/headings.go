@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// Heading is a heading of a document.
+type Heading struct {
+	Level int
+	Text  string // plain text, without markup
+	Line  int    // 1-indexed source line
+}
+
+// Headings returns the headings of a markdown document in order.
+func Headings(source []byte) []Heading {
+	parseSource := source
+	if fm := ParseFrontMatter(source); fm != nil {
+		parseSource = maskFrontMatter(source, fm)
+	}
+	doc := newGoldmark().Parser().Parse(text.NewReader(parseSource))
+	lines := newANSIRenderer(parseSource, 0) // for its source line lookups
+
+	var headings []Heading
+	ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		start, _ := lines.sourceLineRange(h)
+		var sb strings.Builder
+		plainText(&sb, h, parseSource)
+		headings = append(headings, Heading{Level: h.Level, Text: sb.String(), Line: start})
+		return ast.WalkSkipChildren, nil
+	})
+	return headings
+}
+
+// Breadcrumb returns the text of the headings enclosing a source line, from
+// the outermost in, such as ["Plan", "Phase 2", "Auth"].
+func Breadcrumb(headings []Heading, line int) []string {
+	var path []Heading
+	for _, h := range headings {
+		if h.Line >= line {
+			break
+		}
+		for len(path) > 0 && path[len(path)-1].Level >= h.Level {
+			path = path[:len(path)-1]
+		}
+		path = append(path, h)
+	}
+
+	crumbs := make([]string, len(path))
+	for i, h := range path {
+		crumbs[i] = h.Text
+	}
+	return crumbs
+}
+
+// plainText writes the text of inline nodes without their markup.
+func plainText(sb *strings.Builder, node ast.Node, source []byte) {
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *ast.Text:
+			sb.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				sb.WriteString(" ")
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		case *ast.AutoLink:
+			sb.Write(n.URL(source))
+		default:
+			plainText(sb, child, source)
+		}
+	}
+}
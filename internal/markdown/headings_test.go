@@ -0,0 +1,38 @@
+package markdown
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBreadcrumb(t *testing.T) {
+	source := []byte("---\ntitle: x\n---\n# Plan\n\n## Phase 1\n\ntext\n\n## Phase *2*\n\n### `Auth`\n\nLogin flow.\n\nSetext\n------\n\nmore\n")
+	headings := Headings(source)
+	if len(headings) != 5 {
+		t.Fatalf("got %d headings, want 5: %+v", len(headings), headings)
+	}
+
+	tests := []struct {
+		line int
+		want []string
+	}{
+		{1, nil},
+		{8, []string{"Plan", "Phase 1"}},
+		{10, []string{"Plan", "Phase 1"}}, // the heading line itself
+		{14, []string{"Plan", "Phase 2", "Auth"}},
+		{19, []string{"Plan", "Setext"}},
+	}
+	for _, tt := range tests {
+		if got := Breadcrumb(headings, tt.line); !slices.Equal(got, tt.want) {
+			t.Errorf("Breadcrumb(line %d) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestHeadingsParseAsRendered(t *testing.T) {
+	// Typographer substitutions apply as they do in the rendered view
+	headings := Headings([]byte("# Plan -- v2\n"))
+	if len(headings) != 1 || headings[0].Text != "Plan – v2" {
+		t.Errorf("Headings = %+v, want the rendered text %q", headings, "Plan – v2")
+	}
+}
@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// RenderHTML renders markdown source as an HTML fragment for the browser.
+// Every commentable block carries data-start and data-end attributes with
+// its 1-indexed source lines and an id of the form "L<n>" for its first
+// line. Code blocks and front matter are split into one element per source
+// line, each with its own anchor.
+func RenderHTML(source []byte) (string, error) {
+	frontMatter := ParseFrontMatter(source)
+	parseSource := source
+	if frontMatter != nil {
+		parseSource = maskFrontMatter(source, frontMatter)
+	}
+	lines := newANSIRenderer(parseSource, 0) // for its source line lookups
+
+	md := newGoldmark(
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(util.Prioritized(&codeLineRenderer{lines}, 100)),
+		),
+	)
+
+	doc := md.Parser().Parse(text.NewReader(parseSource))
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		setLineAttributes := func(node, lineNode ast.Node) {
+			start, end := lines.sourceLineRange(lineNode)
+			node.SetAttributeString("id", fmt.Sprintf("L%d", start))
+			node.SetAttributeString("data-start", fmt.Sprint(start))
+			node.SetAttributeString("data-end", fmt.Sprint(end))
+		}
+		switch node.(type) {
+		case *ast.Heading, *ast.Paragraph, *east.DefinitionTerm:
+			setLineAttributes(node, node)
+		case *ast.ListItem:
+			// Tight items; paragraphs of loose items and nested lists carry
+			// their own lines
+			if first, ok := node.FirstChild().(*ast.TextBlock); ok {
+				setLineAttributes(node, first)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if frontMatter != nil {
+		buf.WriteString(`<pre class="front-matter"><code>`)
+		sourceLines := strings.Split(string(source), "\n")
+		for n := frontMatter.SourceStart; n <= frontMatter.SourceEnd && n <= len(sourceLines); n++ {
+			writeLine(&buf, n, sourceLines[n-1])
+		}
+		buf.WriteString("</code></pre>\n")
+	}
+	if err := md.Renderer().Render(&buf, parseSource, doc); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writeLine writes one source line as an anchored, selectable element. Lines
+// are displayed as blocks, so no newline follows.
+func writeLine(w io.Writer, n int, line string) {
+	fmt.Fprintf(w, `<span class="line" id="L%d" data-start="%d" data-end="%d">%s</span>`,
+		n, n, n, html.EscapeString(line))
+}
+
+// codeLineRenderer renders code blocks with one anchored element per line,
+// so single lines of code can be selected.
+type codeLineRenderer struct {
+	lines *ansiRenderer
+}
+
+func (r *codeLineRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderCode)
+	reg.Register(ast.KindCodeBlock, r.renderCode)
+}
+
+func (r *codeLineRenderer) renderCode(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	w.WriteString("<pre><code")
+	if fenced, ok := node.(*ast.FencedCodeBlock); ok {
+		if lang := fenced.Language(source); lang != nil {
+			fmt.Fprintf(w, ` class="language-%s"`, html.EscapeString(string(lang)))
+		}
+	}
+	w.WriteString(">")
+
+	for i := 0; i < node.Lines().Len(); i++ {
+		seg := node.Lines().At(i)
+		writeLine(w, r.lines.byteOffsetToLine(seg.Start), strings.TrimSuffix(string(seg.Value(source)), "\n"))
+	}
+	w.WriteString("</code></pre>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,37 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML_LineAttributes(t *testing.T) {
+	source := []byte("---\ntitle: Plan\n---\n# Plan\n\nSome *text*\nover two lines.\n\n- one\n- two\n\n```go\nx := 1\n<y>\n```\n")
+	out, err := RenderHTML(source)
+	if err != nil {
+		t.Fatalf("RenderHTML failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`<span class="line" id="L2" data-start="2" data-end="2">title: Plan</span>`,
+		`<h1 id="L4" data-start="4" data-end="4">Plan</h1>`,
+		`<p id="L6" data-start="6" data-end="7">Some <em>text</em>`,
+		`<li id="L10" data-start="10" data-end="10">two</li>`,
+		`<code class="language-go">`,
+		`<span class="line" id="L14" data-start="14" data-end="14">&lt;y&gt;</span>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output should contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderHTML_LooseListItems(t *testing.T) {
+	out, err := RenderHTML([]byte("- one\n\n- two\n"))
+	if err != nil {
+		t.Fatalf("RenderHTML failed: %v", err)
+	}
+	if strings.Count(out, `id="L1"`) != 1 {
+		t.Errorf("line anchors should be unique, got:\n%s", out)
+	}
+}
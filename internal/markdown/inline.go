@@ -0,0 +1,245 @@
+package markdown
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/yuin/goldmark/text"
+)
+
+// inlineText is rendered text that remembers, for every byte written, the
+// source byte offset it came from (-1 for styling and synthetic text).
+type inlineText struct {
+	buf   strings.Builder
+	offs  []int
+	marks []mark
+}
+
+// mark tags a position in rendered text, such as a footnote reference, so
+// the rendered line it lands on can still be found after wrapping.
+type mark struct {
+	pos      int // byte position in the text
+	footnote int // footnote index referenced at pos
+}
+
+// addMark tags the current end of the text.
+func (t *inlineText) addMark(m mark) {
+	m.pos = t.buf.Len()
+	t.marks = append(t.marks, m)
+}
+
+// writeString appends styling or text that has no source counterpart.
+func (t *inlineText) writeString(s string) {
+	t.buf.WriteString(s)
+	for i := 0; i < len(s); i++ {
+		t.offs = append(t.offs, -1)
+	}
+}
+
+// writeSource appends source bytes starting at the given byte offset.
+func (t *inlineText) writeSource(b []byte, start int) {
+	t.buf.Write(b)
+	for i := range b {
+		t.offs = append(t.offs, start+i)
+	}
+}
+
+// writeSegment appends the bytes of a goldmark segment, including any
+// padding goldmark inserted for tab expansion.
+func (t *inlineText) writeSegment(seg text.Segment, source []byte) {
+	if seg.Padding > 0 {
+		t.writeString(strings.Repeat(" ", seg.Padding))
+	}
+	t.writeSource(source[seg.Start:seg.Stop], seg.Start)
+}
+
+// textLine is one line of rendered text with its per-byte source offsets.
+type textLine struct {
+	text  string
+	offs  []int
+	marks []mark
+}
+
+func (t *inlineText) line() textLine {
+	return textLine{text: t.buf.String(), offs: t.offs, marks: t.marks}
+}
+
+// slice returns the part of the line between byte positions start and end,
+// keeping the marks that fall inside it.
+func (l textLine) slice(start, end int) textLine {
+	sub := textLine{text: l.text[start:end], offs: l.offs[start:end]}
+	for _, m := range l.marks {
+		if m.pos >= start && (m.pos < end || (m.pos == end && end == len(l.text))) {
+			m.pos -= start
+			sub.marks = append(sub.marks, m)
+		}
+	}
+	return sub
+}
+
+// join returns a followed by b, separated by a space with the given offset.
+func join(a, b textLine, spaceOffset int) textLine {
+	joined := textLine{
+		text:  a.text + " " + b.text,
+		offs:  append(append(append([]int(nil), a.offs...), spaceOffset), b.offs...),
+		marks: append([]mark(nil), a.marks...),
+	}
+	for _, m := range b.marks {
+		m.pos += len(a.text) + 1
+		joined.marks = append(joined.marks, m)
+	}
+	return joined
+}
+
+// segments returns the source spans of the line's visible text, with columns
+// shifted right by col.
+func (l textLine) segments(col int) []Segment {
+	var segs []Segment
+	for i := 0; i < len(l.text); {
+		if l.text[i] == '\033' {
+			i = skipEscape(l.text, i)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(l.text[i:])
+		w := runewidth.RuneWidth(r)
+		if off := l.offs[i]; off >= 0 {
+			n := len(segs)
+			if n > 0 && segs[n-1].SourceEnd == off && segs[n-1].Col+segs[n-1].Width == col {
+				segs[n-1].SourceEnd += size
+				segs[n-1].Width += w
+			} else {
+				segs = append(segs, Segment{Col: col, Width: w, SourceStart: off, SourceEnd: off + size})
+			}
+		}
+		col += w
+		i += size
+	}
+	return segs
+}
+
+// skipEscape returns the index just past the ANSI escape sequence at i:
+// a control sequence, which ends at a letter, or an operating system
+// command such as a hyperlink, which ends at BEL or ESC \.
+func skipEscape(s string, i int) int {
+	if i+1 < len(s) && s[i+1] == ']' {
+		for j := i + 2; j < len(s); j++ {
+			if s[j] == '\a' {
+				return j + 1
+			}
+			if s[j] == '\033' && j+1 < len(s) && s[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return len(s)
+	}
+	for i++; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			return i + 1
+		}
+	}
+	return i
+}
+
+// wrapText wraps offset-tracked text to the given width, splitting on hard
+// line breaks first and then on whitespace between words.
+func (r *ansiRenderer) wrapText(l textLine, width int) []textLine {
+	if width <= 0 {
+		width = 40
+	}
+
+	var lines []textLine
+	start := 0
+	for i := 0; i <= len(l.text); i++ {
+		if i < len(l.text) && l.text[i] != '\n' {
+			continue
+		}
+		para := l.slice(start, i)
+		if para.text == "" {
+			lines = append(lines, textLine{})
+		} else {
+			lines = append(lines, wrapWords(para, width)...)
+		}
+		start = i + 1
+	}
+
+	if len(lines) == 0 {
+		lines = []textLine{{}}
+	}
+	if strings.Contains(l.text, "\033]8;") {
+		lines = continueLinks(lines, r.colors.link)
+	}
+	return lines
+}
+
+// wrapWords greedily packs whitespace-separated words into lines, joining
+// words on a line with a single space.
+func wrapWords(l textLine, width int) []textLine {
+	var lines []textLine
+	var current textLine
+	currentWidth := 0
+
+	for _, w := range splitWords(l) {
+		wordWidth := VisibleLen(w.text)
+
+		if currentWidth == 0 {
+			current = w
+			currentWidth = wordWidth
+		} else if currentWidth+1+wordWidth <= width {
+			current = join(current, w, joinOffset(current, w))
+			currentWidth += 1 + wordWidth
+		} else {
+			lines = append(lines, current)
+			current = w
+			currentWidth = wordWidth
+		}
+	}
+
+	if current.text != "" {
+		lines = append(lines, current)
+	}
+	if len(lines) == 0 {
+		lines = []textLine{{}}
+	}
+	return lines
+}
+
+// joinOffset returns the source offset for the space joining two words: the
+// single separator byte between them when they are adjacent in the source.
+func joinOffset(a, b textLine) int {
+	last, first := -1, -1
+	for i := len(a.offs) - 1; i >= 0 && last < 0; i-- {
+		last = a.offs[i]
+	}
+	for i := 0; i < len(b.offs) && first < 0; i++ {
+		first = b.offs[i]
+	}
+	if last >= 0 && first == last+2 {
+		return last + 1
+	}
+	return -1
+}
+
+// splitWords splits text on Unicode whitespace, keeping each word's offsets.
+func splitWords(l textLine) []textLine {
+	var words []textLine
+	start := -1
+	for i := 0; i < len(l.text); {
+		r, size := utf8.DecodeRuneInString(l.text[i:])
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, l.slice(start, i))
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+		i += size
+	}
+	if start >= 0 {
+		words = append(words, l.slice(start, len(l.text)))
+	}
+	return words
+}
@@ -0,0 +1,227 @@
+package markdown
+
+import (
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// SupportsHyperlinks guesses from the environment whether the terminal
+// shows OSC 8 hyperlinks, rather than printing them or ignoring them.
+func SupportsHyperlinks(getenv func(string) string) bool {
+	term := getenv("TERM")
+	switch {
+	case term == "dumb":
+		return false
+	case getenv("KITTY_WINDOW_ID") != "", getenv("WT_SESSION") != "", getenv("KONSOLE_VERSION") != "":
+		return true
+	}
+	switch getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby", "rio":
+		return true
+	}
+	if v, err := strconv.Atoi(getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	for _, name := range []string{"kitty", "alacritty", "foot", "wezterm", "ghostty"} {
+		if strings.Contains(term, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hyperlinkStart opens an OSC 8 hyperlink to target; hyperlinkEnd closes it.
+func hyperlinkStart(target string) string {
+	return "\033]8;;" + target + "\033\\"
+}
+
+const hyperlinkEnd = "\033]8;;\033\\"
+
+// LocalPath returns the file path of a link destination without a scheme,
+// such as "guide.md" or "../docs/setup.md#install", and false for URLs and
+// links within the document.
+func LocalPath(dest string) (string, bool) {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// linkURL returns the URL a link destination opens in a terminal: URLs as
+// written, and paths as file URLs resolved against the directory base. Links
+// within the document have none.
+func linkURL(dest, base string) string {
+	u, err := url.Parse(dest)
+	if err != nil {
+		return ""
+	}
+	if u.Scheme != "" {
+		return u.String()
+	}
+	path, ok := LocalPath(dest)
+	if !ok {
+		return ""
+	}
+	if !filepath.IsAbs(path) {
+		if path, err = filepath.Abs(filepath.Join(base, path)); err != nil {
+			return ""
+		}
+	}
+	file := url.URL{Scheme: "file", Path: filepath.ToSlash(path), Fragment: u.Fragment}
+	return file.String()
+}
+
+// writeLink writes a link's text as a hyperlink to dest, or, without
+// hyperlinks, followed by dest.
+func (r *ansiRenderer) writeLink(buf *inlineText, dest string, text func()) {
+	if !r.hyperlinks {
+		buf.writeString(underline + r.colors.link)
+		text()
+		buf.writeString(reset)
+		buf.writeString(r.colors.muted + " (" + dest + ")" + reset)
+		return
+	}
+
+	target := linkURL(dest, r.linkBase)
+	if target != "" {
+		buf.writeString(hyperlinkStart(target))
+	}
+	buf.writeString(underline + r.colors.link)
+	text()
+	buf.writeString(reset)
+	if target != "" {
+		buf.writeString(hyperlinkEnd)
+	}
+}
+
+// continueLinks closes a hyperlink left open at the end of a wrapped line
+// and opens it again on the next in the link color, so each line can be
+// drawn on its own.
+func continueLinks(lines []textLine, color string) []textLine {
+	open := ""
+	for i, l := range lines {
+		if open != "" {
+			l = l.enclose(open+underline+color, "")
+		}
+		open = openLink(l.text)
+		if open != "" {
+			l = l.enclose("", reset+hyperlinkEnd)
+		}
+		lines[i] = l
+	}
+	return lines
+}
+
+// openLink returns the sequence opening the hyperlink still open at the end
+// of text, or "" when none is.
+func openLink(text string) string {
+	open := ""
+	for i := 0; i < len(text); {
+		if !strings.HasPrefix(text[i:], "\033]8;") {
+			i++
+			continue
+		}
+		end := skipEscape(text, i)
+		if seq := text[i:end]; seq == hyperlinkEnd {
+			open = ""
+		} else {
+			open = seq
+		}
+		i = end
+	}
+	return open
+}
+
+// enclose returns the line between prefix and suffix, which have no source
+// counterpart.
+func (l textLine) enclose(prefix, suffix string) textLine {
+	offs := make([]int, 0, len(prefix)+len(l.offs)+len(suffix))
+	for range len(prefix) {
+		offs = append(offs, -1)
+	}
+	offs = append(offs, l.offs...)
+	for range len(suffix) {
+		offs = append(offs, -1)
+	}
+
+	out := textLine{text: prefix + l.text + suffix, offs: offs}
+	for _, m := range l.marks {
+		m.pos += len(prefix)
+		out.marks = append(out.marks, m)
+	}
+	return out
+}
+
+// Link is a link in the text of a document.
+type Link struct {
+	Destination string
+	Start, End  int // source byte range of the link text
+}
+
+// Links returns the links of the document with text, in source order.
+func (d *Document) Links() []Link {
+	var links []Link
+	for _, b := range d.blocks {
+		ast.Walk(b.node, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+			n, ok := node.(*ast.Link)
+			if !ok || !entering {
+				return ast.WalkContinue, nil
+			}
+			if start, end, ok := textRange(n); ok {
+				links = append(links, Link{Destination: string(n.Destination), Start: start, End: end})
+			}
+			return ast.WalkSkipChildren, nil
+		})
+	}
+	return links
+}
+
+// textRange returns the source byte range spanned by the text inside a node.
+func textRange(node ast.Node) (int, int, bool) {
+	start, end := -1, -1
+	ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := n.(*ast.Text); ok && entering {
+			if start < 0 || t.Segment.Start < start {
+				start = t.Segment.Start
+			}
+			end = max(end, t.Segment.Stop)
+		}
+		return ast.WalkContinue, nil
+	})
+	return start, end, start >= 0
+}
+
+// AnchorLine returns the source line of the heading with an ID, as a link
+// within the document such as "#rollout" names it. IDs are matched ignoring
+// case, preferring an exact match.
+func (d *Document) AnchorLine(id string) (int, bool) {
+	if id == "" {
+		return 0, false
+	}
+	lines := newANSIRenderer(d.parseSource, 0) // for its source line lookups
+	line, exact := 0, false
+	for _, b := range d.blocks {
+		ast.Walk(b.node, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+			h, ok := node.(*ast.Heading)
+			if !ok || !entering || exact {
+				return ast.WalkContinue, nil
+			}
+			attr, _ := h.AttributeString("id")
+			value, _ := attr.([]byte)
+			switch {
+			case string(value) == id:
+				line, _ = lines.sourceLineRange(h)
+				exact = true
+			case line == 0 && strings.EqualFold(string(value), id):
+				line, _ = lines.sourceLineRange(h)
+			}
+			return ast.WalkSkipChildren, nil
+		})
+	}
+	return line, line > 0
+}
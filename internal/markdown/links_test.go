@@ -0,0 +1,118 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestLinkURL(t *testing.T) {
+	tests := []struct {
+		dest, want string
+	}{
+		{"https://example.com/a?b=c", "https://example.com/a?b=c"},
+		{"mailto:me@example.com", "mailto:me@example.com"},
+		{"guide.md", "file:///docs/guide.md"},
+		{"../setup notes.md#install", "file:///setup%20notes.md#install"},
+		{"/etc/hosts", "file:///etc/hosts"},
+		{"#usage", ""},
+	}
+	for _, tt := range tests {
+		if got := linkURL(tt.dest, "/docs"); got != tt.want {
+			t.Errorf("linkURL(%q) = %q, want %q", tt.dest, got, tt.want)
+		}
+	}
+}
+
+func TestRenderHyperlinks(t *testing.T) {
+	t.Parallel()
+	d := Parse([]byte("See [the setup guide for new machines](setup.md) and <https://example.com> first.\n"))
+	d.SetHyperlinks(true)
+	d.SetLinkBase("/docs")
+	doc := d.Render(24)
+
+	var text []string
+	for i, line := range doc.Lines {
+		if w := VisibleLen(line); w > 24 {
+			t.Errorf("line %d is %d columns wide: %q", i, w, line)
+		}
+		if openLink(line) != "" {
+			t.Errorf("line %d leaves a hyperlink open: %q", i, line)
+		}
+		text = append(text, ansi.Strip(line))
+	}
+	if joined := strings.Join(text, " "); strings.Contains(joined, "(setup.md)") {
+		t.Errorf("hyperlinks should not repeat their destination: %q", joined)
+	}
+
+	opened := 0
+	for _, line := range doc.Lines {
+		opened += strings.Count(line, hyperlinkStart("file:///docs/setup.md"))
+	}
+	if opened < 2 {
+		t.Errorf("a link wrapped over lines should be opened on each, got %d: %q", opened, doc.Lines)
+	}
+	if !strings.Contains(strings.Join(doc.Lines, ""), hyperlinkStart("https://example.com")) {
+		t.Errorf("autolinks should be hyperlinks: %q", doc.Lines)
+	}
+}
+
+func TestRenderLinksWithoutHyperlinks(t *testing.T) {
+	t.Parallel()
+	doc := Parse([]byte("See [setup](setup.md).\n")).Render(80)
+	if got := ansi.Strip(doc.Lines[0]); got != "See setup (setup.md)." {
+		t.Errorf("got %q, want the destination after the text", got)
+	}
+	if strings.Contains(doc.Lines[0], "\033]8;") {
+		t.Errorf("no hyperlinks should be emitted: %q", doc.Lines[0])
+	}
+}
+
+func TestLinks(t *testing.T) {
+	source := []byte("Read [the *guide*](guide.md) and [ref].\n\n[ref]: other.md\n")
+	links := Parse(source).Links()
+	if len(links) != 2 {
+		t.Fatalf("got %d links, want 2: %+v", len(links), links)
+	}
+	if l := links[0]; l.Destination != "guide.md" || string(source[l.Start:l.End]) != "the *guide" {
+		t.Errorf("first link = %q over %q", l.Destination, source[l.Start:l.End])
+	}
+	if links[1].Destination != "other.md" {
+		t.Errorf("reference link destination = %q, want other.md", links[1].Destination)
+	}
+}
+
+func TestSupportsHyperlinks(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+	if !SupportsHyperlinks(env(map[string]string{"TERM_PROGRAM": "iTerm.app"})) {
+		t.Error("iTerm2 supports hyperlinks")
+	}
+	if !SupportsHyperlinks(env(map[string]string{"VTE_VERSION": "6800"})) {
+		t.Error("recent VTE terminals support hyperlinks")
+	}
+	if SupportsHyperlinks(env(map[string]string{"TERM_PROGRAM": "Apple_Terminal", "TERM": "xterm-256color"})) {
+		t.Error("Terminal.app doesn't support hyperlinks")
+	}
+}
+
+func TestAnchorLine(t *testing.T) {
+	d := Parse([]byte("# Plan\n\nSee [rollout](#rollout).\n\n> ## Rollout steps\n\n## Rollout\n\nText.\n"))
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{"plan", 1},
+		{"rollout-steps", 5},
+		{"rollout", 7},
+		{"Rollout", 7},
+		{"missing", 0},
+	}
+	for _, tt := range tests {
+		if got, _ := d.AnchorLine(tt.id); got != tt.want {
+			t.Errorf("AnchorLine(%q) = %d, want %d", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package markdowntest generates markdown documents for tests and benchmarks.
+package markdowntest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Large returns a generated document of at least n lines, made of sections
+// with a heading, inline styles, a link that wraps at eighty columns, a list
+// and a code block.
+func Large(n int) []byte {
+	var sb strings.Builder
+	for i, lines := 0, 0; lines < n; i++ {
+		fmt.Fprintf(&sb, "## Section %d\n\nSome *text* with `code` and a [link](https://example.com) "+
+			"that runs on long enough to wrap at eighty columns.\n\n- one\n- two\n\n```go\nfunc f() {}\n```\n\n", i)
+		lines += 12
+	}
+	return []byte(sb.String())
+}
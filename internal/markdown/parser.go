@@ -1,28 +1,26 @@
 package markdown
 
 import (
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/parser"
-	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/extension"
 )
 
 // ParseAndRender parses markdown source and renders it with ANSI styling,
 // tracking the mapping from rendered lines to source lines.
 func ParseAndRender(source []byte, width int) (*RenderedDocument, error) {
-	md := goldmark.New(
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-		),
-	)
-
-	reader := text.NewReader(source)
-	doc := md.Parser().Parse(reader)
-
-	renderer := newANSIRenderer(source, width)
-	renderer.render(doc)
+	return Parse(source).Render(width), nil
+}
 
-	return &RenderedDocument{
-		Lines:    renderer.lines,
-		Mappings: renderer.mappings,
-	}, nil
+// typographicSubstitutions replaces goldmark's HTML entities with the
+// characters themselves, which a terminal shows as well as a browser.
+var typographicSubstitutions = extension.TypographicSubstitutions{
+	extension.LeftSingleQuote:  []byte("‘"),
+	extension.RightSingleQuote: []byte("’"),
+	extension.LeftDoubleQuote:  []byte("“"),
+	extension.RightDoubleQuote: []byte("”"),
+	extension.EnDash:           []byte("–"),
+	extension.EmDash:           []byte("—"),
+	extension.Ellipsis:         []byte("…"),
+	extension.LeftAngleQuote:   []byte("«"),
+	extension.RightAngleQuote:  []byte("»"),
+	extension.Apostrophe:       []byte("’"),
 }
@@ -1,14 +1,17 @@
 package markdown
 
 import (
-	"bytes"
 	"fmt"
+	"html"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mattn/go-runewidth"
+	"github.com/paulbuckley/mdmu/internal/theme"
 	"github.com/yuin/goldmark/ast"
 	east "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
 )
 
 // ANSI escape codes for styling
@@ -18,26 +21,70 @@ const (
 	italic    = "\033[3m"
 	dim       = "\033[2m"
 	underline = "\033[4m"
+)
 
-	fgCyan    = "\033[36m"
-	fgYellow  = "\033[33m"
-	fgGreen   = "\033[32m"
-	fgMagenta = "\033[35m"
-	fgWhite   = "\033[37m"
-	fgGray    = "\033[90m"
+// palette holds the ANSI escape codes for a theme's colors.
+type palette struct {
+	heading1   string
+	heading2   string
+	heading3   string
+	heading4   string
+	inlineCode string
+	code       string
+	codeBg     string
+	link       string
+	muted      string
+}
 
-	bgDarkGray = "\033[48;5;236m"
-)
+// defaultPalette colors documents that haven't been given a theme.
+var defaultPalette = newPalette(theme.Dark())
+
+func newPalette(t *theme.Theme) palette {
+	return palette{
+		heading1:   theme.Fg(t.Heading1),
+		heading2:   theme.Fg(t.Heading2),
+		heading3:   theme.Fg(t.Heading3),
+		heading4:   theme.Fg(t.Heading4),
+		inlineCode: theme.Fg(t.InlineCode),
+		code:       theme.Fg(t.CodeFg),
+		codeBg:     theme.Bg(t.CodeBg),
+		link:       theme.Fg(t.Link),
+		muted:      theme.Fg(t.Muted),
+	}
+}
+
+// heading returns the style of headings of a level, in the rendered and
+// source views alike.
+func (p palette) heading(level int) string {
+	switch level {
+	case 1:
+		return p.heading1 + bold
+	case 2:
+		return p.heading2 + bold
+	case 3:
+		return p.heading3 + bold
+	case 4:
+		return p.heading4 + bold
+	}
+	return bold
+}
 
 type ansiRenderer struct {
 	source      []byte
 	width       int
+	hyperlinks  bool   // links render as OSC 8 terminal hyperlinks
+	linkBase    string // directory relative links are resolved against
+	colors      palette
 	lines       []string
 	mappings    []LineMapping
 	lineOffsets []int // byte offsets where each source line starts
 
 	// State for inline rendering
 	inlineStyles []string
+
+	// Footnote positions, by rendered line
+	footnoteRefs []FootnoteRef
+	footnoteDefs map[int]int
 }
 
 func newANSIRenderer(source []byte, width int) *ansiRenderer {
@@ -50,9 +97,55 @@ func newANSIRenderer(source []byte, width int) *ansiRenderer {
 	}
 
 	return &ansiRenderer{
-		source:      source,
-		width:       width,
-		lineOffsets: offsets,
+		source:       source,
+		width:        width,
+		colors:       defaultPalette,
+		lineOffsets:  offsets,
+		footnoteDefs: map[int]int{},
+	}
+}
+
+// subRenderer returns an empty renderer over the same source for rendering
+// nested blocks at a narrower width.
+func (r *ansiRenderer) subRenderer(width int) *ansiRenderer {
+	return &ansiRenderer{
+		source:       r.source,
+		width:        width,
+		hyperlinks:   r.hyperlinks,
+		linkBase:     r.linkBase,
+		colors:       r.colors,
+		lineOffsets:  r.lineOffsets,
+		footnoteDefs: map[int]int{},
+	}
+}
+
+// appendRendered appends the lines of a sub-renderer, the first behind
+// prefix and the rest behind continuation, shifting its mappings and
+// footnote positions to match.
+func (r *ansiRenderer) appendRendered(sub *ansiRenderer, prefix, continuation string) {
+	base := len(r.lines)
+	for i, line := range sub.lines {
+		p := continuation
+		if i == 0 {
+			p = prefix
+		}
+		if line == "" && strings.TrimSpace(p) == "" {
+			p = ""
+		}
+		shift := VisibleLen(p)
+		mapping := sub.mappings[i]
+		r.addLine(p+line, mapping.SourceStart, mapping.SourceEnd)
+		for _, seg := range mapping.Segments {
+			seg.Col += shift
+			r.mappings[len(r.mappings)-1].Segments = append(r.mappings[len(r.mappings)-1].Segments, seg)
+		}
+	}
+	for _, ref := range sub.footnoteRefs {
+		ref.RenderedLine += base
+		r.footnoteRefs = append(r.footnoteRefs, ref)
+	}
+	for index, line := range sub.footnoteDefs {
+		r.footnoteDefs[index] = line + base
 	}
 }
 
@@ -66,6 +159,18 @@ func (r *ansiRenderer) addLine(text string, sourceStart, sourceEnd int) {
 	})
 }
 
+// addTextLine appends a rendered line of offset-tracked text behind a
+// decorative prefix, recording the source spans of the text.
+func (r *ansiRenderer) addTextLine(prefix string, l textLine, sourceStart, sourceEnd int) {
+	r.addLine(prefix+l.text, sourceStart, sourceEnd)
+	r.mappings[len(r.mappings)-1].Segments = l.segments(VisibleLen(prefix))
+	for _, m := range l.marks {
+		if m.footnote > 0 {
+			r.footnoteRefs = append(r.footnoteRefs, FootnoteRef{Index: m.footnote, RenderedLine: len(r.lines) - 1})
+		}
+	}
+}
+
 func (r *ansiRenderer) addBlankLine(sourceStart, sourceEnd int) {
 	r.addLine("", sourceStart, sourceEnd)
 }
@@ -151,7 +256,7 @@ func (r *ansiRenderer) renderNode(node ast.Node, depth int) {
 
 	case *ast.ThematicBreak:
 		start, end := r.sourceLineRange(n)
-		r.addLine(fgGray+strings.Repeat("─", min(r.width, 40))+reset, start, end)
+		r.addLine(r.colors.muted+strings.Repeat("─", min(r.width, 40))+reset, start, end)
 		r.addBlankLine(start, end)
 
 	case *ast.Blockquote:
@@ -160,6 +265,12 @@ func (r *ansiRenderer) renderNode(node ast.Node, depth int) {
 	case *ast.HTMLBlock:
 		r.renderHTMLBlock(n)
 
+	case *east.FootnoteList:
+		r.renderFootnoteList(n, depth)
+
+	case *east.DefinitionList:
+		r.renderDefinitionList(n, depth)
+
 	default:
 		// For unknown block nodes, try rendering children
 		if node.HasChildren() {
@@ -176,29 +287,16 @@ func (r *ansiRenderer) renderChildren(node ast.Node, depth int) {
 
 func (r *ansiRenderer) renderHeading(node *ast.Heading) {
 	start, end := r.sourceLineRange(node)
-	text := r.renderInlineChildren(node)
 
-	var prefix string
-	var color string
-	switch node.Level {
-	case 1:
-		color = fgCyan + bold
-		prefix = "# "
-	case 2:
-		color = fgGreen + bold
-		prefix = "## "
-	case 3:
-		color = fgYellow + bold
-		prefix = "### "
-	case 4:
-		color = fgMagenta + bold
-		prefix = "#### "
-	default:
-		color = bold
-		prefix = strings.Repeat("#", node.Level) + " "
-	}
+	color := r.colors.heading(node.Level)
+	prefix := strings.Repeat("#", node.Level) + " "
+
+	var t inlineText
+	t.writeString(color + prefix)
+	r.renderInline(&t, node)
+	t.writeString(reset)
 
-	r.addLine(color+prefix+text+reset, start, end)
+	r.addTextLine("", t.line(), start, end)
 	r.addBlankLine(start, end)
 }
 
@@ -207,11 +305,45 @@ func (r *ansiRenderer) renderParagraph(node ast.Node, depth int) {
 	text := r.renderInlineChildren(node)
 
 	// Word wrap
-	wrapped := r.wordWrap(text, r.width-depth*2)
-	for _, line := range wrapped {
-		r.addLine(line, start, end)
+	wrapped := r.wrapText(text, r.width-depth*2)
+	r.addWrappedLines("", "", wrapped, start, end)
+	r.addBlankLine(end, end)
+}
+
+// addWrappedLines appends wrapped text lines, the first behind prefix and the
+// rest behind continuation. Each line maps to the source lines its text was
+// actually rendered from, falling back to the block's range for lines
+// without source text.
+func (r *ansiRenderer) addWrappedLines(prefix, continuation string, wrapped []textLine, start, end int) {
+	lastLine := start
+	for i, line := range wrapped {
+		p := continuation
+		if i == 0 {
+			p = prefix
+		}
+		r.addTextLine(p, line, start, end)
+
+		mapping := &r.mappings[len(r.mappings)-1]
+		if s, e, ok := r.segmentLineRange(mapping.Segments); ok {
+			mapping.SourceStart, mapping.SourceEnd = s, e
+			lastLine = e
+		} else if i > 0 {
+			mapping.SourceStart, mapping.SourceEnd = lastLine, lastLine
+		}
 	}
-	r.addBlankLine(start, end)
+}
+
+// segmentLineRange returns the 1-indexed source lines covered by segments.
+func (r *ansiRenderer) segmentLineRange(segs []Segment) (int, int, bool) {
+	if len(segs) == 0 {
+		return 0, 0, false
+	}
+	first, last := segs[0].SourceStart, segs[0].SourceEnd
+	for _, seg := range segs[1:] {
+		first = min(first, seg.SourceStart)
+		last = max(last, seg.SourceEnd)
+	}
+	return r.byteOffsetToLine(first), r.byteOffsetToLine(last - 1), true
 }
 
 func (r *ansiRenderer) renderFencedCodeBlock(node *ast.FencedCodeBlock) {
@@ -223,15 +355,13 @@ func (r *ansiRenderer) renderFencedCodeBlock(node *ast.FencedCodeBlock) {
 
 	// Header line
 	if lang != "" {
-		r.addLine(bgDarkGray+fgGray+" "+lang+" "+reset, start, start)
+		r.addLine(r.colors.codeBg+r.colors.muted+" "+lang+" "+reset, start, start)
 	}
 
 	// Render each line of the code block
 	for i := 0; i < node.Lines().Len(); i++ {
 		seg := node.Lines().At(i)
-		line := string(seg.Value(r.source))
-		line = strings.TrimRight(line, "\n")
-		r.addLine(bgDarkGray+fgWhite+" "+line+" "+reset, r.byteOffsetToLine(seg.Start), r.byteOffsetToLine(seg.Start))
+		r.addCodeLine(seg)
 	}
 
 	r.addBlankLine(end, end)
@@ -242,14 +372,31 @@ func (r *ansiRenderer) renderCodeBlock(node *ast.CodeBlock) {
 
 	for i := 0; i < node.Lines().Len(); i++ {
 		seg := node.Lines().At(i)
-		line := string(seg.Value(r.source))
-		line = strings.TrimRight(line, "\n")
-		r.addLine(bgDarkGray+fgWhite+" "+line+" "+reset, r.byteOffsetToLine(seg.Start), r.byteOffsetToLine(seg.Start))
+		r.addCodeLine(seg)
 	}
 
 	r.addBlankLine(end, end)
 }
 
+// addCodeLine renders one line of a code block on a shaded background.
+func (r *ansiRenderer) addCodeLine(seg text.Segment) {
+	r.addSourceLine(r.colors.codeBg+r.colors.code+" ", " ", seg)
+}
+
+// addSourceLine renders a verbatim source line between style strings.
+func (r *ansiRenderer) addSourceLine(before, after string, seg text.Segment) {
+	line := r.byteOffsetToLine(seg.Start)
+	for seg.Stop > seg.Start && r.source[seg.Stop-1] == '\n' {
+		seg.Stop--
+	}
+
+	var t inlineText
+	t.writeString(before)
+	t.writeSegment(seg, r.source)
+	t.writeString(after + reset)
+	r.addTextLine("", t.line(), line, line)
+}
+
 func (r *ansiRenderer) renderList(node *ast.List, depth int) {
 	itemNum := node.Start
 	if itemNum == 0 {
@@ -289,122 +436,170 @@ func (r *ansiRenderer) renderList(node *ast.List, depth int) {
 			}
 
 			indent := strings.Repeat("  ", depth)
+			continuation := indent + strings.Repeat(" ", len(prefix))
+			wrapped := r.wrapText(text, r.width-len(indent)-len(prefix))
 			if firstBlock {
-				wrapped := r.wordWrap(text, r.width-len(indent)-len(prefix))
-				for i, line := range wrapped {
-					if i == 0 {
-						r.addLine(indent+prefix+line, start, end)
-					} else {
-						r.addLine(indent+strings.Repeat(" ", len(prefix))+line, start, end)
-					}
-				}
-				firstBlock = false
+				r.addWrappedLines(indent+prefix, continuation, wrapped, start, end)
 			} else {
-				wrapped := r.wordWrap(text, r.width-len(indent)-len(prefix))
-				for _, line := range wrapped {
-					r.addLine(indent+strings.Repeat(" ", len(prefix))+line, start, end)
-				}
+				r.addWrappedLines(continuation, continuation, wrapped, start, end)
 			}
+			firstBlock = false
 		}
 	}
 
-	r.addBlankLine(r.sourceLineRange(node))
+	_, end := r.sourceLineRange(node)
+	r.addBlankLine(end, end)
 }
 
 func (r *ansiRenderer) renderBlockquote(node *ast.Blockquote, depth int) {
 	// Render children into a temporary renderer, then prefix each line
-	subRenderer := newANSIRenderer(r.source, r.width-4)
+	subRenderer := r.subRenderer(r.width - 4)
+	subRenderer.renderChildren(node, depth)
+
+	prefix := r.colors.muted + "│ " + reset
+	r.appendRendered(subRenderer, prefix, prefix)
+}
+
+// renderFootnoteList renders footnote definitions at the end of the
+// document, each numbered like its references.
+func (r *ansiRenderer) renderFootnoteList(node *east.FootnoteList, depth int) {
+	start, _ := r.sourceLineRange(node)
+	r.addLine(r.colors.muted+strings.Repeat("─", min(r.width, 20))+reset, start, start)
+	r.addBlankLine(start, start)
+
 	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
-		subRenderer.renderNode(child, depth)
+		footnote, ok := child.(*east.Footnote)
+		if !ok {
+			continue
+		}
+
+		label := fmt.Sprintf("[%d] ", footnote.Index)
+		subRenderer := r.subRenderer(r.width - len(label))
+		subRenderer.renderChildren(footnote, depth)
+
+		r.footnoteDefs[footnote.Index] = len(r.lines)
+		r.appendRendered(subRenderer, r.colors.link+label+reset, strings.Repeat(" ", len(label)))
 	}
+}
 
-	prefix := fgGray + "│ " + reset
-	for i, line := range subRenderer.lines {
-		mapping := subRenderer.mappings[i]
-		r.addLine(prefix+line, mapping.SourceStart, mapping.SourceEnd)
+// renderDefinitionList renders each term in bold with its descriptions
+// indented below it.
+func (r *ansiRenderer) renderDefinitionList(node *east.DefinitionList, depth int) {
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch n := child.(type) {
+		case *east.DefinitionTerm:
+			start, end := r.sourceLineRange(n)
+			var t inlineText
+			t.writeString(bold)
+			r.renderInline(&t, n)
+			t.writeString(reset)
+			r.addWrappedLines("", "", r.wrapText(t.line(), r.width-depth*2), start, end)
+
+		case *east.DefinitionDescription:
+			subRenderer := r.subRenderer(r.width - 4)
+			subRenderer.renderChildren(n, depth)
+
+			// Tight descriptions sit directly below their term
+			if n.IsTight {
+				for len(subRenderer.lines) > 1 && subRenderer.lines[len(subRenderer.lines)-1] == "" {
+					subRenderer.lines = subRenderer.lines[:len(subRenderer.lines)-1]
+					subRenderer.mappings = subRenderer.mappings[:len(subRenderer.mappings)-1]
+				}
+			}
+			r.appendRendered(subRenderer, r.colors.muted+"  : "+reset, "    ")
+		}
 	}
+
+	_, end := r.sourceLineRange(node)
+	r.addBlankLine(end, end)
 }
 
 func (r *ansiRenderer) renderHTMLBlock(node *ast.HTMLBlock) {
 	_, end := r.sourceLineRange(node)
 	for i := 0; i < node.Lines().Len(); i++ {
 		seg := node.Lines().At(i)
-		line := string(seg.Value(r.source))
-		line = strings.TrimRight(line, "\n")
-		r.addLine(fgGray+line+reset, r.byteOffsetToLine(seg.Start), r.byteOffsetToLine(seg.Start))
+		r.addSourceLine(r.colors.muted, "", seg)
 	}
 	r.addBlankLine(end, end)
 }
 
-// renderInlineChildren renders all inline children of a node to a string with ANSI codes.
-func (r *ansiRenderer) renderInlineChildren(node ast.Node) string {
-	var buf bytes.Buffer
-	r.renderInline(&buf, node)
-	return buf.String()
+// renderInlineChildren renders all inline children of a node to text with
+// ANSI codes, tracking the source offset of every rendered byte.
+func (r *ansiRenderer) renderInlineChildren(node ast.Node) textLine {
+	var t inlineText
+	r.renderInline(&t, node)
+	return t.line()
 }
 
-func (r *ansiRenderer) renderInline(buf *bytes.Buffer, node ast.Node) {
+func (r *ansiRenderer) renderInline(buf *inlineText, node ast.Node) {
 	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
 		switch n := child.(type) {
 		case *ast.Text:
-			buf.Write(n.Segment.Value(r.source))
+			buf.writeSegment(n.Segment, r.source)
 			if n.SoftLineBreak() {
-				buf.WriteByte(' ')
+				buf.writeString(" ")
 			}
 			if n.HardLineBreak() {
-				buf.WriteByte('\n')
+				buf.writeString("\n")
 			}
 
 		case *ast.String:
-			buf.Write(n.Value)
+			buf.writeString(html.UnescapeString(string(n.Value)))
 
 		case *ast.CodeSpan:
-			buf.WriteString(fgYellow)
-			buf.WriteString("`")
+			buf.writeString(r.colors.inlineCode)
+			buf.writeString("`")
 			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
 				if t, ok := c.(*ast.Text); ok {
-					buf.Write(t.Segment.Value(r.source))
+					buf.writeSegment(t.Segment, r.source)
 				}
 			}
-			buf.WriteString("`")
-			buf.WriteString(reset)
+			buf.writeString("`")
+			buf.writeString(reset)
 
 		case *ast.Emphasis:
 			if n.Level == 2 {
-				buf.WriteString(bold)
+				buf.writeString(bold)
 				r.renderInline(buf, n)
-				buf.WriteString(reset)
+				buf.writeString(reset)
 			} else {
-				buf.WriteString(italic)
+				buf.writeString(italic)
 				r.renderInline(buf, n)
-				buf.WriteString(reset)
+				buf.writeString(reset)
 			}
 
 		case *ast.Link:
-			buf.WriteString(underline + fgCyan)
-			r.renderInline(buf, n)
-			buf.WriteString(reset)
-			buf.WriteString(fgGray + " (" + string(n.Destination) + ")" + reset)
+			r.writeLink(buf, string(n.Destination), func() { r.renderInline(buf, n) })
 
 		case *ast.Image:
-			buf.WriteString(fgGray + "[img: ")
+			buf.writeString(r.colors.muted + "[img: ")
 			r.renderInline(buf, n)
-			buf.WriteString("]" + reset)
+			buf.writeString("]" + reset)
 
 		case *ast.AutoLink:
 			url := string(n.URL(r.source))
-			buf.WriteString(underline + fgCyan + url + reset)
+			if r.hyperlinks {
+				buf.writeString(hyperlinkStart(linkURL(url, "")) + underline + r.colors.link + url + reset + hyperlinkEnd)
+			} else {
+				buf.writeString(underline + r.colors.link + url + reset)
+			}
 
 		case *ast.RawHTML:
 			for i := 0; i < n.Segments.Len(); i++ {
-				seg := n.Segments.At(i)
-				buf.Write(seg.Value(r.source))
+				buf.writeSegment(n.Segments.At(i), r.source)
 			}
 
 		case *east.Strikethrough:
-			buf.WriteString(dim)
+			buf.writeString(dim)
 			r.renderInline(buf, n)
-			buf.WriteString(reset)
+			buf.writeString(reset)
+
+		case *east.FootnoteLink:
+			buf.addMark(mark{footnote: n.Index})
+			buf.writeString(r.colors.link + fmt.Sprintf("[%d]", n.Index) + reset)
+
+		case *east.FootnoteBacklink:
+			// References are reached with the footnote jump key instead
 
 		default:
 			// Unknown inline node — try rendering children
@@ -415,79 +610,18 @@ func (r *ansiRenderer) renderInline(buf *bytes.Buffer, node ast.Node) {
 	}
 }
 
-// wordWrap wraps text to the given width, respecting ANSI escape sequences.
-func (r *ansiRenderer) wordWrap(s string, width int) []string {
-	if width <= 0 {
-		width = 40
-	}
-
-	var lines []string
-	for _, paragraph := range strings.Split(s, "\n") {
-		if paragraph == "" {
-			lines = append(lines, "")
-			continue
-		}
-		wrapped := wrapLine(paragraph, width)
-		lines = append(lines, wrapped...)
-	}
-
-	if len(lines) == 0 {
-		lines = []string{""}
-	}
-	return lines
-}
-
-// wrapLine wraps a single line of text, accounting for ANSI escape codes.
-func wrapLine(s string, width int) []string {
-	words := strings.Fields(s)
-	if len(words) == 0 {
-		return []string{""}
-	}
-
-	var lines []string
-	currentLine := ""
-	currentWidth := 0
-
-	for _, word := range words {
-		wordWidth := VisibleLen(word)
-
-		if currentWidth == 0 {
-			currentLine = word
-			currentWidth = wordWidth
-		} else if currentWidth+1+wordWidth <= width {
-			currentLine += " " + word
-			currentWidth += 1 + wordWidth
-		} else {
-			lines = append(lines, currentLine)
-			currentLine = word
-			currentWidth = wordWidth
-		}
-	}
-
-	if currentLine != "" {
-		lines = append(lines, currentLine)
-	}
-
-	return lines
-}
-
 // VisibleLen returns the visible width of a string, ignoring ANSI escape sequences
 // and accounting for wide characters (CJK, emoji).
 func VisibleLen(s string) int {
 	width := 0
-	inEscape := false
-	for _, r := range s {
-		if inEscape {
-			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
-				inEscape = false
-			}
-			continue
-		}
-		if r == '\033' {
-			inEscape = true
+	for i := 0; i < len(s); {
+		if s[i] == '\033' {
+			i = skipEscape(s, i)
 			continue
 		}
+		r, size := utf8.DecodeRuneInString(s[i:])
 		width += runewidth.RuneWidth(r)
+		i += size
 	}
 	return width
 }
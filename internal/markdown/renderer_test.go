@@ -119,6 +119,7 @@ func TestVisibleLen(t *testing.T) {
 		{"hello", 5},
 		{"\033[1mhello\033[0m", 5},
 		{"\033[36m# Title\033[0m", 7},
+		{"\033]8;;https://example.com/a\033\\link\033]8;;\033\\", 4},
 		{"", 0},
 	}
 
@@ -154,3 +155,163 @@ func stripANSI(s string) string {
 	}
 	return result.String()
 }
+
+func TestParseAndRender_Segments(t *testing.T) {
+	source := []byte("Some **bold** text.\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	// Columns 5-9 hold "bold", which lives at bytes 7-11 in the source
+	start, end, ok := ColumnSpan(source, doc.Mappings[0], 5, 9)
+	if !ok {
+		t.Fatal("expected a source span for the rendered word")
+	}
+	if got := string(source[start:end]); got != "bold" {
+		t.Errorf("ColumnSpan = %q, want %q", got, "bold")
+	}
+}
+
+func TestParseAndRender_ListSegmentsSkipBullet(t *testing.T) {
+	source := []byte("- item one\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	segs := doc.Mappings[0].Segments
+	if len(segs) != 1 {
+		t.Fatalf("expected 1 segment, got %d", len(segs))
+	}
+	if segs[0].Col != 4 {
+		t.Errorf("segment column = %d, want 4 (after the bullet)", segs[0].Col)
+	}
+	if got := string(source[segs[0].SourceStart:segs[0].SourceEnd]); got != "item one" {
+		t.Errorf("segment source = %q, want %q", got, "item one")
+	}
+}
+
+func TestPosition(t *testing.T) {
+	source := []byte("ab\ncdef\n")
+	tests := []struct {
+		offset   int
+		wantLine int
+		wantCol  int
+	}{
+		{0, 1, 1},
+		{1, 1, 2},
+		{3, 2, 1},
+		{6, 2, 4},
+	}
+
+	for _, tt := range tests {
+		line, col := Position(source, tt.offset)
+		if line != tt.wantLine || col != tt.wantCol {
+			t.Errorf("Position(%d) = (%d, %d), want (%d, %d)", tt.offset, line, col, tt.wantLine, tt.wantCol)
+		}
+	}
+}
+
+func TestParseAndRender_WrappedParagraphMappings(t *testing.T) {
+	source := []byte("# Title\n\nalpha beta\ngamma delta\nepsilon zeta\n")
+	doc, err := ParseAndRender(source, 11)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	want := map[string][2]int{
+		"alpha beta":  {3, 3},
+		"gamma delta": {4, 4},
+		"epsilon":     {5, 5},
+		"zeta":        {5, 5},
+	}
+	for i, line := range doc.Lines {
+		r, ok := want[stripANSI(line)]
+		if !ok {
+			continue
+		}
+		m := doc.Mappings[i]
+		if m.SourceStart != r[0] || m.SourceEnd != r[1] {
+			t.Errorf("line %q maps to %d-%d, want %d-%d", stripANSI(line), m.SourceStart, m.SourceEnd, r[0], r[1])
+		}
+		delete(want, stripANSI(line))
+	}
+	for line := range want {
+		t.Errorf("expected rendered line %q", line)
+	}
+}
+
+func TestParseAndRender_WrapAcrossSoftBreak(t *testing.T) {
+	source := []byte("one two\nthree\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	// Both source lines join into one rendered line covering lines 1-2
+	if doc.Mappings[0].SourceStart != 1 || doc.Mappings[0].SourceEnd != 2 {
+		t.Errorf("joined line maps to %d-%d, want 1-2", doc.Mappings[0].SourceStart, doc.Mappings[0].SourceEnd)
+	}
+}
+
+func TestParseAndRender_Footnotes(t *testing.T) {
+	source := []byte("A claim[^src].\n\n[^src]: The source.\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	if len(doc.FootnoteRefs) != 1 {
+		t.Fatalf("expected 1 footnote reference, got %d", len(doc.FootnoteRefs))
+	}
+	ref := doc.FootnoteRefs[0]
+	if !containsVisible(doc.Lines[ref.RenderedLine], "A claim[1].") {
+		t.Errorf("reference line = %q, want it to contain %q", stripANSI(doc.Lines[ref.RenderedLine]), "A claim[1].")
+	}
+
+	def, ok := doc.FootnoteDefs[ref.Index]
+	if !ok {
+		t.Fatal("expected a rendered footnote definition")
+	}
+	if !containsVisible(doc.Lines[def], "[1] The source.") {
+		t.Errorf("definition line = %q, want it to contain %q", stripANSI(doc.Lines[def]), "[1] The source.")
+	}
+	if m := doc.Mappings[def]; m.SourceStart != 3 || m.SourceEnd != 3 {
+		t.Errorf("definition maps to %d-%d, want 3-3", m.SourceStart, m.SourceEnd)
+	}
+}
+
+func TestParseAndRender_DefinitionList(t *testing.T) {
+	source := []byte("Term\n: The definition.\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	if len(doc.Lines) < 2 {
+		t.Fatalf("expected at least 2 lines, got %d", len(doc.Lines))
+	}
+	if got := stripANSI(doc.Lines[0]); got != "Term" {
+		t.Errorf("term line = %q, want %q", got, "Term")
+	}
+	if got := stripANSI(doc.Lines[1]); got != "  : The definition." {
+		t.Errorf("description line = %q, want %q", got, "  : The definition.")
+	}
+	if m := doc.Mappings[1]; m.SourceStart != 2 {
+		t.Errorf("description maps to line %d, want 2", m.SourceStart)
+	}
+}
+
+func TestParseAndRender_Typographer(t *testing.T) {
+	source := []byte("\"Quoted\" text -- and more...\n")
+	doc, err := ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	want := "“Quoted” text – and more…"
+	if got := stripANSI(doc.Lines[0]); got != want {
+		t.Errorf("rendered = %q, want %q", got, want)
+	}
+}
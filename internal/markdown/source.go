@@ -0,0 +1,190 @@
+package markdown
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// sourceTabWidth is the tab stop used to show tabs in the source view.
+const sourceTabWidth = 4
+
+// Patterns for the light syntax coloring of the source view.
+var (
+	sourceHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]|$)`)
+	sourceFence   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	sourceRule    = regexp.MustCompile(`^ {0,3}(?:=+|-+|(?:\* *){3,}|(?:_ *){3,})[ \t]*$`)
+	sourceRefDef  = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:`)
+	sourceMarker  = regexp.MustCompile(`^[ \t]*(?:>[ \t]?)*[ \t]*(?:[-*+][ \t]+(?:\[[ xX]\][ \t]+)?|\d{1,9}[.)][ \t]+)?`)
+	sourceCode    = regexp.MustCompile("`[^`]*`")
+	sourceLink    = regexp.MustCompile(`!?\[[^\]]*\](?:\([^)]*\)|\[[^\]]*\])|\[\^[^\]]+\]|<[a-z][a-z0-9+.-]*:[^ >]*>`)
+	sourceComment = regexp.MustCompile(`<!--.*?-->`)
+)
+
+// sourceSpans are the inline patterns colored in the source view, later
+// ones over earlier ones.
+var sourceSpans = []struct {
+	re    *regexp.Regexp
+	style func(palette) string
+}{
+	{sourceLink, func(p palette) string { return p.link }},
+	{sourceComment, func(p palette) string { return p.muted }},
+	{sourceCode, func(p palette) string { return p.inlineCode }},
+}
+
+// RenderSource renders the document's markdown source as it is, with line
+// numbers and light syntax coloring, wrapping long lines at width. Mappings
+// cover every byte of the source, so comments can target exact syntax.
+func (d *Document) RenderSource(width int) *RenderedDocument {
+	wc := d.widthCache(width)
+	if wc.source == nil {
+		wc.source = renderSource(d.source, d.frontMatter, width, d.colors)
+	}
+	return wc.source
+}
+
+func renderSource(source []byte, fm *FrontMatter, width int, colors palette) *RenderedDocument {
+	r := newANSIRenderer(source, width)
+	lines := strings.Split(string(source), "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1] // the end of the last line, not a line
+	}
+
+	digits := len(fmt.Sprint(len(lines)))
+	blank := colors.muted + strings.Repeat(" ", digits) + " │ " + reset
+	textWidth := max(width-digits-3, 10)
+
+	var fence string // opening fence of the code block being read
+	for i, line := range lines {
+		n := i + 1
+		line = strings.TrimSuffix(line, "\r")
+		styles := make([]string, len(line))
+
+		switch {
+		case fm != nil && n >= fm.SourceStart && n <= fm.SourceEnd:
+			fill(styles, 0, len(line), colors.muted)
+		case fence != "":
+			if m := sourceFence.FindStringSubmatch(line); m != nil && m[1][0] == fence[0] &&
+				len(m[1]) >= len(fence) && strings.TrimSpace(line[len(m[0]):]) == "" {
+				fence = ""
+				fill(styles, 0, len(line), colors.muted)
+			} else {
+				fill(styles, 0, len(line), colors.code)
+			}
+		default:
+			fence = styleSourceLine(line, styles, colors)
+		}
+
+		gutter := colors.muted + fmt.Sprintf("%*d │ ", digits, n) + reset
+		r.addSourceRows(line, styles, r.lineOffsets[i], n, gutter, blank, textWidth)
+	}
+
+	return &RenderedDocument{
+		Lines:        r.lines,
+		Mappings:     r.mappings,
+		FrontMatter:  fm,
+		FootnoteDefs: map[int]int{},
+		colors:       colors,
+	}
+}
+
+// styleSourceLine sets the styles of a line outside code blocks, returning
+// the fence when the line opens a fenced code block.
+func styleSourceLine(line string, styles []string, colors palette) string {
+	if m := sourceFence.FindStringSubmatch(line); m != nil {
+		fill(styles, 0, len(line), colors.muted)
+		return m[1]
+	}
+	if m := sourceHeading.FindStringSubmatch(line); m != nil {
+		fill(styles, 0, len(line), colors.heading(len(m[1])))
+		return ""
+	}
+	if sourceRule.MatchString(line) {
+		fill(styles, 0, len(line), colors.muted)
+		return ""
+	}
+	if sourceRefDef.MatchString(line) {
+		fill(styles, 0, len(line), colors.link)
+		return ""
+	}
+
+	fill(styles, 0, len(sourceMarker.FindString(line)), colors.muted)
+	for i := range line {
+		if line[i] == '|' {
+			styles[i] = colors.muted
+		}
+	}
+	for _, span := range sourceSpans {
+		for _, loc := range span.re.FindAllStringIndex(line, -1) {
+			fill(styles, loc[0], loc[1], span.style(colors))
+		}
+	}
+	return ""
+}
+
+func fill(styles []string, start, end int, style string) {
+	for i := start; i < end; i++ {
+		styles[i] = style
+	}
+}
+
+// addSourceRows appends source line n, which starts at byte offset start,
+// wrapped into rows of textWidth columns behind its gutter. Each row maps
+// its columns to the bytes they show; tabs are expanded to spaces.
+func (r *ansiRenderer) addSourceRows(line string, styles []string, start, n int, gutter, blank string, textWidth int) {
+	prefix := VisibleLen(gutter)
+
+	var row strings.Builder
+	var segs []Segment
+	col, style := 0, ""
+	segStart, segCol := -1, 0
+
+	endSegment := func(i int) {
+		if segStart >= 0 {
+			segs = append(segs, Segment{Col: prefix + segCol, Width: col - segCol, SourceStart: start + segStart, SourceEnd: start + i})
+			segStart = -1
+		}
+	}
+	endRow := func(i int) {
+		endSegment(i)
+		if style != "" {
+			row.WriteString(reset)
+		}
+		r.addLine(gutter+row.String(), n, n)
+		r.mappings[len(r.mappings)-1].Segments = segs
+		row.Reset()
+		segs, col, style = nil, 0, ""
+		gutter = blank
+	}
+
+	for i, c := range line {
+		w, text := runewidth.RuneWidth(c), string(c)
+		if c == '\t' {
+			w = sourceTabWidth - col%sourceTabWidth
+			text = strings.Repeat(" ", w)
+		}
+		if col > 0 && col+w > textWidth {
+			endRow(i)
+		}
+		if styles[i] != style {
+			if style != "" {
+				row.WriteString(reset)
+			}
+			row.WriteString(styles[i])
+			style = styles[i]
+		}
+
+		if c == '\t' {
+			// A tab gets its own segment, since its width isn't that of its byte
+			endSegment(i)
+			segs = append(segs, Segment{Col: prefix + col, Width: w, SourceStart: start + i, SourceEnd: start + i + 1})
+		} else if segStart < 0 {
+			segStart, segCol = i, col
+		}
+		row.WriteString(text)
+		col += w
+	}
+	endRow(len(line))
+}
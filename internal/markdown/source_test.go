@@ -0,0 +1,60 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestRenderSource(t *testing.T) {
+	source := []byte("# Title\n\n| a | b |\n\tindented\n")
+	doc := Parse(source).RenderSource(40)
+
+	want := []string{"1 │ # Title", "2 │ ", "3 │ | a | b |", "4 │     indented"}
+	if len(doc.Lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %q", len(doc.Lines), len(want), doc.Lines)
+	}
+	for i, line := range doc.Lines {
+		if got := ansi.Strip(line); got != want[i] {
+			t.Errorf("line %d = %q, want %q", i, got, want[i])
+		}
+		if m := doc.Mappings[i]; m.SourceStart != i+1 || m.SourceEnd != i+1 {
+			t.Errorf("line %d maps to %d-%d, want %d", i, m.SourceStart, m.SourceEnd, i+1)
+		}
+	}
+
+	// Columns map to the exact syntax, pipes included
+	start, end, ok := ColumnSpan(source, doc.Mappings[2], 4, 9)
+	if got := string(source[start:end]); !ok || got != "| a |" {
+		t.Errorf("columns 4-9 of the table row = %q, want %q", got, "| a |")
+	}
+	start, end, ok = ColumnSpan(source, doc.Mappings[3], 8, 16)
+	if got := string(source[start:end]); !ok || got != "indented" {
+		t.Errorf("columns after the tab = %q, want %q", got, "indented")
+	}
+}
+
+func TestRenderSourceWraps(t *testing.T) {
+	source := []byte(strings.Repeat("word ", 10) + "\n")
+	doc := Parse(source).RenderSource(24)
+
+	if len(doc.Lines) != 3 {
+		t.Fatalf("got %d rows, want 3: %q", len(doc.Lines), doc.Lines)
+	}
+	if got := ansi.Strip(doc.Lines[1]); !strings.HasPrefix(got, "  │ ") {
+		t.Errorf("continuation row = %q, want a blank line number", got)
+	}
+	var covered int
+	for _, m := range doc.Mappings {
+		if m.SourceStart != 1 {
+			t.Errorf("row maps to line %d, want 1", m.SourceStart)
+		}
+		for _, seg := range m.Segments {
+			covered += seg.SourceEnd - seg.SourceStart
+		}
+	}
+	if covered != len(source)-1 {
+		t.Errorf("rows cover %d bytes, want the whole line of %d", covered, len(source)-1)
+	}
+}
@@ -0,0 +1,70 @@
+package markdown
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ColumnSpan returns the source byte range [start, end) rendered in the
+// visible columns [startCol, endCol) of a line. ok is false when no source
+// text is rendered in those columns.
+func ColumnSpan(source []byte, m LineMapping, startCol, endCol int) (start, end int, ok bool) {
+	for _, seg := range m.Segments {
+		if seg.Col+seg.Width <= startCol || seg.Col >= endCol {
+			continue
+		}
+		s := segmentOffset(source, seg, startCol)
+		e := segmentOffset(source, seg, endCol)
+		if s >= e {
+			continue
+		}
+		if !ok || s < start {
+			start = s
+		}
+		if !ok || e > end {
+			end = e
+		}
+		ok = true
+	}
+	return start, end, ok
+}
+
+// segmentOffset returns the source offset of the given visible column,
+// clamped to the segment.
+func segmentOffset(source []byte, seg Segment, col int) int {
+	off := seg.SourceStart
+	c := seg.Col
+	for off < seg.SourceEnd && c < col {
+		r, size := utf8.DecodeRune(source[off:])
+		c += runewidth.RuneWidth(r)
+		off += size
+	}
+	return off
+}
+
+// Position converts a byte offset into a 1-indexed source line and a
+// 1-indexed byte column within that line.
+func Position(source []byte, offset int) (line, col int) {
+	if offset > len(source) {
+		offset = len(source)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	line = bytes.Count(source[:offset], []byte("\n")) + 1
+	lineStart := bytes.LastIndexByte(source[:offset], '\n') + 1
+	return line, offset - lineStart + 1
+}
+
+// OffsetColumn returns the visible column at which a line shows the source
+// byte at offset. ok is false when the line doesn't show it.
+func OffsetColumn(source []byte, m LineMapping, offset int) (col int, ok bool) {
+	for _, seg := range m.Segments {
+		if offset >= seg.SourceStart && offset < seg.SourceEnd {
+			return seg.Col + runewidth.StringWidth(string(source[seg.SourceStart:offset])), true
+		}
+	}
+	return 0, false
+}
@@ -1,14 +1,36 @@
 package markdown
 
+// Segment maps a run of visible columns in a rendered line to the contiguous
+// source bytes they were rendered from.
+type Segment struct {
+	Col         int // 0-indexed visible column in the rendered line
+	Width       int // visible width of the run
+	SourceStart int // byte offset in source (inclusive)
+	SourceEnd   int // byte offset in source (exclusive)
+}
+
 // LineMapping maps a rendered line to its source file line range.
 type LineMapping struct {
-	RenderedLine int // 0-indexed line in rendered output
-	SourceStart  int // 1-indexed line in source file
-	SourceEnd    int // 1-indexed line in source file
+	RenderedLine int       // 0-indexed line in rendered output
+	SourceStart  int       // 1-indexed line in source file
+	SourceEnd    int       // 1-indexed line in source file
+	Segments     []Segment // source spans of the rendered text; empty for decoration
 }
 
 // RenderedDocument holds the rendered output and its line mappings.
 type RenderedDocument struct {
-	Lines    []string      // rendered lines (with ANSI codes)
-	Mappings []LineMapping // one per rendered line
+	Lines       []string      // rendered lines (with ANSI codes)
+	Mappings    []LineMapping // one per rendered line
+	FrontMatter *FrontMatter  // nil when the document has none
+
+	FootnoteRefs []FootnoteRef // footnote references in document order
+	FootnoteDefs map[int]int   // footnote index -> first rendered line of its definition
+
+	colors palette // what it was rendered with, for Fold's notes
+}
+
+// FootnoteRef is a footnote reference in the rendered output.
+type FootnoteRef struct {
+	Index        int // footnote number, shared with its definition
+	RenderedLine int
 }
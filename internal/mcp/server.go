@@ -0,0 +1,181 @@
+// Package mcp serves review comments to coding agents over the Model Context
+// Protocol: JSON-RPC 2.0 messages, one per line, on stdin and stdout.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/paulbuckley/mdmu/internal/output"
+)
+
+// ProtocolVersion is the newest protocol revision the server speaks.
+const ProtocolVersion = "2025-06-18"
+
+// supportedVersions are the protocol revisions a client may negotiate.
+var supportedVersions = []string{"2024-11-05", "2025-03-26", ProtocolVersion}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+
+	codeResourceNotFound = -32002
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// Server answers MCP requests about the comments on markdown documents.
+type Server struct {
+	Version string   // reported to clients as the server version
+	Files   []string // documents offered as resources
+
+	// Settings returns the settings for a document, as configured for its
+	// directory. Without it the defaults are used.
+	Settings func(doc string) (Settings, error)
+}
+
+// Settings are the configured settings that apply to a document.
+type Settings struct {
+	StoreDir string         // persistence directory, as in the config file
+	Output   output.Options // how reviews are formatted
+}
+
+// Serve handles requests from r until it is closed, writing responses to w.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	enc := json.NewEncoder(w)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if resp := s.handle(line); resp != nil {
+			if err := enc.Encode(resp); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// handle answers one message. Notifications get no response.
+func (s *Server) handle(line []byte) *response {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"),
+			Error: &rpcError{codeParseError, "parse error: " + err.Error()}}
+	}
+	if req.ID == nil {
+		return nil
+	}
+
+	resp := &response{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{codeInvalidRequest, "invalid request"}
+		return resp
+	}
+
+	result, err := s.dispatch(req.Method, req.Params)
+	if err != nil {
+		rpcErr, ok := err.(*rpcError)
+		if !ok {
+			rpcErr = &rpcError{codeInvalidParams, err.Error()}
+		}
+		resp.Error = rpcErr
+		return resp
+	}
+	resp.Result = result
+	return resp
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, p.ProtocolVersion) {
+			version = p.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities": map[string]any{
+				"tools":     map[string]any{},
+				"resources": map[string]any{},
+			},
+			"serverInfo": map[string]any{"name": "mdmu", "version": s.Version},
+			"instructions": "Review comments left with mdmu on markdown documents. " +
+				"Fetch the review, address each comment, then reply to or resolve it.",
+		}, nil
+
+	case "ping":
+		return map[string]any{}, nil
+
+	case "tools/list":
+		return map[string]any{"tools": tools}, nil
+
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.callTool(p.Name, p.Arguments)
+
+	case "resources/list":
+		return map[string]any{"resources": s.resources()}, nil
+
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		return s.readResource(p.URI)
+	}
+	return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method not found: %s", method)}
+}
+
+func decodeParams(params json.RawMessage, v any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{codeInvalidParams, "invalid params: " + err.Error()}
+	}
+	return nil
+}
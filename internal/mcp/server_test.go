@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulbuckley/mdmu/internal/output"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// session sends requests to a server and returns its responses by ID.
+func session(t *testing.T, s *Server, requests ...string) map[int]response {
+	t.Helper()
+	var out bytes.Buffer
+	if err := s.Serve(strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatalf("Serve failed: %v", err)
+	}
+
+	responses := map[int]response{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var resp response
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		var id int
+		json.Unmarshal(resp.ID, &id)
+		responses[id] = resp
+	}
+	return responses
+}
+
+// resultText returns the text content of a tool call result.
+func resultText(t *testing.T, resp response) (string, bool) {
+	t.Helper()
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %v", resp.Error)
+	}
+	data, _ := json.Marshal(resp.Result)
+	var result struct {
+		Content []struct{ Text string } `json:"content"`
+		IsError bool                    `json:"isError"`
+	}
+	json.Unmarshal(data, &result)
+	if len(result.Content) == 0 {
+		t.Fatalf("no content in %s", data)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func setup(t *testing.T) (*Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "plan.md")
+	if err := os.WriteFile(doc, []byte("# Plan\n\nUse sessions.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	state := filepath.Join(dir, "state")
+	s := &Server{Version: "test", Files: []string{doc}, Settings: func(string) (Settings, error) {
+		return Settings{StoreDir: state, Output: output.DefaultOptions()}, nil
+	}}
+	cf := &store.CommentFile{Comments: []store.Comment{
+		{ID: "c1", SourceStart: 3, SourceEnd: 3, SelectedText: "Use sessions.", Comment: "Why not tokens?"},
+	}}
+	if err := store.Save(store.PathFor(doc, state), cf); err != nil {
+		t.Fatal(err)
+	}
+	return s, doc
+}
+
+func TestInitializeAndList(t *testing.T) {
+	s, _ := setup(t)
+	responses := session(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"nope"}`,
+	)
+
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3 (none for the notification)", len(responses))
+	}
+	init := responses[1].Result.(map[string]any)
+	if init["protocolVersion"] != "2025-03-26" {
+		t.Errorf("protocolVersion = %v, want the client's version", init["protocolVersion"])
+	}
+	tools := responses[2].Result.(map[string]any)["tools"].([]any)
+	if len(tools) != 4 {
+		t.Errorf("got %d tools, want 4", len(tools))
+	}
+	if responses[3].Error == nil || responses[3].Error.Code != codeMethodNotFound {
+		t.Errorf("unknown method should fail with method not found, got %+v", responses[3])
+	}
+}
+
+func TestReviewTools(t *testing.T) {
+	s, doc := setup(t)
+	responses := session(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"get_review","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"reply_to_comment","arguments":{"id":"c1","body":"Tokens expire","resolve":true}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"list_comments","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"resolve_comment","arguments":{"id":"zzz"}}}`,
+	)
+
+	if text, _ := resultText(t, responses[1]); !strings.Contains(text, "**Comment:** Why not tokens?") {
+		t.Errorf("get_review = %q, want the formatted comment", text)
+	}
+	if text, isErr := resultText(t, responses[2]); isErr || !strings.Contains(text, "resolved") {
+		t.Errorf("reply_to_comment = %q (error %v)", text, isErr)
+	}
+	if text, _ := resultText(t, responses[3]); strings.Contains(text, "c1") {
+		t.Errorf("list_comments should leave out resolved comments, got %s", text)
+	}
+	if _, isErr := resultText(t, responses[4]); !isErr {
+		t.Error("resolving an unknown comment should report a tool error")
+	}
+
+	path, err := s.storePath(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := store.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cf.Comments[0]
+	if !c.Resolved() || len(c.Replies) != 1 || c.Replies[0].Body != "Tokens expire" {
+		t.Errorf("stored comment = %+v, want it resolved with the reply", c)
+	}
+}
+
+func TestReviewUsesSettings(t *testing.T) {
+	s, doc := setup(t)
+	settings := s.Settings
+	var asked string
+	s.Settings = func(doc string) (Settings, error) {
+		asked = doc
+		set, err := settings(doc)
+		set.Output.Prompt = "Comments on {{.Filename}}:"
+		return set, err
+	}
+	responses := session(t, s,
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"get_review","arguments":{}}}`,
+	)
+
+	if asked != doc {
+		t.Errorf("settings were asked for %q, want %q", asked, doc)
+	}
+	if text, _ := resultText(t, responses[1]); !strings.HasPrefix(text, "Comments on plan.md:") {
+		t.Errorf("get_review = %q, want the configured prompt", text)
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	s, doc := setup(t)
+	req, _ := json.Marshal(map[string]any{
+		"jsonrpc": "2.0", "id": 1, "method": "resources/read",
+		"params": map[string]any{"uri": resourceURI(doc)},
+	})
+	responses := session(t, s, string(req))
+
+	data, _ := json.Marshal(responses[1].Result)
+	if !strings.Contains(string(data), "Why not tokens?") {
+		t.Errorf("resources/read = %s, want the review", data)
+	}
+}
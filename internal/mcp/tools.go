@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/output"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// tool describes a tool to clients.
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+}
+
+// schema builds an object schema from property descriptions. Properties
+// named in required must be given.
+func schema(props map[string]map[string]any, required ...string) map[string]any {
+	s := map[string]any{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var (
+	fileProp = map[string]any{
+		"type":        "string",
+		"description": "Path of the markdown document. May be omitted when the server was started with a single file.",
+	}
+	idProp = map[string]any{"type": "string", "description": "ID of the comment, from list_comments."}
+)
+
+var tools = []tool{
+	{
+		Name:        "list_comments",
+		Description: "List the review comments on a markdown document, with the source lines and text each one refers to. Resolved comments are left out unless include_resolved is set.",
+		InputSchema: schema(map[string]map[string]any{
+			"file":             fileProp,
+			"include_resolved": {"type": "boolean", "description": "Also list resolved comments."},
+		}),
+	},
+	{
+		Name:        "get_review",
+		Description: "Get the open review comments on a markdown document formatted as markdown instructions, quoting the text each comment refers to.",
+		InputSchema: schema(map[string]map[string]any{"file": fileProp}),
+	},
+	{
+		Name:        "resolve_comment",
+		Description: "Mark a review comment as resolved once it has been addressed.",
+		InputSchema: schema(map[string]map[string]any{"file": fileProp, "id": idProp}, "id"),
+	},
+	{
+		Name:        "reply_to_comment",
+		Description: "Reply to a review comment, for example to explain a change or ask a question. Set resolve to also mark it resolved.",
+		InputSchema: schema(map[string]map[string]any{
+			"file":    fileProp,
+			"id":      idProp,
+			"body":    {"type": "string", "description": "Text of the reply."},
+			"resolve": {"type": "boolean", "description": "Also mark the comment resolved."},
+		}, "id", "body"),
+	},
+}
+
+type toolArgs struct {
+	File            string `json:"file"`
+	ID              string `json:"id"`
+	Body            string `json:"body"`
+	Resolve         bool   `json:"resolve"`
+	IncludeResolved bool   `json:"include_resolved"`
+}
+
+// callTool runs a tool. Failures of the tool itself are reported in the
+// result, so the agent can see and correct them.
+func (s *Server) callTool(name string, raw json.RawMessage) (any, error) {
+	var args toolArgs
+	if err := decodeParams(raw, &args); err != nil {
+		return nil, err
+	}
+
+	var text string
+	var err error
+	switch name {
+	case "list_comments":
+		text, err = s.listComments(args)
+	case "get_review":
+		text, err = s.review(args.File)
+	case "resolve_comment":
+		text, err = s.updateComment(args, func(c *store.Comment) string {
+			c.Status = store.StatusResolved
+			return "Resolved comment " + c.ID
+		})
+	case "reply_to_comment":
+		if strings.TrimSpace(args.Body) == "" {
+			err = errors.New("body must not be empty")
+			break
+		}
+		text, err = s.updateComment(args, func(c *store.Comment) string {
+			c.Replies = append(c.Replies, store.Reply{Author: "agent", Body: args.Body, CreatedAt: time.Now()})
+			if args.Resolve {
+				c.Status = store.StatusResolved
+				return "Replied to and resolved comment " + c.ID
+			}
+			return "Replied to comment " + c.ID
+		})
+	default:
+		return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool: %s", name)}
+	}
+
+	if err != nil {
+		return toolResult(err.Error(), true), nil
+	}
+	return toolResult(text, false), nil
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]any{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// document resolves the file argument to an absolute path.
+func (s *Server) document(file string) (string, error) {
+	if file == "" {
+		if len(s.Files) != 1 {
+			return "", errors.New("file is required")
+		}
+		file = s.Files[0]
+	}
+	return filepath.Abs(file)
+}
+
+// settings returns the settings for a document.
+func (s *Server) settings(doc string) (Settings, error) {
+	if s.Settings == nil {
+		return Settings{Output: output.DefaultOptions()}, nil
+	}
+	return s.Settings(doc)
+}
+
+// storePath returns the comment file for a document.
+func (s *Server) storePath(doc string) (string, error) {
+	settings, err := s.settings(doc)
+	if err != nil {
+		return "", err
+	}
+	return store.PathFor(doc, settings.StoreDir), nil
+}
+
+func (s *Server) listComments(args toolArgs) (string, error) {
+	doc, err := s.document(args.File)
+	if err != nil {
+		return "", err
+	}
+	path, err := s.storePath(doc)
+	if err != nil {
+		return "", err
+	}
+	cf, err := store.Load(path)
+	if err != nil {
+		return "", err
+	}
+
+	comments := []store.Comment{}
+	for _, c := range cf.Comments {
+		if args.IncludeResolved || !c.Resolved() {
+			comments = append(comments, c)
+		}
+	}
+	data, err := json.MarshalIndent(map[string]any{"file": doc, "comments": comments}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// review formats the open comments on a document as the TUI would copy them.
+func (s *Server) review(file string) (string, error) {
+	doc, err := s.document(file)
+	if err != nil {
+		return "", err
+	}
+	source, err := os.ReadFile(doc)
+	if err != nil {
+		return "", fmt.Errorf("reading document: %w", err)
+	}
+	settings, err := s.settings(doc)
+	if err != nil {
+		return "", err
+	}
+	cf, err := store.Load(store.PathFor(doc, settings.StoreDir))
+	if err != nil {
+		return "", err
+	}
+
+	opts := settings.Output
+	if fm := markdown.ParseFrontMatter(source); fm != nil {
+		opts.Metadata = fm.Data
+	}
+	review := output.FormatWith(cf, source, filepath.Base(doc), opts)
+	if review == "" {
+		return "There are no open comments on " + filepath.Base(doc) + ".", nil
+	}
+	return review, nil
+}
+
+// updateComment applies change to one comment in the store file and returns
+// the message it produces.
+func (s *Server) updateComment(args toolArgs, change func(c *store.Comment) string) (string, error) {
+	doc, err := s.document(args.File)
+	if err != nil {
+		return "", err
+	}
+
+	path, err := s.storePath(doc)
+	if err != nil {
+		return "", err
+	}
+
+	var msg string
+	_, err = store.Update(path, func(cf *store.CommentFile) error {
+		c, ok := cf.Find(args.ID)
+		if !ok {
+			return fmt.Errorf("no comment with id %q on %s", args.ID, filepath.Base(doc))
+		}
+		msg = change(c)
+		return nil
+	})
+	return msg, err
+}
+
+// resourceURI names the review of a document as a resource.
+func resourceURI(doc string) string {
+	return "mdmu://review" + (&url.URL{Path: filepath.ToSlash(doc)}).EscapedPath()
+}
+
+func (s *Server) resources() []map[string]any {
+	resources := []map[string]any{}
+	for _, file := range s.Files {
+		doc, err := filepath.Abs(file)
+		if err != nil {
+			continue
+		}
+		resources = append(resources, map[string]any{
+			"uri":         resourceURI(doc),
+			"name":        "Review of " + filepath.Base(doc),
+			"description": "Open review comments on " + doc,
+			"mimeType":    "text/markdown",
+		})
+	}
+	return resources
+}
+
+func (s *Server) readResource(uri string) (any, error) {
+	for _, file := range s.Files {
+		doc, err := filepath.Abs(file)
+		if err != nil || resourceURI(doc) != uri {
+			continue
+		}
+		review, err := s.review(doc)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"contents": []map[string]any{{"uri": uri, "mimeType": "text/markdown", "text": review}},
+		}, nil
+	}
+	return nil, &rpcError{codeResourceNotFound, "resource not found: " + uri}
+}
@@ -0,0 +1,187 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// Diagnostic formats, for showing comments in editors.
+const (
+	FormatQuickfix = "quickfix" // vim quickfix lines: file:line:col: text
+	FormatLSP      = "lsp"      // LSP publishDiagnostics parameters
+	FormatSARIF    = "sarif"    // SARIF 2.1.0 log
+)
+
+// DiagnosticFormats lists the diagnostic formats.
+var DiagnosticFormats = []string{FormatQuickfix, FormatLSP, FormatSARIF}
+
+// Diagnostics renders the open comments on the document at path in a
+// diagnostic format. The path is written as given, except that LSP needs
+// an absolute file URI.
+func Diagnostics(format string, cf *store.CommentFile, source []byte, path string) (string, error) {
+	switch format {
+	case FormatQuickfix:
+		return Quickfix(cf, path), nil
+	case FormatLSP:
+		return LSP(cf, source, path)
+	case FormatSARIF:
+		return SARIF(cf, source, path)
+	}
+	return "", fmt.Errorf("unknown diagnostic format %q (available: %s)", format, strings.Join(DiagnosticFormats, ", "))
+}
+
+// Quickfix renders comments as lines vim's default errorformat reads, one
+// per comment, with line breaks in comments replaced by spaces.
+func Quickfix(cf *store.CommentFile, path string) string {
+	var sb strings.Builder
+	for _, c := range openComments(cf) {
+		col := max(c.StartCol, 1)
+		fmt.Fprintf(&sb, "%s:%d:%d: %s\n", path, c.SourceStart, col, strings.Join(strings.Fields(c.Comment), " "))
+	}
+	return sb.String()
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`      // 0-indexed
+	Character int `json:"character"` // UTF-16 code units
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+// lspInformation is the severity comments are reported with.
+const lspInformation = 3
+
+// LSP renders comments as the parameters of a textDocument/publishDiagnostics
+// notification.
+func LSP(cf *store.CommentFile, source []byte, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(string(source), "\n")
+	diagnostics := []lspDiagnostic{}
+	for _, c := range openComments(cf) {
+		r := columnRange(c, lines)
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range: lspRange{
+				Start: lspPosition{c.SourceStart - 1, r.startCol},
+				End:   lspPosition{c.SourceEnd - 1, r.endCol},
+			},
+			Severity: lspInformation,
+			Code:     c.ID,
+			Source:   "mdmu",
+			Message:  c.Comment,
+		})
+	}
+
+	uri := url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}
+	return marshal(map[string]any{"uri": uri.String(), "diagnostics": diagnostics})
+}
+
+// SARIF renders comments as a SARIF 2.1.0 log with one result per comment.
+func SARIF(cf *store.CommentFile, source []byte, path string) (string, error) {
+	lines := strings.Split(string(source), "\n")
+	results := []map[string]any{}
+	for _, c := range openComments(cf) {
+		region := map[string]any{
+			"startLine": c.SourceStart,
+			"endLine":   c.SourceEnd,
+			"snippet":   map[string]any{"text": c.SelectedText},
+		}
+		if c.HasColumns() {
+			r := columnRange(c, lines)
+			region["startColumn"] = r.startCol + 1
+			region["endColumn"] = r.endCol + 1
+		}
+		results = append(results, map[string]any{
+			"ruleId":  "review-comment",
+			"level":   "note",
+			"message": map[string]any{"text": c.Comment},
+			"locations": []map[string]any{{
+				"physicalLocation": map[string]any{
+					"artifactLocation": map[string]any{"uri": filepath.ToSlash(path)},
+					"region":           region,
+				},
+			}},
+			"properties": map[string]any{"commentId": c.ID},
+		})
+	}
+
+	return marshal(map[string]any{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []map[string]any{{
+			"tool": map[string]any{
+				"driver": map[string]any{
+					"name":           "mdmu",
+					"informationUri": "https://github.com/paulbuckley/mdmu",
+					"rules": []map[string]any{{
+						"id":               "review-comment",
+						"shortDescription": map[string]any{"text": "Review comment"},
+					}},
+				},
+			},
+			"columnKind": "utf16CodeUnits",
+			"results":    results,
+		}},
+	})
+}
+
+// utf16Range is the 0-indexed start and exclusive end column of a comment,
+// in UTF-16 code units.
+type utf16Range struct {
+	startCol, endCol int
+}
+
+// columnRange converts a comment's byte columns to UTF-16 columns. Comments
+// on whole lines run from the start of the first line to the end of the last.
+func columnRange(c store.Comment, lines []string) utf16Range {
+	last := sourceLine(lines, c.SourceEnd)
+	if !c.HasColumns() {
+		return utf16Range{0, utf16Len(last)}
+	}
+	first := sourceLine(lines, c.SourceStart)
+	return utf16Range{
+		startCol: utf16Len(first[:min(c.StartCol-1, len(first))]),
+		endCol:   utf16Len(last[:min(c.EndCol, len(last))]),
+	}
+}
+
+// sourceLine returns 1-indexed line n, or "" when the source has changed and
+// no longer has it.
+func sourceLine(lines []string, n int) string {
+	if n < 1 || n > len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n-1], "\r")
+}
+
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+func marshal(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
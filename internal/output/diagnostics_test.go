@@ -0,0 +1,93 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+var diagnosticSource = []byte("# Title\n\nThe café is\nclosed.\n")
+
+var diagnosticComments = &store.CommentFile{Comments: []store.Comment{
+	{ID: "b", SourceStart: 3, SourceEnd: 3, StartCol: 5, EndCol: 9, SelectedText: "café", Comment: "Which\none?"},
+	{ID: "a", SourceStart: 3, SourceEnd: 4, SelectedText: "The café is\nclosed.", Comment: "Reword"},
+	{ID: "c", SourceStart: 1, SourceEnd: 1, Comment: "Done", Status: store.StatusResolved},
+}}
+
+func TestQuickfix(t *testing.T) {
+	want := "docs/plan.md:3:5: Which one?\ndocs/plan.md:3:1: Reword\n"
+	if got := Quickfix(diagnosticComments, "docs/plan.md"); got != want {
+		t.Errorf("Quickfix() = %q, want %q", got, want)
+	}
+}
+
+func TestLSP(t *testing.T) {
+	out, err := LSP(diagnosticComments, diagnosticSource, "/docs/plan.md")
+	if err != nil {
+		t.Fatalf("LSP failed: %v", err)
+	}
+	var params struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	if err := json.Unmarshal([]byte(out), &params); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if params.URI != "file:///docs/plan.md" {
+		t.Errorf("uri = %q", params.URI)
+	}
+	if len(params.Diagnostics) != 2 {
+		t.Fatalf("got %d diagnostics, want 2", len(params.Diagnostics))
+	}
+
+	// "café" is 5 bytes but 4 UTF-16 code units
+	want := lspRange{lspPosition{2, 4}, lspPosition{2, 8}}
+	if got := params.Diagnostics[0].Range; got != want {
+		t.Errorf("column range = %+v, want %+v", got, want)
+	}
+	want = lspRange{lspPosition{2, 0}, lspPosition{3, 7}}
+	if got := params.Diagnostics[1].Range; got != want {
+		t.Errorf("line range = %+v, want %+v", got, want)
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	out, err := Diagnostics(FormatSARIF, diagnosticComments, diagnosticSource, "plan.md")
+	if err != nil {
+		t.Fatalf("SARIF failed: %v", err)
+	}
+	var log struct {
+		Version string
+		Runs    []struct {
+			Results []struct {
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           map[string]any
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 2 {
+		t.Fatalf("unexpected log:\n%s", out)
+	}
+	loc := log.Runs[0].Results[0].Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "plan.md" || loc.Region["startColumn"] != 5.0 || loc.Region["endColumn"] != 9.0 {
+		t.Errorf("location = %+v", loc)
+	}
+	if _, ok := log.Runs[0].Results[1].Locations[0].PhysicalLocation.Region["startColumn"]; ok {
+		t.Error("comments on whole lines should have no columns")
+	}
+
+	if _, err := Diagnostics("xml", diagnosticComments, diagnosticSource, "plan.md"); err == nil ||
+		!strings.Contains(err.Error(), "quickfix") {
+		t.Errorf("unknown format error = %v", err)
+	}
+}
@@ -2,60 +2,353 @@ package output
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
+	"text/template"
 
+	"github.com/paulbuckley/mdmu/internal/markdown"
 	"github.com/paulbuckley/mdmu/internal/store"
 )
 
+// DefaultPrompt is the template for the instruction that opens the output.
+const DefaultPrompt = "Please address my comments on {{.Filename}}:"
+
+// Options control how comments are formatted.
+type Options struct {
+	// Prompt is a text/template for the opening instruction, executed with
+	// TemplateData.
+	Prompt string
+
+	// Metadata is the document's front matter, exposed to templates as .Meta.
+	Metadata map[string]any
+
+	// Quotes is QuotesFull to quote the text each comment targets, or
+	// QuotesNone for line references only.
+	Quotes string
+
+	// Layout is LayoutFlat to list comments in source order, or
+	// LayoutSections to group them under the headings that contain them.
+	Layout string
+
+	// Untouched lists the sections without comments in LayoutSections, as
+	// having no feedback.
+	Untouched bool
+
+	// Context is the number of source lines quoted before and after the
+	// lines of each comment, marked apart from them.
+	Context int
+
+	// MaxChars and MaxTokens limit the size of the output; zero means no
+	// limit. Context is left out and quotes abbreviated, and then left out,
+	// until it fits.
+	MaxChars  int
+	MaxTokens int
+}
+
+// Quote styles.
+const (
+	QuotesFull = "full"
+	QuotesNone = "none"
+)
+
+// QuoteStyles lists the quote styles.
+var QuoteStyles = []string{QuotesFull, QuotesNone}
+
+// Layouts.
+const (
+	LayoutFlat     = "flat"
+	LayoutSections = "sections"
+)
+
+// Layouts lists the layouts.
+var Layouts = []string{LayoutFlat, LayoutSections}
+
+// TemplateData is the data available to output templates.
+type TemplateData struct {
+	Filename string
+	Count    int            // number of comments
+	Meta     map[string]any // front matter; empty when the document has none
+}
+
+// DefaultOptions returns the options used by Format.
+func DefaultOptions() Options {
+	return Options{Prompt: DefaultPrompt}
+}
+
+// presets are named prompt styles selectable from configuration.
+var presets = map[string]string{
+	"default":   DefaultPrompt,
+	"revise":    "Please revise {{.Filename}} to address my review comments:",
+	"questions": "Please answer my questions about {{.Filename}}:",
+	"minimal":   "Comments on {{.Filename}}:",
+}
+
+// PresetNames returns the names of the output presets in sorted order.
+func PresetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Preset returns the options for a named output preset.
+func Preset(name string) (Options, error) {
+	prompt, ok := presets[name]
+	if !ok {
+		return Options{}, fmt.Errorf("unknown output preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	return Options{Prompt: prompt}, nil
+}
+
+// ParsePrompt parses a prompt template, reporting syntax errors.
+func ParsePrompt(prompt string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=zero").Parse(prompt)
+}
+
 // Format renders comments as structured markdown for LLM consumption.
 func Format(cf *store.CommentFile, source []byte, filename string) string {
-	if len(cf.Comments) == 0 {
-		return ""
+	return FormatWith(cf, source, filename, DefaultOptions())
+}
+
+// FormatWith renders comments like Format, using the given options. A prompt
+// template that fails to parse or execute falls back to DefaultPrompt.
+// Resolved comments are left out.
+func FormatWith(cf *store.CommentFile, source []byte, filename string, opts Options) string {
+	out, _ := FormatReport(cf, source, filename, opts)
+	return out
+}
+
+// Report describes formatted output and how it was fitted to its budget.
+type Report struct {
+	Size        Size
+	Abbreviated bool // quotes were shortened or left out to fit
+	OverBudget  bool // the output is over budget even without quotes
+}
+
+// quoteLimit bounds the quote of each comment: the lines kept, and the
+// characters kept of each line. Zero means no limit; negative lines leave
+// quotes out.
+type quoteLimit struct {
+	lines, chars int
+}
+
+// quoteLimits are the successively tighter limits tried until output fits
+// its budget.
+var quoteLimits = []quoteLimit{{0, 0}, {20, 400}, {10, 200}, {6, 120}, {2, 80}, {-1, 0}}
+
+// FormatReport renders comments like FormatWith, also reporting the size of
+// the output.
+func FormatReport(cf *store.CommentFile, source []byte, filename string, opts Options) (string, Report) {
+	sorted := openComments(cf)
+	if len(sorted) == 0 {
+		return "", Report{}
 	}
 
-	sourceLines := strings.Split(string(source), "\n")
+	limits := quoteLimits
+	if opts.Quotes == QuotesNone {
+		limits = limits[len(limits)-1:]
+	}
+	var out string
+	var report Report
+	for i, limit := range limits {
+		out = formatComments(sorted, source, filename, opts, limit)
+		report = Report{Size: Measure(out), Abbreviated: limit != quoteLimits[0] && opts.Quotes != QuotesNone}
+		if report.Size.Within(opts.MaxChars, opts.MaxTokens) {
+			return out, report
+		}
+		report.OverBudget = i == len(limits)-1
+	}
+	return out, report
+}
 
-	// Sort comments by source line position
-	sorted := make([]store.Comment, len(cf.Comments))
-	copy(sorted, cf.Comments)
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].SourceStart < sorted[j].SourceStart
-	})
+func formatComments(sorted []store.Comment, source []byte, filename string, opts Options, limit quoteLimit) string {
+	sourceLines := strings.Split(string(source), "\n")
+	headings := markdown.Headings(source)
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Please address my comments on %s:\n\n", filename))
+	sb.WriteString(renderPrompt(opts, TemplateData{
+		Filename: filename,
+		Count:    len(sorted),
+		Meta:     opts.Metadata,
+	}) + "\n\n")
 	sb.WriteString(fmt.Sprintf("## Comments on %s\n\n", filename))
 
+	if opts.Layout == LayoutSections {
+		writeSections(&sb, sorted, headings, sourceLines, opts, limit)
+		return sb.String()
+	}
+
 	for i, c := range sorted {
-		if c.SourceStart == c.SourceEnd {
-			sb.WriteString(fmt.Sprintf("### Line %d:\n", c.SourceStart))
+		sb.WriteString("### " + rangeLabel(c) + ":\n")
+		if crumbs := markdown.Breadcrumb(headings, c.SourceStart); len(crumbs) > 0 {
+			sb.WriteString("**Section:** " + strings.Join(crumbs, " > ") + "\n")
+		}
+		writeQuote(&sb, c, sourceLines, opts, limit)
+		writeComment(&sb, "**Comment:** ", c)
+
+		if i < len(sorted)-1 {
+			sb.WriteString("\n---\n\n")
 		} else {
-			sb.WriteString(fmt.Sprintf("### Lines %d-%d:\n", c.SourceStart, c.SourceEnd))
+			sb.WriteString("\n")
 		}
+	}
+
+	return sb.String()
+}
+
+// writeQuote quotes the source text a comment targets, within a limit.
+func writeQuote(sb *strings.Builder, c store.Comment, sourceLines []string, opts Options, limit quoteLimit) {
+	var lines []string
+	switch {
+	case limit.lines < 0:
+		return
+	case opts.Context > 0 && limit == quoteLimits[0]:
+		lines = contextLines(c, sourceLines, opts.Context)
+	default:
+		lines = abbreviate(quotedLines(c, sourceLines), limit)
+	}
+	for _, line := range lines {
+		sb.WriteString("> " + line + "\n")
+	}
+	sb.WriteString("\n")
+}
 
-		// Quote the selected source text
-		start := c.SourceStart - 1
-		end := c.SourceEnd
-		if start < 0 {
-			start = 0
+// writeComment writes a comment's body after label, and its replies.
+func writeComment(sb *strings.Builder, label string, c store.Comment) {
+	sb.WriteString(label + c.Comment + "\n")
+	for _, r := range c.Replies {
+		sb.WriteString("**Reply (" + r.Author + "):** " + r.Body + "\n")
+	}
+}
+
+// contextLines returns the whole lines of a comment with n lines around
+// them, numbered, with "▶" marking the comment's lines and "│" the context.
+func contextLines(c store.Comment, sourceLines []string, n int) []string {
+	first := max(c.SourceStart-n, 1)
+	last := min(c.SourceEnd+n, len(sourceLines))
+	if last == len(sourceLines) && sourceLines[last-1] == "" {
+		last-- // the empty line after a final newline
+	}
+	width := len(fmt.Sprint(last))
+
+	var lines []string
+	for num := first; num <= last; num++ {
+		marker := "│"
+		if num >= c.SourceStart && num <= c.SourceEnd {
+			marker = "▶"
 		}
-		if end > len(sourceLines) {
-			end = len(sourceLines)
+		line := fmt.Sprintf("%*d %s %s", width, num, marker, sourceLines[num-1])
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return lines
+}
+
+// abbreviate shortens a quote to a limit, keeping its first and last lines
+// around a marker for those left out.
+func abbreviate(lines []string, limit quoteLimit) []string {
+	var out []string
+	if limit.lines > 0 && len(lines) > limit.lines {
+		head := (limit.lines + 1) / 2
+		tail := limit.lines - head
+		out = append(out, lines[:head]...)
+		out = append(out, fmt.Sprintf("… (%d lines omitted) …", len(lines)-limit.lines))
+		out = append(out, lines[len(lines)-tail:]...)
+	} else {
+		out = slices.Clone(lines)
+	}
+
+	if limit.chars > 0 {
+		for i, line := range out {
+			if runes := []rune(line); len(runes) > limit.chars {
+				out[i] = string(runes[:limit.chars]) + " …"
+			}
 		}
-		for _, line := range sourceLines[start:end] {
-			sb.WriteString("> " + line + "\n")
+	}
+	return out
+}
+
+// openComments returns the comments that aren't resolved, sorted by source
+// line position.
+func openComments(cf *store.CommentFile) []store.Comment {
+	var open []store.Comment
+	for _, c := range cf.Comments {
+		if !c.Resolved() {
+			open = append(open, c)
 		}
-		sb.WriteString("\n")
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		return open[i].SourceStart < open[j].SourceStart
+	})
+	return open
+}
 
-		sb.WriteString("**Comment:** " + c.Comment + "\n")
+// renderPrompt executes the prompt template, falling back to DefaultPrompt.
+func renderPrompt(opts Options, data TemplateData) string {
+	if data.Meta == nil {
+		data.Meta = map[string]any{}
+	}
+	for _, prompt := range []string{opts.Prompt, DefaultPrompt} {
+		if prompt == "" {
+			continue
+		}
+		tmpl, err := ParsePrompt(prompt)
+		if err != nil {
+			continue
+		}
+		var sb strings.Builder
+		if err := tmpl.Execute(&sb, data); err == nil {
+			return strings.TrimRight(sb.String(), "\n")
+		}
+	}
+	return ""
+}
 
-		if i < len(sorted)-1 {
-			sb.WriteString("\n---\n\n")
+// rangeLabel describes the source range a comment targets.
+func rangeLabel(c store.Comment) string {
+	var label string
+	if c.SourceStart == c.SourceEnd {
+		label = fmt.Sprintf("Line %d", c.SourceStart)
+	} else {
+		label = fmt.Sprintf("Lines %d-%d", c.SourceStart, c.SourceEnd)
+	}
+	if c.HasColumns() {
+		if c.SourceStart == c.SourceEnd {
+			label += fmt.Sprintf(", columns %d-%d", c.StartCol, c.EndCol)
 		} else {
-			sb.WriteString("\n")
+			label = fmt.Sprintf("Line %d column %d to line %d column %d", c.SourceStart, c.StartCol, c.SourceEnd, c.EndCol)
 		}
 	}
+	return label
+}
 
-	return sb.String()
+// quotedLines returns the source text a comment targets, trimmed to its
+// columns when it targets a span.
+func quotedLines(c store.Comment, sourceLines []string) []string {
+	start := c.SourceStart - 1
+	end := c.SourceEnd
+	if start < 0 {
+		start = 0
+	}
+	if end > len(sourceLines) {
+		end = len(sourceLines)
+	}
+	if start >= end {
+		return nil
+	}
+
+	lines := make([]string, end-start)
+	copy(lines, sourceLines[start:end])
+	if c.HasColumns() {
+		last := len(lines) - 1
+		if end == c.SourceEnd && c.EndCol < len(lines[last]) {
+			lines[last] = lines[last][:c.EndCol]
+		}
+		if start == c.SourceStart-1 && c.StartCol-1 <= len(lines[0]) {
+			lines[0] = lines[0][c.StartCol-1:]
+		}
+	}
+	return lines
 }
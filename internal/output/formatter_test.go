@@ -67,3 +67,185 @@ func TestFormatMultipleComments_Sorted(t *testing.T) {
 		t.Error("comments should be sorted by source line")
 	}
 }
+
+func TestFormatColumnSpan(t *testing.T) {
+	source := []byte("# Title\n\nThe quick brown fox.\n")
+	cf := &store.CommentFile{
+		Comments: []store.Comment{
+			{ID: "1", SourceStart: 3, SourceEnd: 3, StartCol: 5, EndCol: 15, Comment: "which fox?"},
+		},
+	}
+
+	result := Format(cf, source, "test.md")
+
+	if !strings.Contains(result, "### Line 3, columns 5-15:") {
+		t.Errorf("output should contain column reference, got:\n%s", result)
+	}
+	if !strings.Contains(result, "> quick brown\n") {
+		t.Errorf("output should quote only the selected span, got:\n%s", result)
+	}
+}
+
+func TestFormatWithPromptTemplate(t *testing.T) {
+	source := []byte("line1\nline2\n")
+	cf := &store.CommentFile{
+		Comments: []store.Comment{{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "fix"}},
+	}
+
+	opts := Options{
+		Prompt:   "Review of {{.Meta.title}} ({{.Count}} comments):",
+		Metadata: map[string]any{"title": "Auth plan"},
+	}
+	result := FormatWith(cf, source, "test.md", opts)
+	if !strings.HasPrefix(result, "Review of Auth plan (1 comments):\n\n") {
+		t.Errorf("prompt template not applied, got:\n%s", result)
+	}
+
+	// A broken template falls back to the default prompt
+	opts.Prompt = "{{.Nope"
+	result = FormatWith(cf, source, "test.md", opts)
+	if !strings.HasPrefix(result, "Please address my comments on test.md:") {
+		t.Errorf("expected default prompt fallback, got:\n%s", result)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	for _, name := range PresetNames() {
+		opts, err := Preset(name)
+		if err != nil {
+			t.Fatalf("Preset(%q) failed: %v", name, err)
+		}
+		if _, err := ParsePrompt(opts.Prompt); err != nil {
+			t.Errorf("preset %q has an invalid prompt: %v", name, err)
+		}
+	}
+
+	if _, err := Preset("nope"); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+}
+
+func TestFormatSkipsResolvedAndIncludesReplies(t *testing.T) {
+	source := []byte("# Title\n\nFirst.\n\nSecond.\n")
+	cf := &store.CommentFile{
+		Comments: []store.Comment{
+			{ID: "1", SourceStart: 3, SourceEnd: 3, Comment: "Done already", Status: store.StatusResolved},
+			{ID: "2", SourceStart: 5, SourceEnd: 5, Comment: "Why?",
+				Replies: []store.Reply{{Author: "agent", Body: "Because it is shorter"}}},
+		},
+	}
+
+	result := Format(cf, source, "test.md")
+
+	if strings.Contains(result, "Done already") {
+		t.Error("resolved comments should be left out")
+	}
+	if !strings.Contains(result, "**Reply (agent):** Because it is shorter") {
+		t.Errorf("output should contain the reply, got:\n%s", result)
+	}
+
+	cf.Comments[1].Status = store.StatusResolved
+	if result := Format(cf, source, "test.md"); result != "" {
+		t.Errorf("expected empty output when every comment is resolved, got %q", result)
+	}
+}
+
+func TestFormatBudget(t *testing.T) {
+	var lines []string
+	for i := 1; i <= 40; i++ {
+		lines = append(lines, strings.Repeat("word ", 20))
+	}
+	source := []byte(strings.Join(lines, "\n") + "\n")
+	cf := &store.CommentFile{Comments: []store.Comment{{ID: "1", SourceStart: 1, SourceEnd: 40, Comment: "Too long"}}}
+
+	full, report := FormatReport(cf, source, "test.md", DefaultOptions())
+	if report.Abbreviated || report.Size != Measure(full) {
+		t.Errorf("unlimited report = %+v", report)
+	}
+
+	opts := DefaultOptions()
+	opts.MaxTokens = 400
+	out, report := FormatReport(cf, source, "test.md", opts)
+	if !report.Abbreviated || report.OverBudget || report.Size.Tokens > 400 {
+		t.Errorf("budgeted report = %+v", report)
+	}
+	if !strings.Contains(out, "lines omitted") || !strings.Contains(out, "**Comment:** Too long") {
+		t.Errorf("abbreviated output should keep the comment and mark the elision, got:\n%s", out)
+	}
+
+	opts = DefaultOptions()
+	opts.Quotes = QuotesNone
+	out, report = FormatReport(cf, source, "test.md", opts)
+	if strings.Contains(out, "> word") || report.Abbreviated {
+		t.Errorf("QuotesNone should give references only, got:\n%s", out)
+	}
+	if !strings.Contains(out, "### Lines 1-40:") {
+		t.Errorf("output should keep the line reference, got:\n%s", out)
+	}
+
+	opts.MaxChars = 10
+	if _, report := FormatReport(cf, source, "test.md", opts); !report.OverBudget {
+		t.Error("output that can't fit should be reported over budget")
+	}
+}
+
+func TestFormatContextAndSection(t *testing.T) {
+	source := []byte("# Plan\n\n## Phase 2\n\n### Auth\n\nline 7\nline 8\nline 9\nline 10\n")
+	cf := &store.CommentFile{Comments: []store.Comment{{ID: "1", SourceStart: 8, SourceEnd: 8, Comment: "Why?"}}}
+
+	opts := DefaultOptions()
+	opts.Context = 2
+	out := FormatWith(cf, source, "test.md", opts)
+	if !strings.Contains(out, "**Section:** Plan > Phase 2 > Auth\n") {
+		t.Errorf("output should name the enclosing headings, got:\n%s", out)
+	}
+	want := "> 6 │\n> 7 │ line 7\n> 8 ▶ line 8\n> 9 │ line 9\n> 10 │ line 10\n"
+	if !strings.Contains(strings.ReplaceAll(out, ">  ", "> "), want) {
+		t.Errorf("output should quote numbered context around the comment, got:\n%s", out)
+	}
+
+	opts.MaxChars = Measure(out).Chars - 10
+	if out := FormatWith(cf, source, "test.md", opts); strings.Contains(out, "│") {
+		t.Errorf("context should be dropped to fit the budget, got:\n%s", out)
+	}
+}
+
+func TestSizeString(t *testing.T) {
+	if got := Measure(strings.Repeat("x", 5100)).String(); got != "5.1k chars, ~1.3k tokens" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestFormatSections(t *testing.T) {
+	source := []byte("Intro\n\n# Plan\n\n## Phase 1\n\nstep one\nstep two\n\n## Phase 2\n\n### Auth\n\nlogin\n")
+	cf := &store.CommentFile{Comments: []store.Comment{
+		{ID: "1", SourceStart: 8, SourceEnd: 8, Comment: "second"},
+		{ID: "2", SourceStart: 7, SourceEnd: 7, Comment: "first"},
+		{ID: "3", SourceStart: 14, SourceEnd: 14, Comment: "auth"},
+		{ID: "4", SourceStart: 1, SourceEnd: 1, Comment: "intro"},
+	}}
+
+	opts := DefaultOptions()
+	opts.Layout = LayoutSections
+	out := FormatWith(cf, source, "test.md", opts)
+	want := "## Comments on test.md\n\n" +
+		"### Before the first heading\n\n**Line 1:**\n> Intro\n\n**Comment:** intro\n\n" +
+		"### Plan (line 3)\n\n" +
+		"#### Phase 1 (line 5)\n\n**Lines 7-8:**\n> step one\n> step two\n\n" +
+		"**Comment (Line 7):** first\n**Comment (Line 8):** second\n\n" +
+		"#### Phase 2 (line 10)\n\n" +
+		"##### Auth (line 12)\n\n**Line 14:**\n> login\n\n**Comment:** auth\n\n"
+	if !strings.HasSuffix(out, want) {
+		t.Errorf("sections output =\n%s\nwant suffix\n%s", out, want)
+	}
+
+	cf.Comments = cf.Comments[2:3]
+	opts.Untouched = true
+	out = FormatWith(cf, source, "test.md", opts)
+	if !strings.Contains(out, "#### Phase 1 (line 5)\n\n_No feedback._\n") {
+		t.Errorf("untouched sections should be listed, got:\n%s", out)
+	}
+	if strings.Contains(out, "Phase 2 (line 10)\n\n_No feedback._") {
+		t.Errorf("a section with nested feedback isn't untouched, got:\n%s", out)
+	}
+}
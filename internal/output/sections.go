@@ -0,0 +1,110 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// section is a heading of the document and the comments from it to the
+// next heading.
+type section struct {
+	heading  markdown.Heading
+	depth    int // nesting depth, from 1 for the outermost headings
+	parent   int // index of the enclosing section, 0 for none
+	comments []store.Comment
+	feedback bool // the section or one nested in it has comments
+}
+
+// writeSections writes comments grouped under the headings that contain
+// them, nesting the headings as the document does.
+func writeSections(sb *strings.Builder, sorted []store.Comment, headings []markdown.Heading, sourceLines []string, opts Options, limit quoteLimit) {
+	// sections[0] holds the comments before the first heading
+	sections := make([]section, len(headings)+1)
+	var open []int
+	for i, h := range headings {
+		for len(open) > 0 && sections[open[len(open)-1]].heading.Level >= h.Level {
+			open = open[:len(open)-1]
+		}
+		s := section{heading: h, depth: len(open) + 1}
+		if len(open) > 0 {
+			s.parent = open[len(open)-1]
+		}
+		sections[i+1] = s
+		open = append(open, i+1)
+	}
+
+	for _, c := range sorted {
+		// A comment belongs to the last heading on or before its first line
+		i := sort.Search(len(headings), func(i int) bool { return headings[i].Line > c.SourceStart })
+		sections[i].comments = append(sections[i].comments, c)
+		for j := i; j > 0 && !sections[j].feedback; j = sections[j].parent {
+			sections[j].feedback = true
+		}
+	}
+
+	if len(sections[0].comments) > 0 {
+		if len(headings) > 0 {
+			sb.WriteString("### Before the first heading\n\n")
+		}
+		writeGroups(sb, sections[0].comments, sourceLines, opts, limit)
+	}
+	for _, s := range sections[1:] {
+		if !s.feedback && !opts.Untouched {
+			continue
+		}
+		level := min(s.depth+2, 6)
+		fmt.Fprintf(sb, "%s %s (line %d)\n\n", strings.Repeat("#", level), s.heading.Text, s.heading.Line)
+		switch {
+		case len(s.comments) > 0:
+			writeGroups(sb, s.comments, sourceLines, opts, limit)
+		case !s.feedback:
+			sb.WriteString("_No feedback._\n\n")
+		}
+	}
+}
+
+// writeGroups writes the comments of a section, quoting comments on
+// overlapping or adjacent lines once, together.
+func writeGroups(sb *strings.Builder, comments []store.Comment, sourceLines []string, opts Options, limit quoteLimit) {
+	for _, group := range mergeComments(comments) {
+		span := group[0]
+		if len(group) > 1 {
+			span = store.Comment{SourceStart: group[0].SourceStart}
+			for _, c := range group {
+				span.SourceEnd = max(span.SourceEnd, c.SourceEnd)
+			}
+		}
+
+		sb.WriteString("**" + rangeLabel(span) + ":**\n")
+		writeQuote(sb, span, sourceLines, opts, limit)
+		for _, c := range group {
+			label := "**Comment:** "
+			if len(group) > 1 {
+				label = "**Comment (" + rangeLabel(c) + "):** "
+			}
+			writeComment(sb, label, c)
+		}
+		sb.WriteString("\n")
+	}
+}
+
+// mergeComments groups comments, sorted by line, whose lines overlap or are
+// adjacent.
+func mergeComments(comments []store.Comment) [][]store.Comment {
+	var groups [][]store.Comment
+	end := 0
+	for _, c := range comments {
+		if n := len(groups); n > 0 && c.SourceStart <= end+1 {
+			groups[n-1] = append(groups[n-1], c)
+			end = max(end, c.SourceEnd)
+			continue
+		}
+		groups = append(groups, []store.Comment{c})
+		end = c.SourceEnd
+	}
+	return groups
+}
@@ -0,0 +1,36 @@
+package output
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Size is the size of formatted output.
+type Size struct {
+	Chars  int
+	Tokens int // estimated
+}
+
+// Measure returns the size of s. Tokens are estimated at four characters
+// each, which is close for English prose and markdown.
+func Measure(s string) Size {
+	chars := utf8.RuneCountInString(s)
+	return Size{Chars: chars, Tokens: (chars + 3) / 4}
+}
+
+// Within reports whether the size fits the limits; zero means no limit.
+func (s Size) Within(maxChars, maxTokens int) bool {
+	return (maxChars <= 0 || s.Chars <= maxChars) && (maxTokens <= 0 || s.Tokens <= maxTokens)
+}
+
+// String describes the size compactly, such as "5.1k chars, ~1.3k tokens".
+func (s Size) String() string {
+	return fmt.Sprintf("%s chars, ~%s tokens", compact(s.Chars), compact(s.Tokens))
+}
+
+func compact(n int) string {
+	if n < 1000 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%.1fk", float64(n)/1000)
+}
@@ -0,0 +1,236 @@
+// Package server serves a browser review UI for a markdown document. The
+// document is rendered as HTML with an anchor per source line, and comments
+// are read and written through a small JSON API backed by the comment file,
+// so a TUI session on the same file sees them too.
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/output"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+//go:embed page.html
+var pageHTML string
+
+var pageTemplate = template.Must(template.New("page").Parse(pageHTML))
+
+// Server serves one document and its comments.
+type Server struct {
+	Path      string         // absolute path of the document
+	StorePath string         // comment file, shared with other sessions
+	Output    output.Options // formatting of the review
+	Addr      string         // address the server listens on, as host:port
+}
+
+// Handler returns the HTTP handler for the UI and its API:
+//
+//	GET    /                    the rendered document
+//	GET    /api/comments        the comments, as {"file", "comments"}
+//	POST   /api/comments        add a comment from {"source_start", "source_end", "comment"}
+//	DELETE /api/comments/{id}   delete a comment
+//	GET    /api/review          the formatted review, as markdown
+//
+// Requests must name the server by its loopback address, in the Host header
+// and any Origin header, so that other web pages, including those reaching
+// it through DNS rebinding, can't read or add comments.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.page)
+	mux.HandleFunc("GET /api/comments", s.listComments)
+	mux.HandleFunc("POST /api/comments", s.addComment)
+	mux.HandleFunc("DELETE /api/comments/{id}", s.deleteComment)
+	mux.HandleFunc("GET /api/review", s.review)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.local(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("unexpected host %q", r.Host))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !s.local(u.Host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("cross-origin request from %q", origin))
+				return
+			}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// local reports whether host, as host:port, names the server's loopback
+// address: its port on localhost or a loopback IP.
+func (s *Server) local(host string) bool {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return false
+	}
+	if _, addrPort, err := net.SplitHostPort(s.Addr); err != nil || port != addrPort {
+		return false
+	}
+	if name == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(name)
+	return ip != nil && ip.IsLoopback()
+}
+
+// The document is read on every request, so edits show up on reload.
+func (s *Server) page(w http.ResponseWriter, r *http.Request) {
+	source, err := os.ReadFile(s.Path)
+	if err != nil {
+		http.Error(w, "reading document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body, err := markdown.RenderHTML(source)
+	if err != nil {
+		http.Error(w, "rendering document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = pageTemplate.Execute(&buf, map[string]any{
+		"Name": filepath.Base(s.Path),
+		"Body": template.HTML(body),
+	})
+	if err != nil {
+		http.Error(w, "rendering page: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request) {
+	cf, err := store.Load(s.StorePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	comments := cf.Comments
+	if comments == nil {
+		comments = []store.Comment{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"file": s.Path, "comments": comments})
+}
+
+type newComment struct {
+	SourceStart int    `json:"source_start"`
+	SourceEnd   int    `json:"source_end"`
+	Comment     string `json:"comment"`
+}
+
+func (s *Server) addComment(w http.ResponseWriter, r *http.Request) {
+	// Browsers send other pages' form and text/plain posts without asking
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("requests must be application/json"))
+		return
+	}
+	var req newComment
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.Comment) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("comment must not be empty"))
+		return
+	}
+
+	source, err := os.ReadFile(s.Path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	lines := strings.Split(string(source), "\n")
+	if req.SourceStart < 1 || req.SourceEnd < req.SourceStart || req.SourceEnd > len(lines) {
+		writeError(w, http.StatusBadRequest,
+			fmt.Errorf("invalid line range %d-%d: the document has %d lines", req.SourceStart, req.SourceEnd, len(lines)))
+		return
+	}
+
+	c := store.Comment{
+		ID:           uuid.New().String(),
+		SourceStart:  req.SourceStart,
+		SourceEnd:    req.SourceEnd,
+		SelectedText: strings.Join(lines[req.SourceStart-1:req.SourceEnd], "\n"),
+		Comment:      req.Comment,
+		CreatedAt:    time.Now(),
+	}
+	_, err = store.Update(s.StorePath, func(cf *store.CommentFile) error {
+		cf.Comments = append(cf.Comments, c)
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, c)
+}
+
+func (s *Server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	_, err := store.Update(s.StorePath, func(cf *store.CommentFile) error {
+		for i, c := range cf.Comments {
+			if c.ID == id {
+				cf.Comments = append(cf.Comments[:i], cf.Comments[i+1:]...)
+				return nil
+			}
+		}
+		return errNotFound
+	})
+	switch {
+	case errors.Is(err, errNotFound):
+		writeError(w, http.StatusNotFound, fmt.Errorf("no comment with id %q", id))
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+func (s *Server) review(w http.ResponseWriter, r *http.Request) {
+	source, err := os.ReadFile(s.Path)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	cf, err := store.Load(s.StorePath)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	opts := s.Output
+	if fm := markdown.ParseFrontMatter(source); fm != nil {
+		opts.Metadata = fm.Data
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	fmt.Fprint(w, output.FormatWith(cf, source, filepath.Base(s.Path), opts))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
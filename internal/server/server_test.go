@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/paulbuckley/mdmu/internal/output"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, *Server) {
+	t.Helper()
+	dir := t.TempDir()
+	doc := filepath.Join(dir, "plan.md")
+	if err := os.WriteFile(doc, []byte("# Plan\n\nUse sessions.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Path: doc, StorePath: filepath.Join(dir, "plan.md.json"), Output: output.DefaultOptions()}
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	s.Addr = ts.Listener.Addr().String()
+	return ts, s
+}
+
+// request sends a request as the page does, with JSON bodies.
+func request(t *testing.T, method, url, body string) (int, string) {
+	t.Helper()
+	header := http.Header{}
+	if body != "" {
+		header.Set("Content-Type", "application/json")
+	}
+	return requestWith(t, method, url, body, header)
+}
+
+func requestWith(t *testing.T, method, url, body string, header http.Header) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	if host := header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestPage(t *testing.T) {
+	ts, _ := newTestServer(t)
+	status, body := request(t, "GET", ts.URL+"/", "")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	if !strings.Contains(body, `<p id="L3" data-start="3" data-end="3">Use sessions.</p>`) {
+		t.Errorf("page should contain the anchored document, got:\n%s", body)
+	}
+	if status, _ := request(t, "GET", ts.URL+"/nope", ""); status != http.StatusNotFound {
+		t.Errorf("unknown path status = %d, want 404", status)
+	}
+}
+
+func TestCommentsAPI(t *testing.T) {
+	ts, s := newTestServer(t)
+
+	status, body := request(t, "POST", ts.URL+"/api/comments",
+		`{"source_start": 3, "source_end": 3, "comment": "Why not tokens?"}`)
+	if status != http.StatusCreated {
+		t.Fatalf("add status = %d, want 201: %s", status, body)
+	}
+	var added store.Comment
+	json.Unmarshal([]byte(body), &added)
+	if added.ID == "" || added.SelectedText != "Use sessions." {
+		t.Errorf("added comment = %+v", added)
+	}
+
+	// Written to the comment file other sessions read
+	cf, err := store.Load(s.StorePath)
+	if err != nil || len(cf.Comments) != 1 {
+		t.Fatalf("stored comments = %+v, %v", cf, err)
+	}
+
+	if _, review := request(t, "GET", ts.URL+"/api/review", ""); !strings.Contains(review, "**Comment:** Why not tokens?") {
+		t.Errorf("review = %q", review)
+	}
+
+	for _, bad := range []string{
+		`{"source_start": 3, "source_end": 9, "comment": "x"}`,
+		`{"source_start": 1, "source_end": 1, "comment": " "}`,
+		`not json`,
+	} {
+		if status, _ := request(t, "POST", ts.URL+"/api/comments", bad); status != http.StatusBadRequest {
+			t.Errorf("POST %s: status = %d, want 400", bad, status)
+		}
+	}
+
+	if status, _ := request(t, "DELETE", ts.URL+"/api/comments/"+added.ID, ""); status != http.StatusNoContent {
+		t.Errorf("delete status = %d, want 204", status)
+	}
+	if status, _ := request(t, "DELETE", ts.URL+"/api/comments/"+added.ID, ""); status != http.StatusNotFound {
+		t.Errorf("second delete status = %d, want 404", status)
+	}
+	if _, body := request(t, "GET", ts.URL+"/api/comments", ""); !strings.Contains(body, `"comments":[]`) {
+		t.Errorf("comments after delete = %s", body)
+	}
+}
+
+func TestRejectsOtherSites(t *testing.T) {
+	ts, s := newTestServer(t)
+	_, port, _ := net.SplitHostPort(s.Addr)
+	body := `{"source_start": 3, "source_end": 3, "comment": "Ship it"}`
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"text/plain post", http.Header{"Content-Type": {"text/plain"}}, http.StatusUnsupportedMediaType},
+		{"form post", http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, http.StatusUnsupportedMediaType},
+		{"no content type", http.Header{}, http.StatusUnsupportedMediaType},
+		{"rebound host", http.Header{"Content-Type": {"application/json"}, "Host": {"evil.example:" + port}}, http.StatusForbidden},
+		{"other port", http.Header{"Content-Type": {"application/json"}, "Host": {"localhost:1"}}, http.StatusForbidden},
+		{"cross origin", http.Header{"Content-Type": {"application/json"}, "Origin": {"http://evil.example"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if status, _ := requestWith(t, "POST", ts.URL+"/api/comments", body, tt.header); status != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.want)
+		}
+	}
+	if status, _ := requestWith(t, "GET", ts.URL+"/api/comments", "", http.Header{"Host": {"evil.example:" + port}}); status != http.StatusForbidden {
+		t.Errorf("reading comments through a rebound host: status = %d, want 403", status)
+	}
+	if cf, _ := store.Load(s.StorePath); len(cf.Comments) != 0 {
+		t.Errorf("rejected requests added comments: %+v", cf.Comments)
+	}
+
+	same := http.Header{"Content-Type": {"application/json"}, "Origin": {"http://localhost:" + port}}
+	if status, body := requestWith(t, "POST", ts.URL+"/api/comments", body, same); status != http.StatusCreated {
+		t.Errorf("same-origin post: status = %d, want 201: %s", status, body)
+	}
+}
+
+func TestPageTemplateError(t *testing.T) {
+	ts, _ := newTestServer(t)
+	saved := pageTemplate
+	pageTemplate = template.Must(template.New("page").Parse("<h1>{{.Name}}</h1>{{index .Name 99}}"))
+	defer func() { pageTemplate = saved }()
+
+	status, body := request(t, "GET", ts.URL+"/", "")
+	if status != http.StatusInternalServerError || strings.Contains(body, "<h1>") {
+		t.Errorf("status = %d with body %q, want a 500 without the partial page", status, body)
+	}
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PathFor returns where comments on a document are persisted. An absolute
+// dir holds comment files for all documents, named after their full paths;
+// a relative dir is resolved next to the document.
+func PathFor(docPath, dir string) string {
+	if filepath.IsAbs(dir) {
+		return filepath.Join(dir, url.PathEscape(filepath.ToSlash(docPath))+".json")
+	}
+	return filepath.Join(filepath.Dir(docPath), dir, filepath.Base(docPath)+".mdmu.json")
+}
+
+// Load reads a comment file. A missing file is an empty CommentFile.
+func Load(path string) (*CommentFile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &CommentFile{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading comments: %w", err)
+	}
+
+	cf := &CommentFile{}
+	if err := json.Unmarshal(data, cf); err != nil {
+		return nil, fmt.Errorf("reading comments from %s: %w", path, err)
+	}
+	return cf, nil
+}
+
+// Update applies fn to the comment file at path and saves the result,
+// holding a lock so that concurrent writers such as the TUI and the MCP
+// server don't lose each other's changes. The file is left untouched when
+// fn returns an error.
+func Update(path string, fn func(cf *CommentFile) error) (*CommentFile, error) {
+	unlock, err := lock(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	cf, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(cf); err != nil {
+		return nil, err
+	}
+	if err := Save(path, cf); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// lockStale is how old a lock file must be before it is assumed to have
+// been left behind by a crashed process.
+const lockStale = 10 * time.Second
+
+// lock takes an exclusive lock next to path, waiting briefly for other
+// holders to finish.
+func lock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("locking comments: %w", err)
+	}
+
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("locking comments: %w", err)
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("comments are locked by another process (%s)", lockPath)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Save writes a comment file atomically, creating its directory if needed.
+func Save(path string, cf *CommentFile) error {
+	data, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding comments: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("saving comments: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mdmu-*.json")
+	if err != nil {
+		return fmt.Errorf("saving comments: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving comments: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving comments: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("saving comments: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "plan.md.json")
+	cf := &CommentFile{
+		Comments: []Comment{
+			{ID: "1", SourceStart: 3, SourceEnd: 4, StartCol: 2, EndCol: 7, SelectedText: "text", Comment: "fix", CreatedAt: time.Unix(100, 0).UTC(),
+				Status: StatusResolved, Replies: []Reply{{Author: "agent", Body: "done", CreatedAt: time.Unix(200, 0).UTC()}}},
+		},
+	}
+
+	if err := Save(path, cf); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(loaded.Comments) != 1 || !reflect.DeepEqual(loaded.Comments[0], cf.Comments[0]) {
+		t.Errorf("round trip = %+v, want %+v", loaded.Comments, cf.Comments)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cf, err := Load(filepath.Join(t.TempDir(), "none.json"))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cf.Comments) != 0 {
+		t.Errorf("expected no comments, got %d", len(cf.Comments))
+	}
+}
+
+func TestPathFor(t *testing.T) {
+	doc := filepath.FromSlash("/work/docs/plan.md")
+
+	if got, want := PathFor(doc, "."), filepath.FromSlash("/work/docs/plan.md.mdmu.json"); got != want {
+		t.Errorf("PathFor(relative) = %q, want %q", got, want)
+	}
+	if got, want := PathFor(doc, filepath.FromSlash("/state")), filepath.Join(filepath.FromSlash("/state"), "%2Fwork%2Fdocs%2Fplan.md.json"); filepath.ToSlash(doc) == "/work/docs/plan.md" && got != want {
+		t.Errorf("PathFor(absolute) = %q, want %q", got, want)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.md.json")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := Update(path, func(cf *CommentFile) error {
+				cf.Comments = append(cf.Comments, Comment{ID: time.Now().String()})
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	cf, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cf.Comments) != 10 {
+		t.Errorf("got %d comments, want 10 from concurrent updates", len(cf.Comments))
+	}
+}
+
+func TestUpdateErrorLeavesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plan.md.json")
+	if err := Save(path, &CommentFile{Comments: []Comment{{ID: "1"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Update(path, func(cf *CommentFile) error {
+		cf.Comments = nil
+		return errors.New("nope")
+	})
+	if err == nil {
+		t.Fatal("expected the error from fn")
+	}
+	cf, _ := Load(path)
+	if len(cf.Comments) != 1 {
+		t.Errorf("file changed despite the error: %+v", cf.Comments)
+	}
+}
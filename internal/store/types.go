@@ -3,14 +3,52 @@ package store
 import "time"
 
 type Comment struct {
-	ID           string
-	SourceStart  int // 1-indexed
-	SourceEnd    int
-	SelectedText string
-	Comment      string
-	CreatedAt    time.Time
+	ID           string    `json:"id"`
+	SourceStart  int       `json:"source_start"` // 1-indexed
+	SourceEnd    int       `json:"source_end"`
+	StartCol     int       `json:"start_col,omitempty"` // 1-indexed byte column on SourceStart; 0 means whole lines
+	EndCol       int       `json:"end_col,omitempty"`   // 1-indexed inclusive byte column on SourceEnd
+	SelectedText string    `json:"selected_text"`
+	Comment      string    `json:"comment"`
+	CreatedAt    time.Time `json:"created_at"`
+	Status       string    `json:"status,omitempty"` // StatusOpen or StatusResolved; empty means open
+	Replies      []Reply   `json:"replies,omitempty"`
+}
+
+// Comment statuses.
+const (
+	StatusOpen     = "open"
+	StatusResolved = "resolved"
+)
+
+// Reply is a response to a comment, such as an agent explaining a change.
+type Reply struct {
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HasColumns reports whether the comment targets a span within its lines
+// rather than whole lines.
+func (c Comment) HasColumns() bool {
+	return c.StartCol > 0
+}
+
+// Resolved reports whether the comment has been addressed.
+func (c Comment) Resolved() bool {
+	return c.Status == StatusResolved
 }
 
 type CommentFile struct {
-	Comments []Comment
+	Comments []Comment `json:"comments"`
+}
+
+// Find returns the comment with the given ID.
+func (cf *CommentFile) Find(id string) (*Comment, bool) {
+	for i := range cf.Comments {
+		if cf.Comments[i].ID == id {
+			return &cf.Comments[i], true
+		}
+	}
+	return nil, false
 }
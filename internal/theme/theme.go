@@ -0,0 +1,307 @@
+// Package theme defines the color schemes shared by the markdown renderer and
+// the TUI.
+package theme
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paulbuckley/mdmu/internal/xdg"
+	"go.yaml.in/yaml/v3"
+)
+
+// Theme is a color scheme. Colors are ANSI color numbers ("0"-"255") or hex
+// values ("#5f87d7"); an empty color leaves the terminal default.
+type Theme struct {
+	Name string `yaml:"-"`
+
+	// Rendered markdown
+	Heading1   string `yaml:"heading1"`
+	Heading2   string `yaml:"heading2"`
+	Heading3   string `yaml:"heading3"`
+	Heading4   string `yaml:"heading4"`
+	InlineCode string `yaml:"inline_code"`
+	CodeFg     string `yaml:"code_fg"`
+	CodeBg     string `yaml:"code_bg"`
+	Link       string `yaml:"link"`
+	Muted      string `yaml:"muted"` // rules, quote bars, link targets
+
+	// Interface
+	Accent      string `yaml:"accent"` // active borders and titles
+	Border      string `yaml:"border"` // inactive borders
+	CursorBg    string `yaml:"cursor_bg"`
+	SelectionBg string `yaml:"selection_bg"`
+	Text        string `yaml:"text"`
+	Subtle      string `yaml:"subtle"` // hints and empty states
+	StatusFg    string `yaml:"status_fg"`
+	StatusBg    string `yaml:"status_bg"`
+	StatusKey   string `yaml:"status_key"`
+}
+
+// Dark is the default theme, for dark terminal backgrounds.
+func Dark() *Theme {
+	return &Theme{
+		Name:        "dark",
+		Heading1:    "6",
+		Heading2:    "2",
+		Heading3:    "3",
+		Heading4:    "5",
+		InlineCode:  "3",
+		CodeFg:      "7",
+		CodeBg:      "236",
+		Link:        "6",
+		Muted:       "8",
+		Accent:      "62",
+		Border:      "240",
+		CursorBg:    "236",
+		SelectionBg: "24",
+		Text:        "252",
+		Subtle:      "243",
+		StatusFg:    "252",
+		StatusBg:    "236",
+		StatusKey:   "229",
+	}
+}
+
+// Light is a theme for light terminal backgrounds.
+func Light() *Theme {
+	return &Theme{
+		Name:        "light",
+		Heading1:    "25",
+		Heading2:    "28",
+		Heading3:    "130",
+		Heading4:    "90",
+		InlineCode:  "130",
+		CodeFg:      "235",
+		CodeBg:      "254",
+		Link:        "25",
+		Muted:       "245",
+		Accent:      "62",
+		Border:      "250",
+		CursorBg:    "254",
+		SelectionBg: "153",
+		Text:        "235",
+		Subtle:      "244",
+		StatusFg:    "235",
+		StatusBg:    "253",
+		StatusKey:   "25",
+	}
+}
+
+// HighContrast uses bright colors on black for maximum legibility.
+func HighContrast() *Theme {
+	return &Theme{
+		Name:        "high-contrast",
+		Heading1:    "14",
+		Heading2:    "10",
+		Heading3:    "11",
+		Heading4:    "13",
+		InlineCode:  "11",
+		CodeFg:      "15",
+		CodeBg:      "0",
+		Link:        "14",
+		Muted:       "7",
+		Accent:      "14",
+		Border:      "15",
+		CursorBg:    "238",
+		SelectionBg: "21",
+		Text:        "15",
+		Subtle:      "7",
+		StatusFg:    "15",
+		StatusBg:    "0",
+		StatusKey:   "11",
+	}
+}
+
+// NoColor leaves every color to the terminal, keeping only text attributes.
+// It is used when the NO_COLOR environment variable is set.
+func NoColor() *Theme {
+	return &Theme{Name: "no-color"}
+}
+
+var builtins = map[string]func() *Theme{
+	"dark":          Dark,
+	"light":         Light,
+	"high-contrast": HighContrast,
+	"no-color":      NoColor,
+}
+
+// Names returns the names of the built-in themes.
+func Names() []string {
+	return []string{"auto", "dark", "light", "high-contrast", "no-color"}
+}
+
+// Dir returns the directory searched for user theme files.
+func Dir() string {
+	return filepath.Join(xdg.ConfigDir(), "themes")
+}
+
+// Resolve returns the theme to use for a name. NO_COLOR overrides any
+// choice; "auto" (or "") picks dark or light from the terminal background.
+// Other names are built-in themes or files <name>.yaml in Dir, and paths
+// to theme files are accepted too.
+func Resolve(name string) (*Theme, error) {
+	if os.Getenv("NO_COLOR") != "" {
+		return NoColor(), nil
+	}
+	return Load(name)
+}
+
+// Load returns a theme by name without consulting NO_COLOR.
+func Load(name string) (*Theme, error) {
+	switch {
+	case name == "" || name == "auto":
+		if lipgloss.HasDarkBackground() {
+			return Dark(), nil
+		}
+		return Light(), nil
+	case builtins[name] != nil:
+		return builtins[name](), nil
+	}
+
+	path := name
+	if !strings.ContainsRune(name, os.PathSeparator) && filepath.Ext(name) == "" {
+		path = filepath.Join(Dir(), name+".yaml")
+	}
+	t, err := LoadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown theme %q (built-in themes: %s; user themes live in %s)",
+			name, strings.Join(Names(), ", "), Dir())
+	}
+	return t, err
+}
+
+// themeFile is the on-disk form of a theme: a base theme plus overrides.
+type themeFile struct {
+	Base  string `yaml:"base"`
+	Theme `yaml:",inline"`
+}
+
+// LoadFile reads a YAML theme file. Colors it leaves out are taken from the
+// theme named by its "base" key, or from Dark.
+func LoadFile(path string) (*Theme, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f themeFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("theme %s: %w", path, err)
+	}
+
+	base := Dark()
+	if f.Base != "" {
+		if builtins[f.Base] == nil {
+			return nil, fmt.Errorf("theme %s: unknown base theme %q", path, f.Base)
+		}
+		base = builtins[f.Base]()
+	}
+
+	t := merge(base, &f.Theme)
+	t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("theme %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// namedColor is a theme color with its key in theme files.
+type namedColor struct {
+	key   string
+	value *string
+}
+
+// colors returns pointers to the theme's colors, keyed as in theme files.
+func (t *Theme) colors() []namedColor {
+	return []namedColor{
+		{"heading1", &t.Heading1}, {"heading2", &t.Heading2},
+		{"heading3", &t.Heading3}, {"heading4", &t.Heading4},
+		{"inline_code", &t.InlineCode}, {"code_fg", &t.CodeFg},
+		{"code_bg", &t.CodeBg}, {"link", &t.Link}, {"muted", &t.Muted},
+		{"accent", &t.Accent}, {"border", &t.Border},
+		{"cursor_bg", &t.CursorBg}, {"selection_bg", &t.SelectionBg},
+		{"text", &t.Text}, {"subtle", &t.Subtle},
+		{"status_fg", &t.StatusFg}, {"status_bg", &t.StatusBg},
+		{"status_key", &t.StatusKey},
+	}
+}
+
+// merge returns base with every color set in override replacing its own.
+func merge(base, override *Theme) *Theme {
+	t := *base
+	dst, src := t.colors(), override.colors()
+	for i := range dst {
+		if *src[i].value != "" {
+			*dst[i].value = *src[i].value
+		}
+	}
+	return &t
+}
+
+// Validate reports the first color that is neither an ANSI color number nor
+// a hex value.
+func (t *Theme) Validate() error {
+	for _, c := range t.colors() {
+		if _, _, ok := parseColor(*c.value); !ok && *c.value != "" {
+			return fmt.Errorf("%s: invalid color %q (use 0-255 or #rrggbb)", c.key, *c.value)
+		}
+	}
+	return nil
+}
+
+// Fg returns the escape sequence setting the foreground to color, or "" for
+// an empty or invalid color.
+func Fg(color string) string {
+	return sequence(color, 30, 90, 38)
+}
+
+// Bg returns the escape sequence setting the background to color, or "" for
+// an empty or invalid color.
+func Bg(color string) string {
+	return sequence(color, 40, 100, 48)
+}
+
+// sequence builds an SGR sequence, using the short forms for the 16 basic
+// colors so they follow the terminal's own palette.
+func sequence(color string, basic, bright, extended int) string {
+	index, rgb, ok := parseColor(color)
+	switch {
+	case !ok:
+		return ""
+	case rgb != nil:
+		return fmt.Sprintf("\033[%d;2;%d;%d;%dm", extended, rgb[0], rgb[1], rgb[2])
+	case index < 8:
+		return fmt.Sprintf("\033[%dm", basic+index)
+	case index < 16:
+		return fmt.Sprintf("\033[%dm", bright+index-8)
+	default:
+		return fmt.Sprintf("\033[%d;5;%dm", extended, index)
+	}
+}
+
+// parseColor parses an ANSI color number or a #rrggbb hex color.
+func parseColor(color string) (index int, rgb []int, ok bool) {
+	if strings.HasPrefix(color, "#") {
+		hex := color[1:]
+		if len(hex) != 6 {
+			return 0, nil, false
+		}
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return 0, nil, false
+		}
+		return 0, []int{int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff)}, true
+	}
+	n, err := strconv.Atoi(color)
+	if err != nil || n < 0 || n > 255 {
+		return 0, nil, false
+	}
+	return n, nil, true
+}
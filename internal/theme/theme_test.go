@@ -0,0 +1,93 @@
+package theme
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFgBg(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"basic fg", Fg("6"), "\033[36m"},
+		{"bright fg", Fg("8"), "\033[90m"},
+		{"256 fg", Fg("62"), "\033[38;5;62m"},
+		{"hex fg", Fg("#ff8000"), "\033[38;2;255;128;0m"},
+		{"256 bg", Bg("236"), "\033[48;5;236m"},
+		{"empty", Fg(""), ""},
+		{"invalid", Fg("blue"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "solar.yaml")
+	if err := os.WriteFile(path, []byte("base: light\nheading1: \"#268bd2\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	th, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if th.Name != "solar" {
+		t.Errorf("Name = %q, want %q", th.Name, "solar")
+	}
+	if th.Heading1 != "#268bd2" {
+		t.Errorf("Heading1 = %q, want override %q", th.Heading1, "#268bd2")
+	}
+	if th.CodeBg != Light().CodeBg {
+		t.Errorf("CodeBg = %q, want base value %q", th.CodeBg, Light().CodeBg)
+	}
+}
+
+func TestLoadFileInvalidColor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.yaml")
+	if err := os.WriteFile(path, []byte("link: bluish\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFile(path)
+	if err == nil || !strings.Contains(err.Error(), "link") {
+		t.Errorf("expected an error naming the invalid key, got %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	t.Run("builtin", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "")
+		th, err := Resolve("high-contrast")
+		if err != nil || th.Name != "high-contrast" {
+			t.Errorf("Resolve(high-contrast) = %v, %v", th, err)
+		}
+	})
+
+	t.Run("NO_COLOR wins", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		th, err := Resolve("dark")
+		if err != nil || th.Name != "no-color" {
+			t.Errorf("Resolve with NO_COLOR = %v, %v", th, err)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "")
+		if _, err := Resolve("nope"); err == nil {
+			t.Error("expected an error for an unknown theme")
+		}
+	})
+}
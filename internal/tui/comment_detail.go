@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// openCommentDetail shows the focused comment in full: all of the text it
+// targets, its body and any replies.
+func (m Model) openCommentDetail() Model {
+	if _, ok := m.focusedComment(); !ok {
+		return m
+	}
+	m.detailScroll = 0
+	m.mode = modeDetail
+	return m
+}
+
+// focusedComment returns the comment focused in the comments pane.
+func (m Model) focusedComment() (store.Comment, bool) {
+	sorted := m.listedComments()
+	if m.commentCursor >= len(sorted) {
+		return store.Comment{}, false
+	}
+	return sorted[m.commentCursor], true
+}
+
+func (m Model) handleDetailKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	k, ok := m.keySequence(msg)
+	if !ok {
+		return m, nil
+	}
+
+	maxScroll := max(len(m.detailLines())-m.previewHeight(), 0)
+	switch {
+	case key.Matches(k, m.keys.Quit):
+		return m, tea.Quit
+	case key.Matches(k, m.keys.Help):
+		return m.openHelp(), nil
+	case key.Matches(k, m.keys.Expand, m.keys.Cancel):
+		m.mode = modeNormal
+	case key.Matches(k, m.keys.Up):
+		m.detailScroll--
+	case key.Matches(k, m.keys.Down):
+		m.detailScroll++
+	case key.Matches(k, m.keys.PageUp):
+		m.detailScroll -= m.previewHeight()
+	case key.Matches(k, m.keys.PageDown):
+		m.detailScroll += m.previewHeight()
+	case key.Matches(k, m.keys.Top):
+		m.detailScroll = 0
+	case key.Matches(k, m.keys.Bottom):
+		m.detailScroll = maxScroll
+	}
+	m.detailScroll = max(min(m.detailScroll, maxScroll), 0)
+	return m, nil
+}
+
+// detailLines renders the focused comment in full, followed by its
+// replies.
+func (m Model) detailLines() []string {
+	c, ok := m.focusedComment()
+	if !ok {
+		return nil
+	}
+	width := max(m.width-4, 10)
+
+	lines := m.styles.commentBlock(c, width, 0)
+	if !c.CreatedAt.IsZero() {
+		lines[0] += m.styles.commentLineRef.Render(" · " + c.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	for _, r := range c.Replies {
+		header := "↩ " + r.Author
+		if !r.CreatedAt.IsZero() {
+			header += " · " + r.CreatedAt.Local().Format("2006-01-02 15:04")
+		}
+		lines = append(lines, "", m.styles.commentHeader.Render(header))
+		for _, row := range wrapText(r.Body, width-2) {
+			lines = append(lines, "  "+m.styles.commentText.Render(row))
+		}
+	}
+	return lines
+}
+
+func (m Model) renderCommentDetail() string {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+
+	all := m.detailLines()
+	height := m.previewHeight()
+	lines := all[min(m.detailScroll, len(all)):]
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+
+	label := fmt.Sprintf("Comment %d of %d", m.commentCursor+1, len(m.listedComments()))
+	if len(all) > height {
+		label += fmt.Sprintf(" (%d-%d of %d lines)", m.detailScroll+1, min(m.detailScroll+height, len(all)), len(all))
+	}
+	title := m.styles.previewTitle.Render(label)
+	bordered := m.styles.activeBorder.Width(width - 2).Render(title + "\n" + strings.Join(lines, "\n"))
+
+	km := m.keys
+	hints := " " + m.styles.formatHints(
+		pairHint(km.Up, km.Down, "scroll"),
+		pairHint(km.PageUp, km.PageDown, "page"),
+		pairHint(km.Expand, km.Cancel, "close"))
+	return bordered + "\n" + m.styles.statusBar.Width(width).Render(hints)
+}
@@ -4,36 +4,45 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/google/uuid"
+	"github.com/paulbuckley/mdmu/internal/markdown"
 	"github.com/paulbuckley/mdmu/internal/store"
 )
 
-func newCommentTextarea() textarea.Model {
+func newCommentTextarea(height int) textarea.Model {
+	if height <= 0 {
+		height = 3
+	}
 	ta := textarea.New()
 	ta.Placeholder = ""
 	ta.ShowLineNumbers = false
-	ta.SetHeight(3)
+	ta.SetHeight(height)
 	return ta
 }
 
 func (m Model) handleCommentInput(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "esc":
+		k := keySeq(msg.String())
+		switch {
+		case k.isText():
+			// Typed text never triggers an action
+
+		case key.Matches(k, m.keys.Cancel):
 			m.mode = modeNormal
 			return m, nil
 
-		case "alt+enter":
+		case key.Matches(k, m.keys.Newline):
 			// Insert a newline into the textarea
 			enterMsg := tea.KeyMsg{Type: tea.KeyEnter}
 			var cmd tea.Cmd
 			m.textarea, cmd = m.textarea.Update(enterMsg)
 			return m, cmd
 
-		case "enter":
+		case key.Matches(k, m.keys.Confirm):
 			// Save the comment
 			comment := m.textarea.Value()
 			if comment == "" {
@@ -41,27 +50,19 @@ func (m Model) handleCommentInput(msg tea.Msg) (Model, tea.Cmd) {
 				return m, nil
 			}
 
-			// Map rendered line selection to source lines
-			selStart, selEnd := m.selectionRange()
-			sourceStart, sourceEnd := m.renderedToSourceRange(selStart, selEnd)
-
-			// Extract selected text
-			selectedText := m.extractSourceText(sourceStart, sourceEnd)
-
-			c := store.Comment{
-				ID:           uuid.New().String(),
-				SourceStart:  sourceStart,
-				SourceEnd:    sourceEnd,
-				SelectedText: selectedText,
-				Comment:      comment,
-				CreatedAt:    time.Now(),
-			}
-
-			m.commentFile.Comments = append(m.commentFile.Comments, c)
+			// Map the selection to source lines and text
+			c := m.commentTarget()
+			c.ID = uuid.New().String()
+			c.Comment = comment
+			c.CreatedAt = time.Now()
 
 			m.mode = modeNormal
 			m.selectionStart = -1
+			m.wordAnchorLine = -1
 			m.statusMessage = "" // Clear status message when adding comment
+			m.updateComments(func(cf *store.CommentFile) {
+				cf.Comments = append(cf.Comments, c)
+			})
 			return m, nil
 		}
 	}
@@ -72,10 +73,13 @@ func (m Model) handleCommentInput(msg tea.Msg) (Model, tea.Cmd) {
 }
 
 func (m Model) renderCommentInput() string {
-	selStart, selEnd := m.selectionRange()
-	sourceStart, sourceEnd := m.renderedToSourceRange(selStart, selEnd)
+	target := m.commentTarget()
+	label := fmt.Sprintf("Comment on lines %d-%d", target.SourceStart, target.SourceEnd)
+	if target.HasColumns() {
+		label = fmt.Sprintf("Comment on %d:%d-%d:%d", target.SourceStart, target.StartCol, target.SourceEnd, target.EndCol)
+	}
 
-	title := modalTitleStyle.Render(fmt.Sprintf("Comment on lines %d-%d", sourceStart, sourceEnd))
+	title := m.styles.modalTitle.Render(label)
 	ta := m.textarea.View()
 
 	content := title + "\n" + ta
@@ -85,7 +89,46 @@ func (m Model) renderCommentInput() string {
 		inputWidth = 20
 	}
 
-	return modalStyle.Width(inputWidth).Render(content)
+	return m.styles.modal.Width(inputWidth).Render(content)
+}
+
+// commentTarget maps the current selection to the source range and text a
+// new comment would target.
+func (m Model) commentTarget() store.Comment {
+	if m.wordMode {
+		if start, end, ok := m.wordSourceSpan(); ok {
+			startLine, startCol := markdown.Position(m.source, start)
+			endLine, endCol := markdown.Position(m.source, end-1)
+			return store.Comment{
+				SourceStart:  startLine,
+				SourceEnd:    endLine,
+				StartCol:     startCol,
+				EndCol:       endCol,
+				SelectedText: string(m.source[start:end]),
+			}
+		}
+	}
+
+	selStart, selEnd := m.selectionRange()
+	sourceStart, sourceEnd := m.renderedToSourceRange(selStart, selEnd)
+	return store.Comment{
+		SourceStart:  sourceStart,
+		SourceEnd:    sourceEnd,
+		SelectedText: m.extractSourceText(sourceStart, sourceEnd),
+	}
+}
+
+// targetLines returns the source lines a new comment would target, without
+// the cost of extracting their text.
+func (m Model) targetLines() (int, int) {
+	if m.wordMode {
+		if start, end, ok := m.wordSourceSpan(); ok {
+			startLine, _ := markdown.Position(m.source, start)
+			endLine, _ := markdown.Position(m.source, end-1)
+			return startLine, endLine
+		}
+	}
+	return m.renderedToSourceRange(m.selectionRange())
 }
 
 func (m Model) renderedToSourceRange(renderedStart, renderedEnd int) (int, int) {
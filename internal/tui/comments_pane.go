@@ -5,81 +5,152 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/charmbracelet/x/ansi"
 	"github.com/mattn/go-runewidth"
 	"github.com/paulbuckley/mdmu/internal/store"
 )
 
+// maxQuoteLines is how many lines of a comment's selected text the
+// comments pane quotes. The detail view quotes it all.
+const maxQuoteLines = 2
+
 func (m Model) renderCommentsPane() string {
 	width := m.rightWidth()
-	height := m.contentHeight()
+	height := m.commentsHeight()
 
 	if len(m.commentFile.Comments) == 0 {
-		content := emptyStateStyle.Render("No comments yet\nSelect lines and press C")
+		content := m.styles.emptyState.Render("No comments yet\nSelect lines and press C")
+		return m.commentsPaneBorder(width, height, content)
+	}
+	if len(m.listedComments()) == 0 {
+		content := m.styles.emptyState.Render("No comments match the filter")
 		return m.commentsPaneBorder(width, height, content)
 	}
 
-	// Sort comments by source line
-	sorted := m.sortedComments()
+	rows, starts := m.commentRows(width)
+	top := m.commentTop(starts, height)
+	visibleLines := rows[top:min(top+height, len(rows))]
 
-	var visibleLines []string
-	lineIdx := 0
+	// Pad remaining height
+	for len(visibleLines) < height {
+		visibleLines = append(visibleLines, "")
+	}
 
-	for i, c := range sorted {
-		// Header: line range
-		var header string
-		if c.SourceStart == c.SourceEnd {
-			header = commentHeaderStyle.Render(fmt.Sprintf("L%d", c.SourceStart))
-		} else {
-			header = commentHeaderStyle.Render(fmt.Sprintf("L%d-%d", c.SourceStart, c.SourceEnd))
-		}
+	content := strings.Join(visibleLines, "\n")
+	return m.commentsPaneBorder(width, height, content)
+}
 
-		// Truncate comment text for display
-		text := c.Comment
-		maxTextWidth := width - 6
-		if maxTextWidth < 10 {
-			maxTextWidth = 10
-		}
-		if runewidth.StringWidth(text) > maxTextWidth {
-			text = runewidth.Truncate(text, maxTextWidth, "...")
+// commentRows lays out the comments in source order, separated by rules.
+// starts holds the first row of each comment, then the number of rows.
+func (m Model) commentRows(width int) ([]string, []int) {
+	textWidth := max(width-4, 10)
+
+	var rows []string
+	var starts []int
+	for i, c := range m.listedComments() {
+		if i > 0 {
+			rows = append(rows, m.styles.commentLineRef.Render(strings.Repeat("─", textWidth)))
 		}
+		starts = append(starts, len(rows))
 
-		commentLine := header + " " + commentTextStyle.Render(text)
+		block := m.styles.commentBlock(c, textWidth, maxQuoteLines)
 
 		// Highlight if this comment is focused
 		if m.focusPane == paneComments && i == m.commentCursor {
-			commentLine = commentHighlightStyle.Width(width - 4).Render(commentLine)
+			for j, line := range block {
+				block[j] = m.styles.commentHighlight.Width(textWidth).Render(line)
+			}
 		}
+		rows = append(rows, block...)
+	}
+	return rows, append(starts, len(rows))
+}
 
-		if lineIdx >= m.commentScrollOffset && lineIdx < m.commentScrollOffset+height {
-			visibleLines = append(visibleLines, commentLine)
-		}
-		lineIdx++
+// commentBlock renders a comment as a header, the start of the text it
+// targets, quoting at most maxQuote lines (0 for all), and its body wrapped
+// to width.
+func (s styles) commentBlock(c store.Comment, width, maxQuote int) []string {
+	// Header: line range
+	var header string
+	if c.SourceStart == c.SourceEnd {
+		header = s.commentHeader.Render(fmt.Sprintf("L%d", c.SourceStart))
+	} else {
+		header = s.commentHeader.Render(fmt.Sprintf("L%d-%d", c.SourceStart, c.SourceEnd))
+	}
 
-		// Add separator between comments
-		if i < len(sorted)-1 {
-			sep := commentLineRefStyle.Render(strings.Repeat("─", width-6))
-			if lineIdx >= m.commentScrollOffset && lineIdx < m.commentScrollOffset+height {
-				visibleLines = append(visibleLines, sep)
-			}
-			lineIdx++
+	// Resolved comments and replies come from agents via the MCP server
+	if c.Resolved() {
+		header = s.commentLineRef.Render("✓") + " " + header
+	}
+	if n := len(c.Replies); n > 0 {
+		header += s.commentLineRef.Render(fmt.Sprintf(" ↩%d", n))
+	}
+	lines := []string{header}
+
+	quote := strings.Split(strings.TrimRight(c.SelectedText, "\n"), "\n")
+	if maxQuote > 0 && len(quote) > maxQuote {
+		quote = append(quote[:maxQuote-1], "…")
+	}
+	for _, line := range quote {
+		if strings.TrimSpace(line) == "" && len(quote) == 1 {
+			break
+		}
+		if maxQuote > 0 {
+			line = runewidth.Truncate(line, width-2, "…")
+			lines = append(lines, s.commentLineRef.Render("│ "+line))
+			continue
+		}
+		for _, row := range wrapText(line, width-2) {
+			lines = append(lines, s.commentLineRef.Render("│ "+row))
 		}
 	}
 
-	// Pad remaining height
-	for len(visibleLines) < height {
-		visibleLines = append(visibleLines, "")
+	for _, row := range wrapText(c.Comment, width) {
+		lines = append(lines, s.commentText.Render(row))
 	}
+	return lines
+}
 
-	content := strings.Join(visibleLines, "\n")
-	return m.commentsPaneBorder(width, height, content)
+// wrapText wraps text to width, breaking words longer than a line, and
+// returns the rows.
+func wrapText(text string, width int) []string {
+	text = strings.ReplaceAll(text, "\t", "    ")
+	return strings.Split(ansi.Wrap(text, max(width, 1), ""), "\n")
+}
+
+// commentTop returns the first row of the comments pane to show: the
+// scroll offset, moved just enough to bring the focused comment into view.
+func (m Model) commentTop(starts []int, height int) int {
+	top := m.commentScrollOffset
+	if m.commentCursor+1 < len(starts) {
+		start, end := starts[m.commentCursor], starts[m.commentCursor+1]
+		if end > top+height {
+			top = end - height
+		}
+		if start < top {
+			top = start
+		}
+	}
+	return max(min(top, starts[len(starts)-1]-height), 0)
+}
+
+// scrollComments scrolls the comments pane to keep the focused comment in
+// view.
+func (m *Model) scrollComments() {
+	_, starts := m.commentRows(m.rightWidth())
+	m.commentScrollOffset = m.commentTop(starts, m.commentsHeight())
 }
 
 func (m Model) commentsPaneBorder(width, height int, content string) string {
-	title := paneTitle.Render(fmt.Sprintf("Comments (%d)", len(m.commentFile.Comments)))
+	label := fmt.Sprintf("Comments (%d)", len(m.commentFile.Comments))
+	if m.filter.active() {
+		label = fmt.Sprintf("Comments (%d of %d · %s)", len(m.listedComments()), len(m.commentFile.Comments), m.filter.query)
+	}
+	title := m.styles.paneTitle.Render(ansi.Truncate(label, width-4, "…"))
 
-	style := inactiveBorderStyle
+	style := m.styles.inactiveBorder
 	if m.focusPane == paneComments {
-		style = activeBorderStyle
+		style = m.styles.activeBorder
 	}
 
 	return style.Width(width - 2).Render(title + "\n" + content)
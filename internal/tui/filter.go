@@ -0,0 +1,237 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// commentFilter selects the comments the comments pane lists. A comment
+// must match every term of the query.
+type commentFilter struct {
+	query    string
+	words    []string // in the comment, its selected text or a reply
+	from, to int      // lines the comment must overlap; 0 means any
+	sections []string // in the headings enclosing the comment
+	status   string   // "open", "resolved" or "replied"
+}
+
+// filterStatuses are the values of the is: term.
+var filterStatuses = []string{"open", "resolved", "replied"}
+
+// parseFilter parses a filter query of space-separated terms: L12 or L10-20
+// for comments overlapping those lines, section:word for comments under a
+// heading containing word, is:open, is:resolved or is:replied, and words
+// the comment's text must contain. Words ignore case unless they have
+// capitals.
+func parseFilter(query string) (commentFilter, error) {
+	f := commentFilter{query: strings.TrimSpace(query)}
+	for _, term := range strings.Fields(query) {
+		lower := strings.ToLower(term)
+		switch {
+		case strings.HasPrefix(lower, "is:"):
+			f.status = lower[len("is:"):]
+			if !slices.Contains(filterStatuses, f.status) {
+				return f, fmt.Errorf("unknown status %q (available: %s)", f.status, strings.Join(filterStatuses, ", "))
+			}
+		case strings.HasPrefix(lower, "section:"):
+			if s := term[len("section:"):]; s != "" {
+				f.sections = append(f.sections, s)
+			}
+		default:
+			if from, to, ok := parseLineRange(term); ok {
+				f.from, f.to = from, to
+				continue
+			}
+			f.words = append(f.words, term)
+		}
+	}
+	return f, nil
+}
+
+// parseLineRange parses L12 or L10-20, as the comments pane labels comments.
+func parseLineRange(term string) (int, int, bool) {
+	if len(term) < 2 || (term[0] != 'L' && term[0] != 'l') {
+		return 0, 0, false
+	}
+	first, last, found := strings.Cut(term[1:], "-")
+	from, err := strconv.Atoi(first)
+	if err != nil || from < 1 {
+		return 0, 0, false
+	}
+	to := from
+	if found {
+		if to, err = strconv.Atoi(last); err != nil || to < from {
+			return 0, 0, false
+		}
+	}
+	return from, to, true
+}
+
+func (f commentFilter) active() bool {
+	return f.query != ""
+}
+
+// matches reports whether a comment passes the filter. section is the
+// breadcrumb of headings enclosing the comment.
+func (f commentFilter) matches(c store.Comment, section []string) bool {
+	if f.from > 0 && (c.SourceEnd < f.from || c.SourceStart > f.to) {
+		return false
+	}
+	switch f.status {
+	case "open":
+		if c.Resolved() {
+			return false
+		}
+	case "resolved":
+		if !c.Resolved() {
+			return false
+		}
+	case "replied":
+		if len(c.Replies) == 0 {
+			return false
+		}
+	}
+
+	text := []string{c.Comment, c.SelectedText}
+	for _, r := range c.Replies {
+		text = append(text, r.Body)
+	}
+	for _, w := range f.words {
+		if !containsWord(text, w) {
+			return false
+		}
+	}
+	for _, s := range f.sections {
+		if !containsWord(section, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsWord reports whether any of texts contains w, ignoring case unless
+// w has capitals.
+func containsWord(texts []string, w string) bool {
+	fold := !strings.ContainsFunc(w, unicode.IsUpper)
+	for _, text := range texts {
+		if fold {
+			text = strings.ToLower(text)
+		}
+		if strings.Contains(text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// listedComments returns the comments the comments pane lists: those
+// passing the filter, in source order.
+func (m Model) listedComments() []store.Comment {
+	sorted := m.sortedComments()
+	if !m.filter.active() {
+		return sorted
+	}
+	var listed []store.Comment
+	for _, c := range sorted {
+		if m.filter.matches(c, m.commentSection(c)) {
+			listed = append(listed, c)
+		}
+	}
+	return listed
+}
+
+// commentSection returns the headings enclosing a comment, counting a
+// comment on a heading as inside it.
+func (m Model) commentSection(c store.Comment) []string {
+	if len(m.filter.sections) == 0 {
+		return nil
+	}
+	return markdown.Breadcrumb(m.headings, c.SourceStart+1)
+}
+
+// commentMarks returns the source lines with comments, true where a listed
+// comment covers the line and false where only comments the filter hides do.
+func (m Model) commentMarks() map[int]bool {
+	marks := map[int]bool{}
+	for _, c := range m.commentFile.Comments {
+		listed := !m.filter.active() || m.filter.matches(c, m.commentSection(c))
+		for line := c.SourceStart; line <= c.SourceEnd; line++ {
+			marks[line] = marks[line] || listed
+		}
+	}
+	return marks
+}
+
+// gutter returns the marker shown beside a rendered line for the comments
+// on its source lines.
+func (s styles) gutter(marks map[int]bool, mapping markdown.LineMapping) string {
+	marked, listed := false, false
+	for line := mapping.SourceStart; line <= mapping.SourceEnd && line > 0; line++ {
+		if l, ok := marks[line]; ok {
+			marked, listed = true, listed || l
+		}
+	}
+	switch {
+	case listed:
+		return s.commentHeader.Render("▎")
+	case marked:
+		return s.commentLineRef.Render("▎")
+	}
+	return " "
+}
+
+func (m Model) startFilter() (Model, tea.Cmd) {
+	m.mode = modeFiltering
+	m.filterInput = textinput.New()
+	m.filterInput.Prompt = "Filter: "
+	m.filterInput.Placeholder = "words, L10-20, section:word, is:open"
+	m.filterInput.SetValue(m.filter.query)
+	m.filterInput.CursorEnd()
+	return m, m.filterInput.Focus()
+}
+
+// handleFilterInput edits the filter, applying it as it is typed.
+func (m Model) handleFilterInput(msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := keySeq(msg.String())
+	switch {
+	case k.isText():
+
+	case key.Matches(k, m.keys.Cancel):
+		m.mode = modeNormal
+		m.setFilter(commentFilter{})
+		return m, nil
+
+	case key.Matches(k, m.keys.Confirm):
+		m.mode = modeNormal
+		if _, err := parseFilter(m.filterInput.Value()); err != nil {
+			m.statusMessage = "✗ " + err.Error()
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	if f, err := parseFilter(m.filterInput.Value()); err == nil {
+		m.setFilter(f)
+	}
+	return m, cmd
+}
+
+// setFilter changes the filter, focusing the first comment it lists.
+func (m *Model) setFilter(f commentFilter) {
+	if len(f.sections) > 0 && m.headings == nil {
+		m.headings = markdown.Headings(m.source)
+	}
+	m.filter = f
+	m.commentCursor = 0
+	m.commentScrollOffset = 0
+}
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/paulbuckley/mdmu/internal/markdown"
+)
+
+// toggleFold unfolds the folded region at the cursor, or folds the
+// innermost section, code block or list containing the cursor's line.
+func (m Model) toggleFold() Model {
+	if m.cursor < len(m.doc.Mappings) {
+		row := m.doc.Mappings[m.cursor]
+		for i, g := range m.folds {
+			if g.Start == row.SourceStart && g.End == row.SourceEnd {
+				folds := append(m.folds[:i:i], m.folds[i+1:]...)
+				return m.setFolds(folds)
+			}
+		}
+	}
+
+	line := m.sourceLine(m.cursor)
+	var inner markdown.Region
+	found := false
+	for _, g := range m.regions() {
+		if g.Contains(line) && (!found || g.End-g.Start < inner.End-inner.Start) {
+			inner, found = g, true
+		}
+	}
+	if !found {
+		m.statusMessage = "Nothing to fold here"
+		return m
+	}
+	return m.setFolds(append(m.folds[:len(m.folds):len(m.folds)], inner))
+}
+
+// foldLevel folds every section with a heading at the given level, and
+// unfolds everything else.
+func (m Model) foldLevel(level int) Model {
+	var folds []markdown.Region
+	for _, g := range m.regions() {
+		if g.Kind == markdown.RegionSection && g.Level == level {
+			folds = append(folds, g)
+		}
+	}
+	if len(folds) == 0 {
+		m.statusMessage = fmt.Sprintf("No level %d headings", level)
+		return m
+	}
+	return m.setFolds(folds)
+}
+
+// regions returns the regions of the document that can be folded.
+func (m *Model) regions() []markdown.Region {
+	return m.document().Regions()
+}
+
+// setFolds folds the given regions, keeping the cursor on the same source
+// line, or on the row of the region that now hides it.
+func (m Model) setFolds(folds []markdown.Region) Model {
+	line := m.sourceLine(m.cursor)
+	m.clearSelection()
+	m.wordAnchorLine = -1
+	m.folds = folds
+	m.reRender()
+	m.cursor = firstRow(m.doc, line)
+	if m.wordMode {
+		m.wordCol = m.snapWordCol(m.cursor, 0)
+	}
+	m.ensureCursorVisible()
+	return m
+}
@@ -0,0 +1,48 @@
+package tui
+
+// jumpFootnote moves from a footnote reference on the cursor line to its
+// definition, or from a definition back to the reference it was reached from.
+func (m *Model) jumpFootnote() {
+	for _, ref := range m.doc.FootnoteRefs {
+		if ref.RenderedLine != m.cursor {
+			continue
+		}
+		if line, ok := m.doc.FootnoteDefs[ref.Index]; ok {
+			m.footnoteReturn = ref
+			m.cursor = line
+			m.ensureCursorVisible()
+			return
+		}
+	}
+
+	index, ok := m.footnoteAtCursor()
+	if !ok {
+		m.statusMessage = "No footnote on this line"
+		return
+	}
+	if m.footnoteReturn.Index == index && m.footnoteReturn.RenderedLine < len(m.doc.Lines) {
+		m.cursor = m.footnoteReturn.RenderedLine
+		m.ensureCursorVisible()
+		return
+	}
+	for _, ref := range m.doc.FootnoteRefs {
+		if ref.Index == index {
+			m.cursor = ref.RenderedLine
+			m.ensureCursorVisible()
+			return
+		}
+	}
+	m.statusMessage = "Footnote is never referenced"
+}
+
+// footnoteAtCursor returns the index of the footnote definition containing
+// the cursor. Each definition runs until the next one starts.
+func (m Model) footnoteAtCursor() (int, bool) {
+	index, start := 0, -1
+	for i, line := range m.doc.FootnoteDefs {
+		if line <= m.cursor && line > start {
+			index, start = i, line
+		}
+	}
+	return index, start >= 0
+}
@@ -0,0 +1,166 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-runewidth"
+)
+
+// helpSection is a group of bindings listed together on the help screen.
+type helpSection struct {
+	title    string
+	bindings []key.Binding
+}
+
+func (m Model) helpSections() []helpSection {
+	km := m.keys
+	return []helpSection{
+		{"Markdown pane", []key.Binding{km.Up, km.Down, km.PageUp, km.PageDown, km.Top, km.Bottom,
+			km.SelectUp, km.SelectDown, km.SelectLines, km.Comment, km.Footnote,
+			km.FollowLink, km.JumpBack, km.JumpForward, km.Search, km.NextMatch, km.PrevMatch,
+			km.WordMode, km.ToggleView, km.Fold, km.FoldLevel, km.UnfoldAll, km.Cancel}},
+		{"Word mode", []key.Binding{km.WordLeft, km.WordRight, km.SelectWordLeft, km.SelectWordRight,
+			km.SelectUp, km.SelectDown, km.Comment, km.WordMode, km.Cancel}},
+		{"Comments pane", []key.Binding{km.Up, km.Down, km.Delete, km.Expand, km.Filter, km.Cancel}},
+		{"Comment detail", []key.Binding{km.Up, km.Down, km.PageUp, km.PageDown, km.Top, km.Bottom, km.Expand, km.Cancel}},
+		{"Comment input", []key.Binding{km.Confirm, km.Newline, km.Cancel}},
+		{"Preview", []key.Binding{km.Up, km.Down, km.PageUp, km.PageDown, km.Top, km.Bottom, km.Format, km.Copy, km.Submit, km.Cancel}},
+		{"Everywhere", []key.Binding{km.SwitchPane, km.ShrinkPane, km.GrowPane, km.ToggleComments,
+			km.ToggleStacked, km.Preview, km.Copy, km.Submit, km.Approve, km.Help, km.Quit}},
+	}
+}
+
+// openHelp shows the help overlay, returning to the current mode when it
+// is closed.
+func (m Model) openHelp() Model {
+	m.helpReturn = m.mode
+	m.helpScroll = 0
+	m.mode = modeHelp
+	return m
+}
+
+func (m Model) handleHelpKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
+	k, ok := m.keySequence(msg)
+	if !ok {
+		return m, nil
+	}
+
+	maxScroll := max(len(m.helpLines())-m.previewHeight(), 0)
+	switch {
+	case key.Matches(k, m.keys.Help, m.keys.Cancel, m.keys.Quit):
+		m.mode = m.helpReturn
+	case key.Matches(k, m.keys.Up):
+		m.helpScroll--
+	case key.Matches(k, m.keys.Down):
+		m.helpScroll++
+	case key.Matches(k, m.keys.PageUp):
+		m.helpScroll -= m.previewHeight()
+	case key.Matches(k, m.keys.PageDown):
+		m.helpScroll += m.previewHeight()
+	case key.Matches(k, m.keys.Top):
+		m.helpScroll = 0
+	case key.Matches(k, m.keys.Bottom):
+		m.helpScroll = maxScroll
+	}
+	m.helpScroll = max(min(m.helpScroll, maxScroll), 0)
+	return m, nil
+}
+
+// helpContext returns the title of the help section for what is focused
+// when help was opened.
+func (m Model) helpContext() string {
+	switch {
+	case m.helpReturn == modePreview:
+		return "Preview"
+	case m.helpReturn == modeDetail:
+		return "Comment detail"
+	case m.focusPane == paneComments:
+		return "Comments pane"
+	case m.wordMode:
+		return "Word mode"
+	default:
+		return "Markdown pane"
+	}
+}
+
+// sessionLines describes the current session for the help overlay.
+func (m Model) sessionLines() []string {
+	saved := "not saved (comments last for this session)"
+	if m.opts.StorePath != "" {
+		saved = m.opts.StorePath
+	}
+	rows := [][2]string{
+		{"File", m.filename},
+		{"Comments", fmt.Sprintf("%d", len(m.commentFile.Comments))},
+		{"Saved to", saved},
+	}
+
+	lines := []string{m.styles.commentHeader.Render("Session")}
+	for _, row := range rows {
+		lines = append(lines, "  "+m.styles.commentLineRef.Render(runewidth.FillRight(row[0], 10))+m.styles.commentText.Render(row[1]))
+	}
+	return append(lines, "")
+}
+
+// helpLines renders the session status followed by the help sections, one
+// binding per line. The section for the current context is marked.
+func (m Model) helpLines() []string {
+	keyWidth := 0
+	for _, section := range m.helpSections() {
+		for _, b := range section.bindings {
+			keyWidth = max(keyWidth, runewidth.StringWidth(b.Help().Key))
+		}
+	}
+
+	lines := m.sessionLines()
+	for _, section := range m.helpSections() {
+		title := m.styles.commentHeader.Render(section.title)
+		if section.title == m.helpContext() {
+			title += m.styles.commentLineRef.Render("  (current)")
+		}
+		lines = append(lines, title)
+		for _, b := range section.bindings {
+			if !b.Enabled() {
+				continue
+			}
+			label := runewidth.FillRight(b.Help().Key, keyWidth)
+			lines = append(lines, "  "+m.styles.statusKey.Render(label)+"  "+m.styles.commentText.Render(b.Help().Desc))
+		}
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+func (m Model) renderHelp() string {
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+
+	all := m.helpLines()
+	height := m.previewHeight()
+	lines := all[min(m.helpScroll, len(all)):]
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+
+	label := "Help"
+	if len(all) > height {
+		label = fmt.Sprintf("Help (%d-%d of %d)", m.helpScroll+1, min(m.helpScroll+height, len(all)), len(all))
+	}
+	title := m.styles.previewTitle.Render(label)
+	bordered := m.styles.activeBorder.Width(width - 2).Render(title + "\n" + strings.Join(lines, "\n"))
+
+	km := m.keys
+	hints := " " + m.styles.formatHints(
+		pairHint(km.Up, km.Down, "scroll"),
+		pairHint(km.PageUp, km.PageDown, "page"),
+		pairHint(km.Help, km.Cancel, "close"))
+	return bordered + "\n" + m.styles.statusBar.Width(width).Render(hints)
+}
@@ -0,0 +1,387 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap holds the key bindings of every action. A binding's keys may be
+// sequences of space-separated keys, such as "g g".
+type KeyMap struct {
+	// Markdown pane
+	Up          key.Binding
+	Down        key.Binding
+	SelectUp    key.Binding
+	SelectDown  key.Binding
+	SelectLines key.Binding
+	PageUp      key.Binding
+	PageDown    key.Binding
+	Top         key.Binding
+	Bottom      key.Binding
+	Comment     key.Binding
+	Footnote    key.Binding
+	FollowLink  key.Binding
+	JumpBack    key.Binding
+	JumpForward key.Binding
+	Search      key.Binding
+	NextMatch   key.Binding
+	PrevMatch   key.Binding
+	ToggleView  key.Binding
+	Fold        key.Binding
+	FoldLevel   key.Binding
+	UnfoldAll   key.Binding
+
+	// Word mode
+	WordMode        key.Binding
+	WordLeft        key.Binding
+	WordRight       key.Binding
+	SelectWordLeft  key.Binding
+	SelectWordRight key.Binding
+
+	// Comments pane
+	Delete key.Binding
+	Expand key.Binding
+	Filter key.Binding
+
+	// Text input
+	Confirm key.Binding
+	Newline key.Binding
+
+	// Preview
+	Format key.Binding
+
+	// Everywhere
+	Cancel         key.Binding
+	SwitchPane     key.Binding
+	ShrinkPane     key.Binding
+	GrowPane       key.Binding
+	ToggleComments key.Binding
+	ToggleStacked  key.Binding
+	Preview        key.Binding
+	Copy           key.Binding
+	Submit         key.Binding
+	Approve        key.Binding
+	Help           key.Binding
+	Quit           key.Binding
+}
+
+// Keymaps lists the built-in presets.
+var Keymaps = []string{"default", "vim", "emacs"}
+
+// DefaultKeyMap returns the default bindings.
+func DefaultKeyMap() KeyMap {
+	return KeyMap{
+		Up:          binding("move up", "up"),
+		Down:        binding("move down", "down"),
+		SelectUp:    binding("extend selection up", "shift+up"),
+		SelectDown:  binding("extend selection down", "shift+down"),
+		SelectLines: binding("toggle line selection"),
+		PageUp:      binding("page up", "pgup"),
+		PageDown:    binding("page down", "pgdown"),
+		Top:         binding("go to top", "home"),
+		Bottom:      binding("go to bottom", "end"),
+		Comment:     binding("comment on line or selection", "enter"),
+		Footnote:    binding("jump to footnote and back", "f"),
+		FollowLink:  binding("follow a link to a heading, or review the markdown file it points to", "o"),
+		JumpBack:    binding("go back to where a link was followed from", "alt+left", "ctrl+o"),
+		JumpForward: binding("go forward again after going back", "alt+right"),
+		Search:      binding("search", "/"),
+		NextMatch:   binding("next match", "n"),
+		PrevMatch:   binding("previous match", "N"),
+		ToggleView:  binding("cycle rendered, source and side-by-side views", "r"),
+		Fold:        binding("fold or unfold the section, code block or list at the cursor", "z"),
+		FoldLevel:   binding("fold every section at a heading level", "1", "2", "3", "4", "5", "6"),
+		UnfoldAll:   binding("unfold everything", "0"),
+
+		WordMode:        binding("toggle word mode", "v"),
+		WordLeft:        binding("previous word", "left"),
+		WordRight:       binding("next word", "right"),
+		SelectWordLeft:  binding("extend selection left", "shift+left"),
+		SelectWordRight: binding("extend selection right", "shift+right"),
+
+		Delete: binding("delete comment", "d"),
+		Expand: binding("show the focused comment in full", "enter"),
+		Filter: binding("filter comments", "/"),
+
+		Confirm: binding("save comment or run search", "enter"),
+		Newline: binding("insert newline", "alt+enter"),
+
+		Format: binding("cycle output format", "f"),
+
+		Cancel:         binding("cancel", "esc"),
+		SwitchPane:     binding("switch pane", "tab"),
+		ShrinkPane:     binding("shrink the markdown pane", "<"),
+		GrowPane:       binding("grow the markdown pane", ">"),
+		ToggleComments: binding("collapse or show the comments pane", "\\"),
+		ToggleStacked:  binding("stack the panes or put them side by side", "|"),
+		Preview:        binding("preview output (once there are open comments)", "p", "P"),
+		Copy:           binding("copy output", "c", "C"),
+		Submit:         binding("submit review and exit", "S"),
+		Approve:        binding("approve without comments and exit", "A"),
+		Help:           binding("help", "?"),
+		Quit:           binding("quit", "q"),
+	}
+}
+
+// VimKeyMap returns bindings modelled on vim's normal and visual modes.
+func VimKeyMap() KeyMap {
+	km := DefaultKeyMap()
+	km.Up.SetKeys("k", "up")
+	km.Down.SetKeys("j", "down")
+	km.SelectUp.SetKeys("K", "shift+up")
+	km.SelectDown.SetKeys("J", "shift+down")
+	km.SelectLines.SetKeys("V")
+	km.PageUp.SetKeys("ctrl+b", "pgup")
+	km.PageDown.SetKeys("ctrl+f", "pgdown")
+	km.Top.SetKeys("g g", "home")
+	km.Bottom.SetKeys("G", "end")
+	km.FollowLink.SetKeys("g f", "o")
+	km.Fold.SetKeys("z a")
+	km.UnfoldAll.SetKeys("z R", "0")
+	km.WordLeft.SetKeys("b", "h", "left")
+	km.WordRight.SetKeys("w", "l", "right")
+	km.SelectWordLeft.SetKeys("H", "shift+left")
+	km.SelectWordRight.SetKeys("L", "shift+right")
+	km.Delete.SetKeys("d d", "x")
+	km.Copy.SetKeys("y", "c", "C")
+	return km
+}
+
+// EmacsKeyMap returns bindings modelled on emacs motion commands.
+func EmacsKeyMap() KeyMap {
+	km := DefaultKeyMap()
+	km.Up.SetKeys("ctrl+p", "up")
+	km.Down.SetKeys("ctrl+n", "down")
+	km.SelectLines.SetKeys("ctrl+@")
+	km.PageUp.SetKeys("alt+v", "pgup")
+	km.PageDown.SetKeys("ctrl+v", "pgdown")
+	km.Top.SetKeys("alt+<", "home")
+	km.Bottom.SetKeys("alt+>", "end")
+	km.Search.SetKeys("ctrl+s", "/")
+	km.WordLeft.SetKeys("alt+b", "left")
+	km.WordRight.SetKeys("alt+f", "right")
+	km.Delete.SetKeys("ctrl+d", "d")
+	km.Copy.SetKeys("alt+w", "c", "C")
+	km.Cancel.SetKeys("ctrl+g", "esc")
+	km.Quit.SetKeys("ctrl+x ctrl+c", "q")
+	return km
+}
+
+// NewKeyMap returns a preset with the given actions rebound. Overrides
+// replace the preset's keys for an action.
+func NewKeyMap(preset string, overrides map[string][]string) (KeyMap, error) {
+	var km KeyMap
+	switch preset {
+	case "", "default":
+		km = DefaultKeyMap()
+	case "vim":
+		km = VimKeyMap()
+	case "emacs":
+		km = EmacsKeyMap()
+	default:
+		return km, fmt.Errorf("unknown keymap %q (available: %s)", preset, strings.Join(Keymaps, ", "))
+	}
+
+	var errs []error
+	actions := km.actions()
+	for _, a := range actions {
+		if keys, ok := overrides[a.name]; ok {
+			a.binding.SetKeys(keys...)
+		}
+	}
+	for name := range overrides {
+		if !slices.ContainsFunc(actions, func(a action) bool { return a.name == name }) {
+			errs = append(errs, fmt.Errorf("keys: unknown action %q", name))
+		}
+	}
+	for _, a := range actions {
+		a.binding.SetHelp(keysLabel(a.binding.Keys()), a.binding.Help().Desc)
+	}
+	errs = append(errs, km.conflicts()...)
+
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return km, errors.Join(errs...)
+}
+
+// action is a binding with the name configuration refers to it by.
+type action struct {
+	name    string
+	binding *key.Binding
+}
+
+func (km *KeyMap) actions() []action {
+	return []action{
+		{"up", &km.Up},
+		{"down", &km.Down},
+		{"select-up", &km.SelectUp},
+		{"select-down", &km.SelectDown},
+		{"select-lines", &km.SelectLines},
+		{"page-up", &km.PageUp},
+		{"page-down", &km.PageDown},
+		{"top", &km.Top},
+		{"bottom", &km.Bottom},
+		{"comment", &km.Comment},
+		{"footnote", &km.Footnote},
+		{"follow-link", &km.FollowLink},
+		{"jump-back", &km.JumpBack},
+		{"jump-forward", &km.JumpForward},
+		{"search", &km.Search},
+		{"next-match", &km.NextMatch},
+		{"prev-match", &km.PrevMatch},
+		{"toggle-view", &km.ToggleView},
+		{"fold", &km.Fold},
+		{"fold-level", &km.FoldLevel},
+		{"unfold-all", &km.UnfoldAll},
+		{"word-mode", &km.WordMode},
+		{"word-left", &km.WordLeft},
+		{"word-right", &km.WordRight},
+		{"select-word-left", &km.SelectWordLeft},
+		{"select-word-right", &km.SelectWordRight},
+		{"delete", &km.Delete},
+		{"expand-comment", &km.Expand},
+		{"filter-comments", &km.Filter},
+		{"confirm", &km.Confirm},
+		{"newline", &km.Newline},
+		{"format", &km.Format},
+		{"cancel", &km.Cancel},
+		{"switch-pane", &km.SwitchPane},
+		{"shrink-pane", &km.ShrinkPane},
+		{"grow-pane", &km.GrowPane},
+		{"toggle-comments", &km.ToggleComments},
+		{"toggle-stacked", &km.ToggleStacked},
+		{"preview", &km.Preview},
+		{"copy", &km.Copy},
+		{"submit", &km.Submit},
+		{"approve", &km.Approve},
+		{"help", &km.Help},
+		{"quit", &km.Quit},
+	}
+}
+
+// contexts groups the actions that are active at the same time, whose keys
+// must not clash.
+func (km *KeyMap) contexts() map[string][]*key.Binding {
+	global := []*key.Binding{&km.Cancel, &km.SwitchPane, &km.ShrinkPane, &km.GrowPane,
+		&km.ToggleComments, &km.ToggleStacked, &km.Preview, &km.Copy, &km.Submit,
+		&km.Approve, &km.Help, &km.Quit}
+	markdown := append([]*key.Binding{&km.Up, &km.Down, &km.SelectUp, &km.SelectDown,
+		&km.SelectLines, &km.PageUp, &km.PageDown, &km.Top, &km.Bottom, &km.Comment,
+		&km.Footnote, &km.FollowLink, &km.JumpBack, &km.JumpForward, &km.Search,
+		&km.NextMatch, &km.PrevMatch, &km.WordMode, &km.ToggleView, &km.Fold,
+		&km.FoldLevel, &km.UnfoldAll}, global...)
+	return map[string][]*key.Binding{
+		"markdown pane": markdown,
+		"word mode": append([]*key.Binding{&km.WordLeft, &km.WordRight,
+			&km.SelectWordLeft, &km.SelectWordRight}, markdown...),
+		"comments pane": append([]*key.Binding{&km.Up, &km.Down, &km.Delete, &km.Expand, &km.Filter}, global...),
+		"text input":    {&km.Confirm, &km.Newline, &km.Cancel},
+		"comment detail": {&km.Up, &km.Down, &km.PageUp, &km.PageDown, &km.Top, &km.Bottom,
+			&km.Expand, &km.Cancel, &km.Help, &km.Quit},
+		"preview": {&km.Up, &km.Down, &km.PageUp, &km.PageDown, &km.Top, &km.Bottom,
+			&km.Format, &km.Copy, &km.Submit, &km.Cancel, &km.Help, &km.Quit},
+	}
+}
+
+// conflicts reports keys bound to two actions in the same context, including
+// a key that is also the start of another action's sequence.
+func (km KeyMap) conflicts() []error {
+	names := map[*key.Binding]string{}
+	for _, a := range km.actions() {
+		names[a.binding] = a.name
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for _, ctx := range []string{"markdown pane", "word mode", "comments pane", "comment detail", "text input", "preview"} {
+		owner := map[string]*key.Binding{}
+		for _, b := range km.contexts()[ctx] {
+			for _, k := range b.Keys() {
+				for other, ob := range owner {
+					if ob == b || (other != k && !strings.HasPrefix(k, other+" ") && !strings.HasPrefix(other, k+" ")) {
+						continue
+					}
+					msg := fmt.Sprintf("keys: %q (%s) and %q (%s) clash in the %s", other, names[ob], k, names[b], ctx)
+					if !seen[names[ob]+names[b]] {
+						seen[names[ob]+names[b]] = true
+						errs = append(errs, errors.New(msg))
+					}
+				}
+				owner[k] = b
+			}
+		}
+	}
+	return errs
+}
+
+// isPrefix reports whether keys start a multi-key sequence of any binding.
+func (km KeyMap) isPrefix(keys string) bool {
+	for _, a := range km.actions() {
+		for _, k := range a.binding.Keys() {
+			if strings.HasPrefix(k, keys+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bound reports whether keys trigger any binding.
+func (km KeyMap) bound(keys string) bool {
+	for _, a := range km.actions() {
+		if slices.Contains(a.binding.Keys(), keys) {
+			return true
+		}
+	}
+	return false
+}
+
+func binding(desc string, keys ...string) key.Binding {
+	return key.NewBinding(key.WithKeys(keys...), key.WithHelp(keysLabel(keys), desc))
+}
+
+// keySeq is a pressed key or sequence of keys, matched against bindings.
+type keySeq string
+
+func (k keySeq) String() string { return string(k) }
+
+// isText reports whether the key would be typed into a text input, so it
+// can't trigger actions there.
+func (k keySeq) isText() bool {
+	return len([]rune(string(k))) == 1
+}
+
+// keysLabel formats keys for display, such as "↑, k".
+func keysLabel(keys []string) string {
+	labels := make([]string, len(keys))
+	for i, k := range keys {
+		labels[i] = keyLabel(k)
+	}
+	return strings.Join(labels, ", ")
+}
+
+var keyLabels = strings.NewReplacer(
+	"up", "↑", "down", "↓", "left", "←", "right", "→",
+	"ctrl+@", "Ctrl+Space", "shift+", "Shift+", "ctrl+", "Ctrl+", "alt+", "Alt+",
+	"enter", "Enter", "esc", "Esc", "tab", "Tab",
+	"pgup", "PgUp", "pgdown", "PgDn", "home", "Home", "end", "End",
+)
+
+// keyLabel formats a single key or sequence for display.
+func keyLabel(k string) string {
+	if len([]rune(k)) == 1 {
+		return k
+	}
+	return keyLabels.Replace(k)
+}
+
+// shortLabel is the label of a binding's first key, for the status bar.
+func shortLabel(b key.Binding) string {
+	if len(b.Keys()) == 0 {
+		return ""
+	}
+	return keyLabel(b.Keys()[0])
+}
@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+)
+
+// Layout arranges the markdown and comments panes. Keys can change it
+// during a session.
+type Layout struct {
+	Split        float64 // fraction of the width, or height when stacked, for the markdown pane; 0 means 0.65
+	Panes        string  // "auto", "side" or "stacked"; "" means "auto"
+	HideComments bool    // collapse the comments pane to a count in the markdown pane's title
+}
+
+// LayoutChanges records which parts of the layout keys changed.
+type LayoutChanges struct {
+	Split, Panes, HideComments bool
+}
+
+// PaneLayouts lists the arrangements of the panes: side by side, stacked
+// with the comments below, or stacked only when the terminal is narrower
+// than stackWidth.
+var PaneLayouts = []string{"auto", "side", "stacked"}
+
+const (
+	stackWidth = 90   // terminal width below which "auto" stacks the panes
+	splitStep  = 0.05 // how far one key press moves the split
+	minSplit   = 0.2
+	maxSplit   = 0.9
+)
+
+// stacked reports whether the comments pane is shown below the markdown
+// pane rather than beside it.
+func (m Model) stacked() bool {
+	switch m.layout.Panes {
+	case "side":
+		return false
+	case "stacked":
+		return true
+	}
+	return m.width > 0 && m.width < stackWidth
+}
+
+func (m Model) split() float64 {
+	if m.layout.Split <= 0 {
+		return 0.65
+	}
+	return m.layout.Split
+}
+
+// resizePanes moves the split between the panes by steps, keeping both
+// panes usable.
+func (m Model) resizePanes(steps int) Model {
+	split := math.Round((m.split()+float64(steps)*splitStep)*100) / 100
+	m.layout.Split = max(min(split, maxSplit), minSplit)
+	m.layoutChanged.Split = true
+	m.statusMessage = fmt.Sprintf("Markdown pane %d%%", int(math.Round(m.layout.Split*100)))
+	m.reRender()
+	return m
+}
+
+// toggleComments collapses the comments pane, or shows it again.
+func (m Model) toggleComments() Model {
+	m.layout.HideComments = !m.layout.HideComments
+	m.layoutChanged.HideComments = true
+	if m.layout.HideComments {
+		m.focusPane = paneMarkdown
+	}
+	m.reRender()
+	return m
+}
+
+// toggleStacked switches between side-by-side and stacked panes.
+func (m Model) toggleStacked() Model {
+	if m.stacked() {
+		m.layout.Panes = "side"
+	} else {
+		m.layout.Panes = "stacked"
+	}
+	m.layoutChanged.Panes = true
+	m.reRender()
+	return m
+}
+
+// Layout returns the layout at the end of the session, and which parts of
+// it keys changed.
+func (m Model) Layout() (Layout, LayoutChanges) {
+	return m.layout, m.layoutChanged
+}
+
+// leftWidth is the width of the markdown pane.
+func (m Model) leftWidth() int {
+	if m.width <= 0 {
+		return 40
+	}
+	if m.layout.HideComments || m.stacked() {
+		return m.width
+	}
+	return int(float64(m.width) * m.split())
+}
+
+// rightWidth is the width of the comments pane.
+func (m Model) rightWidth() int {
+	if m.width <= 0 {
+		return 20
+	}
+	if m.stacked() {
+		return m.width
+	}
+	return m.width - m.leftWidth()
+}
+
+// contentHeight is the number of rows the markdown pane shows.
+func (m Model) contentHeight() int {
+	h := m.height - 4 // borders + status bar + title
+	if m.stacked() && !m.layout.HideComments {
+		h = int(float64(m.height-1)*m.split()) - 3
+	}
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+// commentsHeight is the number of rows the comments pane shows.
+func (m Model) commentsHeight() int {
+	if !m.stacked() {
+		return m.contentHeight()
+	}
+	// Both panes have a border and title, and share the rows above the status bar
+	return max(m.height-1-(m.contentHeight()+3)-3, 1)
+}
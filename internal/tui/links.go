@@ -0,0 +1,154 @@
+package tui
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+)
+
+// markdownExts are the extensions of files a link can be followed to.
+var markdownExts = []string{".md", ".markdown", ".mdown", ".mkd"}
+
+// linkClosedMsg reports the end of the review of a linked file.
+type linkClosedMsg struct {
+	path string
+	err  error
+}
+
+// document returns the parsed document, parsing it on first use.
+func (m *Model) document() *markdown.Document {
+	if m.parsed == nil {
+		m.parsed = markdown.Parse(m.source)
+		m.parsed.SetHyperlinks(m.opts.Hyperlinks)
+		m.parsed.SetLinkBase(filepath.Dir(m.opts.Path))
+		if m.opts.Theme != nil {
+			m.parsed.SetTheme(m.opts.Theme)
+		}
+	}
+	return m.parsed
+}
+
+// linkAtCursor returns the link under the word cursor in word mode, or the
+// first link on the cursor row.
+func (m Model) linkAtCursor() (markdown.Link, bool) {
+	if m.cursor >= len(m.doc.Mappings) {
+		return markdown.Link{}, false
+	}
+	links := m.document().Links()
+	if m.wordMode {
+		offset, ok := m.wordOffset(m.cursor, m.wordCol)
+		if !ok {
+			return markdown.Link{}, false
+		}
+		for _, l := range links {
+			if l.Start <= offset && offset < l.End {
+				return l, true
+			}
+		}
+		return markdown.Link{}, false
+	}
+
+	var best markdown.Link
+	found := false
+	for _, seg := range m.doc.Mappings[m.cursor].Segments {
+		for _, l := range links {
+			if l.Start < seg.SourceEnd && seg.SourceStart < l.End && (!found || l.Start < best.Start) {
+				best, found = l, true
+			}
+		}
+	}
+	return best, found
+}
+
+// followLink follows the link at the cursor: a link within the document
+// jumps to its heading, and a link to a markdown file reviews the file in a
+// nested session, returning here when it ends.
+func (m Model) followLink() (Model, tea.Cmd) {
+	link, ok := m.linkAtCursor()
+	if !ok {
+		m.statusMessage = "No link at the cursor"
+		return m, nil
+	}
+	if anchor, ok := strings.CutPrefix(link.Destination, "#"); ok {
+		return m.jumpToAnchor(anchor), nil
+	}
+	path, ok := markdown.LocalPath(link.Destination)
+	if !ok || !slices.Contains(markdownExts, strings.ToLower(filepath.Ext(path))) {
+		m.statusMessage = "✗ Only links to markdown files can be followed: " + link.Destination
+		return m, nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(m.opts.Path), path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		m.statusMessage = "✗ " + err.Error()
+		return m, nil
+	}
+	if m.opts.Open == nil {
+		m.statusMessage = "✗ Links can't be followed in this session"
+		return m, nil
+	}
+
+	return m, tea.ExecProcess(m.opts.Open(path), func(err error) tea.Msg {
+		return linkClosedMsg{path: path, err: err}
+	})
+}
+
+// jumpToAnchor moves the cursor to the heading a link within the document
+// names, remembering where it was in the jump list.
+func (m Model) jumpToAnchor(anchor string) Model {
+	if id, err := url.PathUnescape(anchor); err == nil {
+		anchor = id
+	}
+	line, ok := m.document().AnchorLine(anchor)
+	if !ok {
+		m.statusMessage = "✗ No heading #" + anchor
+		return m
+	}
+	m.jumpsBack = append(m.jumpsBack, m.sourceLine(m.cursor))
+	m.jumpsForward = nil
+	return m.moveToLine(line)
+}
+
+// jumpBack returns to where the last jump came from, and jumpForward
+// undoes that, like a browser's back and forward.
+func (m Model) jumpBack() Model {
+	n := len(m.jumpsBack)
+	if n == 0 {
+		m.statusMessage = "No earlier jump"
+		return m
+	}
+	line := m.jumpsBack[n-1]
+	m.jumpsBack = m.jumpsBack[:n-1]
+	m.jumpsForward = append(m.jumpsForward, m.sourceLine(m.cursor))
+	return m.moveToLine(line)
+}
+
+func (m Model) jumpForward() Model {
+	n := len(m.jumpsForward)
+	if n == 0 {
+		m.statusMessage = "No later jump"
+		return m
+	}
+	line := m.jumpsForward[n-1]
+	m.jumpsForward = m.jumpsForward[:n-1]
+	m.jumpsBack = append(m.jumpsBack, m.sourceLine(m.cursor))
+	return m.moveToLine(line)
+}
+
+// moveToLine moves the cursor to the first row showing a source line.
+func (m Model) moveToLine(line int) Model {
+	m.clearSelection()
+	m.wordAnchorLine = -1
+	m.cursor = firstRow(m.doc, line)
+	if m.wordMode {
+		m.wordCol = m.snapWordCol(m.cursor, 0)
+	}
+	m.ensureCursorVisible()
+	return m
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/paulbuckley/mdmu/internal/markdown"
@@ -11,10 +12,24 @@ func (m Model) renderMarkdownPane() string {
 	height := m.contentHeight()
 
 	if len(m.doc.Lines) == 0 {
-		content := emptyStateStyle.Render("No content to display")
+		content := m.styles.emptyState.Render("No content to display")
 		return m.markdownPaneBorder(width, height, content)
 	}
 
+	// In the side-by-side view the rendered lines fill the left column
+	lineWidth := width - 2 // -2 for border padding
+	var sourceRows []string
+	if m.view == viewSplit {
+		var sourceWidth int
+		lineWidth, sourceWidth = m.splitColumns()
+		sourceRows = m.sourceColumn(sourceWidth, height)
+	}
+
+	// Commented lines are marked in a gutter column, dimmed where the
+	// comments filter hides their comments
+	lineWidth--
+	marks := m.commentMarks()
+
 	// Determine visible lines
 	var visibleLines []string
 	for i := m.scrollOffset; i < m.scrollOffset+height && i < len(m.doc.Lines); i++ {
@@ -22,7 +37,7 @@ func (m Model) renderMarkdownPane() string {
 
 		// Pad or truncate to width
 		visibleWidth := markdown.VisibleLen(line)
-		padding := width - 2 - visibleWidth // -2 for border padding
+		padding := lineWidth - visibleWidth
 		if padding < 0 {
 			padding = 0
 		}
@@ -30,18 +45,23 @@ func (m Model) renderMarkdownPane() string {
 		styledLine := line + strings.Repeat(" ", padding)
 
 		// Apply highlight styles
-		if m.isLineSelected(i) {
-			styledLine = selectedLineStyle.Render(styledLine)
-		} else if i == m.cursor && m.focusPane == paneMarkdown {
-			styledLine = cursorLineStyle.Render(styledLine)
+		if from, to, ok := m.wordHighlight(i); ok {
+			styledLine = m.styles.highlightColumns(styledLine, from, to)
+		} else if m.isLineSelected(i) {
+			styledLine = m.styles.selectedLine.Render(styledLine)
+		} else if i == m.cursor && m.focusPane == paneMarkdown && !m.wordMode {
+			styledLine = m.styles.cursorLine.Render(styledLine)
 		}
 
-		visibleLines = append(visibleLines, styledLine)
+		visibleLines = append(visibleLines, m.styles.gutter(marks, m.doc.Mappings[i])+styledLine)
 	}
 
 	// Pad remaining height with empty lines
 	for len(visibleLines) < height {
-		visibleLines = append(visibleLines, strings.Repeat(" ", width-2))
+		visibleLines = append(visibleLines, strings.Repeat(" ", lineWidth+1))
+	}
+	for i, row := range sourceRows {
+		visibleLines[i] += m.styles.commentLineRef.Render(splitGap) + row
 	}
 
 	content := strings.Join(visibleLines, "\n")
@@ -49,11 +69,18 @@ func (m Model) renderMarkdownPane() string {
 }
 
 func (m Model) markdownPaneBorder(width, height int, content string) string {
-	title := paneTitle.Render("Markdown")
+	label := "Markdown"
+	if m.view != viewRendered {
+		label += " (" + m.view.String() + ")"
+	}
+	if m.layout.HideComments {
+		label += fmt.Sprintf(" · Comments (%d)", m.openComments())
+	}
+	title := m.styles.paneTitle.Render(label)
 
-	style := inactiveBorderStyle
+	style := m.styles.inactiveBorder
 	if m.focusPane == paneMarkdown {
-		style = activeBorderStyle
+		style = m.styles.activeBorder
 	}
 
 	return style.Width(width - 2).Render(title + "\n" + content)
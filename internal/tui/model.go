@@ -1,27 +1,42 @@
 package tui
 
 import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/paulbuckley/mdmu/internal/clipboard"
 	"github.com/paulbuckley/mdmu/internal/markdown"
 	"github.com/paulbuckley/mdmu/internal/output"
 	"github.com/paulbuckley/mdmu/internal/store"
+	"github.com/paulbuckley/mdmu/internal/theme"
 )
 
-// reRender re-parses and re-renders the markdown at the given width.
+// reRender re-renders the markdown to fit the markdown pane.
 func (m *Model) reRender() {
 	renderWidth := m.leftWidth() - 4 // account for borders and padding
 	if renderWidth < 20 {
 		renderWidth = 20
 	}
-	doc, err := markdown.ParseAndRender(m.source, renderWidth)
-	if err != nil {
-		m.statusMessage = "Render error: " + err.Error()
-		return
+	m.document()
+	switch m.view {
+	case viewSource:
+		m.doc = m.parsed.RenderSource(renderWidth)
+	case viewSplit:
+		left, right := m.splitColumns()
+		renderWidth = max(left-1, 10)
+		m.doc = m.parsed.Render(renderWidth)
+		m.sideDoc = m.parsed.RenderSource(max(right, 10))
+	default:
+		m.doc = m.parsed.Render(renderWidth)
 	}
-	m.doc = doc
+	m.doc = m.doc.Fold(m.folds, renderWidth)
 	if m.cursor >= len(m.doc.Lines) {
 		m.cursor = len(m.doc.Lines) - 1
 		if m.cursor < 0 {
@@ -31,6 +46,20 @@ func (m *Model) reRender() {
 	m.ensureCursorVisible()
 }
 
+// resizeDelay is how long the terminal width must stay the same before the
+// document is rendered at it.
+const resizeDelay = 100 * time.Millisecond
+
+// renderMsg asks for the document to be rendered, if the terminal is still
+// the width it was when the message was scheduled.
+type renderMsg struct{ width int }
+
+func renderLater(width int) tea.Cmd {
+	return tea.Tick(resizeDelay, func(time.Time) tea.Msg {
+		return renderMsg{width: width}
+	})
+}
+
 type mode int
 
 const (
@@ -38,6 +67,10 @@ const (
 	modeSelecting
 	modeCommenting
 	modePreview
+	modeSearching
+	modeHelp
+	modeDetail
+	modeFiltering
 )
 
 type pane int
@@ -47,28 +80,90 @@ const (
 	paneComments
 )
 
+// Result is how a review session ended.
+type Result int
+
+const (
+	ResultQuit      Result = iota // quit without submitting
+	ResultSubmitted               // comments submitted
+	ResultApproved                // approved without comments
+)
+
+// Options configure a Model.
+type Options struct {
+	Output        output.Options              // formatting of copied and previewed output
+	Clipboard     clipboard.Backend           // how output is copied
+	Layout        Layout                      // arrangement of the panes
+	CommentHeight int                         // rows of the comment input; 0 means 3
+	Path          string                      // absolute path of the document; empty for stdin
+	StorePath     string                      // file comments are saved to; empty keeps them in memory
+	KeyMap        *KeyMap                     // nil means DefaultKeyMap
+	Submits       bool                        // submitting or approving delivers the review, with --hook or --output
+	Hyperlinks    bool                        // links render as terminal hyperlinks
+	Open          func(path string) *exec.Cmd // reviews a linked file; nil disables following links
+	Theme         *theme.Theme                // colors of the document and interface; nil means theme.Dark
+}
+
 type Model struct {
 	doc         *markdown.RenderedDocument
+	parsed      *markdown.Document         // parsed source, keeping renders for reuse
+	sideDoc     *markdown.RenderedDocument // the source column of the side-by-side view
 	commentFile *store.CommentFile
 	source      []byte
 	filename    string
+	opts        Options
+	keys        KeyMap
+	styles      styles
+
+	// Keys pressed so far of an unfinished multi-key binding
+	pendingKeys string
 
 	// Window dimensions
 	width  int
 	height int
 
+	// Arrangement of the panes, and whether keys changed it
+	layout        Layout
+	layoutChanged LayoutChanges
+
 	// Markdown pane state
 	cursor       int // current rendered line (0-indexed)
 	scrollOffset int // first visible line
+	view         view
+	folds        []markdown.Region // folded sections, code blocks and lists
 
 	// Selection state
-	selectionStart int // -1 means no selection
+	selectionStart int  // -1 means no selection
+	lineSelect     bool // motions extend the selection until it is toggled off
 	mode           mode
 
+	// Word selection state for comments on part of a line
+	wordMode       bool
+	wordCol        int // visible column of the word under the cursor
+	wordAnchorLine int // -1 means no word selection
+	wordAnchorCol  int
+
+	// Footnote reference the last jump to a definition came from
+	footnoteReturn markdown.FootnoteRef
+
+	// Source lines the cursor left by following links within the document,
+	// most recent last, and those left by going back
+	jumpsBack    []int
+	jumpsForward []int
+
+	// Search state
+	searchInput textinput.Model
+	searchQuery string
+
 	// Comments pane state
 	commentCursor       int
 	commentScrollOffset int
 
+	// Comments filter
+	filterInput textinput.Model
+	filter      commentFilter
+	headings    []markdown.Heading // loaded to filter by section
+
 	// Focus
 	focusPane pane
 
@@ -77,26 +172,58 @@ type Model struct {
 
 	// Preview state
 	previewContent string
+	previewFormat  int // index into previewFormats
+	previewReport  output.Report
 	previewScroll  int
 	copiedMessage  bool
 
+	// Comment detail state
+	detailScroll int
+
+	// Help overlay state
+	helpScroll int
+	helpReturn mode // mode to restore when help is closed
+
+	// Modification times of the document when it was last read, and of the
+	// comment file when it was last read or written
+	docModTime   time.Time
+	storeModTime time.Time
+
 	// Status
 	statusMessage string
+	result        Result
 }
 
-func NewModel(doc *markdown.RenderedDocument, cf *store.CommentFile, source []byte, filename string) Model {
+func NewModel(doc *markdown.RenderedDocument, cf *store.CommentFile, source []byte, filename string, opts Options) Model {
+	keys := DefaultKeyMap()
+	if opts.KeyMap != nil {
+		keys = *opts.KeyMap
+	}
+	if opts.Theme == nil {
+		opts.Theme = theme.Dark()
+	}
 	return Model{
 		doc:            doc,
 		commentFile:    cf,
 		source:         source,
 		filename:       filename,
+		opts:           opts,
+		keys:           keys,
+		styles:         newStyles(opts.Theme),
+		layout:         opts.Layout,
 		selectionStart: -1,
+		wordAnchorLine: -1,
 		focusPane:      paneMarkdown,
-		textarea:       newCommentTextarea(),
+		textarea:       newCommentTextarea(opts.CommentHeight),
+		docModTime:     modTime(opts.Path),
+		storeModTime:   modTime(opts.StorePath),
 	}
 }
 
 func (m Model) Init() tea.Cmd {
+	if m.opts.Path != "" || m.opts.StorePath != "" {
+		return checkFilesLater()
+	}
 	return nil
 }
 
@@ -107,11 +234,32 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		m.textarea.SetWidth(m.width - 6)
-		if m.width != oldWidth {
+		switch {
+		case oldWidth == 0:
+			m.reRender()
+		case m.width != oldWidth:
+			// Wait for the terminal to stop resizing, since rendering a
+			// large document at a new width takes a while
+			return m, renderLater(m.width)
+		}
+		return m, nil
+
+	case renderMsg:
+		if msg.width == m.width {
 			m.reRender()
 		}
 		return m, nil
 
+	case fileCheckMsg:
+		return m.reloadDocument().reloadStore(), checkFilesLater()
+
+	case linkClosedMsg:
+		m.statusMessage = "Back from " + filepath.Base(msg.path)
+		if msg.err != nil {
+			m.statusMessage = "✗ Reviewing " + filepath.Base(msg.path) + ": " + msg.err.Error()
+		}
+		return m, nil
+
 	case tea.KeyMsg:
 		// Global keys
 		if msg.String() == "ctrl+c" {
@@ -128,6 +276,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handlePreviewKeys(msg)
 		}
 
+		if m.mode == modeSearching {
+			return m.handleSearchInput(msg)
+		}
+
+		if m.mode == modeHelp {
+			return m.handleHelpKeys(msg)
+		}
+
+		if m.mode == modeDetail {
+			return m.handleDetailKeys(msg)
+		}
+
+		if m.mode == modeFiltering {
+			return m.handleFilterInput(msg)
+		}
+
 		return m.handleKeypress(msg)
 	}
 
@@ -142,136 +306,232 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleKeypress(msg tea.KeyMsg) (Model, tea.Cmd) {
-	key := msg.String()
+	k, ok := m.keySequence(msg)
+	if !ok {
+		return m, nil
+	}
 
 	switch {
 	// Quit
-	case key == "q":
+	case key.Matches(k, m.keys.Quit):
 		return m, tea.Quit
 
+	// Help screen
+	case key.Matches(k, m.keys.Help):
+		return m.openHelp(), nil
+
 	// Enter preview mode
-	case (key == "p" || key == "P") && len(m.commentFile.Comments) > 0:
+	case key.Matches(k, m.keys.Preview) && m.openComments() > 0:
 		return m.enterPreviewMode(), nil
 
+	case key.Matches(k, m.keys.Submit):
+		return m.submit()
+
+	case key.Matches(k, m.keys.Approve):
+		if !m.opts.Submits {
+			m.statusMessage = noSubmitMessage
+			return m, nil
+		}
+		if m.openComments() > 0 {
+			m.statusMessage = "Can't approve with open comments: submit, resolve or delete them"
+			return m, nil
+		}
+		m.result = ResultApproved
+		return m, tea.Quit
+
 	// Copy to clipboard
-	case key == "c" || key == "C":
-		if len(m.commentFile.Comments) == 0 {
-			m.statusMessage = "No comments to copy"
+	case key.Matches(k, m.keys.Copy):
+		if m.openComments() == 0 {
+			m.statusMessage = "No open comments to copy"
 			return m, nil
 		}
-		content := output.Format(m.commentFile, m.source, m.filename)
-		if err := clipboard.Copy(content); err != nil {
+		content := m.formatOutput()
+		if err := m.opts.Clipboard.Copy(content); err != nil {
 			m.statusMessage = "✗ Failed to copy: " + err.Error()
 		} else {
 			m.statusMessage = "✓ Copied to clipboard"
 		}
 		return m, nil
 
-	// Tab: switch focus
-	case key == "tab":
+	// Switch focus, showing a collapsed comments pane
+	case key.Matches(k, m.keys.SwitchPane):
+		if m.layout.HideComments {
+			m = m.toggleComments()
+			m.focusPane = paneMarkdown
+		}
 		if m.focusPane == paneMarkdown {
 			m.focusPane = paneComments
-			if len(m.commentFile.Comments) > 0 {
+			if m.openComments() > 0 {
 				m.scrollToCommentTarget()
+				m.scrollComments()
 			}
 		} else {
 			m.focusPane = paneMarkdown
 		}
 		return m, nil
 
+	// Pane layout
+	case key.Matches(k, m.keys.ShrinkPane):
+		return m.resizePanes(-1), nil
+
+	case key.Matches(k, m.keys.GrowPane):
+		return m.resizePanes(1), nil
+
+	case key.Matches(k, m.keys.ToggleComments):
+		return m.toggleComments(), nil
+
+	case key.Matches(k, m.keys.ToggleStacked):
+		return m.toggleStacked(), nil
+
 	// Navigation when in markdown pane
 	case m.focusPane == paneMarkdown:
-		return m.handleMarkdownKeys(key)
+		return m.handleMarkdownKeys(k)
 
 	// Navigation when in comments pane
 	case m.focusPane == paneComments:
-		return m.handleCommentKeys(key)
+		return m.handleCommentKeys(k)
 	}
 
 	return m, nil
 }
 
-func (m Model) handleMarkdownKeys(key string) (Model, tea.Cmd) {
+// keySequence combines a key press with any keys pressed before it that
+// start a multi-key binding. It reports false while a sequence is still
+// incomplete.
+func (m *Model) keySequence(msg tea.KeyMsg) (keySeq, bool) {
+	pressed := msg.String()
+	if m.pendingKeys != "" {
+		seq := m.pendingKeys + " " + pressed
+		m.pendingKeys = ""
+		if m.keys.isPrefix(seq) {
+			m.pendingKeys = seq
+			return "", false
+		}
+		if m.keys.bound(seq) {
+			return keySeq(seq), true
+		}
+		// An unfinished sequence is dropped and the key handled on its own
+	}
+	if m.keys.isPrefix(pressed) {
+		m.pendingKeys = pressed
+		return "", false
+	}
+	return keySeq(pressed), true
+}
+
+func (m Model) handleMarkdownKeys(k keySeq) (Model, tea.Cmd) {
 	maxLine := len(m.doc.Lines) - 1
 	if maxLine < 0 {
 		maxLine = 0
 	}
 
-	switch key {
-	case "up":
-		m.selectionStart = -1
-		m.mode = modeNormal
-		if m.cursor > 0 {
-			m.cursor--
+	if m.wordMode {
+		if next, cmd, ok := m.handleWordKeys(k); ok {
+			return next, cmd
 		}
-		m.ensureCursorVisible()
-
-	case "down":
-		m.selectionStart = -1
-		m.mode = modeNormal
-		if m.cursor < maxLine {
-			m.cursor++
-		}
-		m.ensureCursorVisible()
+		// Other motions drop the word selection
+		m.wordAnchorLine = -1
+	}
 
-	case "shift+up":
-		if m.selectionStart < 0 {
+	switch {
+	case key.Matches(k, m.keys.WordMode):
+		m.wordMode = true
+		m.wordAnchorLine = -1
+		m.clearSelection()
+		m.wordCol = m.snapWordCol(m.cursor, 0)
+
+	case key.Matches(k, m.keys.SelectLines):
+		if m.lineSelect {
+			m.clearSelection()
+		} else {
+			m.lineSelect = true
 			m.selectionStart = m.cursor
 			m.mode = modeSelecting
 		}
+
+	case key.Matches(k, m.keys.Up), key.Matches(k, m.keys.SelectUp):
+		m.startMove(key.Matches(k, m.keys.SelectUp))
 		if m.cursor > 0 {
 			m.cursor--
 		}
 		m.ensureCursorVisible()
 
-	case "shift+down":
-		if m.selectionStart < 0 {
-			m.selectionStart = m.cursor
-			m.mode = modeSelecting
-		}
+	case key.Matches(k, m.keys.Down), key.Matches(k, m.keys.SelectDown):
+		m.startMove(key.Matches(k, m.keys.SelectDown))
 		if m.cursor < maxLine {
 			m.cursor++
 		}
 		m.ensureCursorVisible()
 
-	case "pgup":
-		m.selectionStart = -1
-		m.mode = modeNormal
+	case key.Matches(k, m.keys.PageUp):
+		m.startMove(false)
 		m.cursor -= m.contentHeight()
 		if m.cursor < 0 {
 			m.cursor = 0
 		}
 		m.ensureCursorVisible()
 
-	case "pgdown":
-		m.selectionStart = -1
-		m.mode = modeNormal
+	case key.Matches(k, m.keys.PageDown):
+		m.startMove(false)
 		m.cursor += m.contentHeight()
 		if m.cursor > maxLine {
 			m.cursor = maxLine
 		}
 		m.ensureCursorVisible()
 
-	case "home":
-		m.selectionStart = -1
-		m.mode = modeNormal
+	case key.Matches(k, m.keys.Top):
+		m.startMove(false)
 		m.cursor = 0
 		m.ensureCursorVisible()
 
-	case "end":
-		m.selectionStart = -1
-		m.mode = modeNormal
+	case key.Matches(k, m.keys.Bottom):
+		m.startMove(false)
 		m.cursor = maxLine
 		m.ensureCursorVisible()
 
-	case "esc":
-		m.selectionStart = -1
-		m.mode = modeNormal
+	case key.Matches(k, m.keys.Cancel):
+		m.clearSelection()
+
+	case key.Matches(k, m.keys.Footnote):
+		m.clearSelection()
+		m.jumpFootnote()
+
+	case key.Matches(k, m.keys.FollowLink):
+		return m.followLink()
+
+	case key.Matches(k, m.keys.JumpBack):
+		return m.jumpBack(), nil
+
+	case key.Matches(k, m.keys.JumpForward):
+		return m.jumpForward(), nil
+
+	case key.Matches(k, m.keys.ToggleView):
+		return m.cycleView(), nil
+
+	case key.Matches(k, m.keys.Fold):
+		return m.toggleFold(), nil
 
-	case "enter":
+	case key.Matches(k, m.keys.FoldLevel):
+		// The first key folds level 1 sections, the second level 2 and so on
+		return m.foldLevel(slices.Index(m.keys.FoldLevel.Keys(), k.String()) + 1), nil
+
+	case key.Matches(k, m.keys.UnfoldAll):
+		return m.setFolds(nil), nil
+
+	case key.Matches(k, m.keys.Search):
+		return m.startSearch()
+
+	case key.Matches(k, m.keys.NextMatch):
+		m.findMatch(1)
+
+	case key.Matches(k, m.keys.PrevMatch):
+		m.findMatch(-1)
+
+	case key.Matches(k, m.keys.Comment):
 		// Enter comment mode
 		m.mode = modeCommenting
-		m.textarea = newCommentTextarea()
+		m.lineSelect = false
+		m.textarea = newCommentTextarea(m.opts.CommentHeight)
 		m.textarea.SetWidth(m.width - 6)
 		if m.selectionStart < 0 {
 			// Comment on current line only
@@ -280,52 +540,169 @@ func (m Model) handleMarkdownKeys(key string) (Model, tea.Cmd) {
 		return m, m.textarea.Focus()
 	}
 
+	if m.wordMode {
+		m.wordCol = m.snapWordCol(m.cursor, m.wordCol)
+	}
+
 	return m, nil
 }
 
-func (m Model) handleCommentKeys(key string) (Model, tea.Cmd) {
-	sorted := m.sortedComments()
+// startMove prepares a cursor motion: selecting motions, and any motion
+// while a line selection is toggled on, extend the selection; others clear
+// it.
+func (m *Model) startMove(selecting bool) {
+	if selecting || m.lineSelect {
+		if m.selectionStart < 0 {
+			m.selectionStart = m.cursor
+			m.mode = modeSelecting
+		}
+		return
+	}
+	m.clearSelection()
+}
+
+func (m *Model) clearSelection() {
+	m.selectionStart = -1
+	m.lineSelect = false
+	m.mode = modeNormal
+}
+
+func (m Model) handleCommentKeys(k keySeq) (Model, tea.Cmd) {
+	sorted := m.listedComments()
 	maxIdx := len(sorted) - 1
 	if maxIdx < 0 {
 		maxIdx = 0
 	}
 
-	switch key {
-	case "up":
+	switch {
+	case key.Matches(k, m.keys.Up):
 		if m.commentCursor > 0 {
 			m.commentCursor--
 			m.scrollToCommentTarget()
 		}
 
-	case "down":
+	case key.Matches(k, m.keys.Down):
 		if m.commentCursor < maxIdx {
 			m.commentCursor++
 			m.scrollToCommentTarget()
 		}
 
-	case "d":
+	case key.Matches(k, m.keys.Delete):
 		if len(sorted) > 0 && m.commentCursor < len(sorted) {
 			// Delete the comment
 			target := sorted[m.commentCursor]
-			for i, c := range m.commentFile.Comments {
-				if c.ID == target.ID {
-					m.commentFile.Comments = append(m.commentFile.Comments[:i], m.commentFile.Comments[i+1:]...)
-					break
+			m.statusMessage = "" // Clear status message when deleting comment
+			m.updateComments(func(cf *store.CommentFile) {
+				for i, c := range cf.Comments {
+					if c.ID == target.ID {
+						cf.Comments = append(cf.Comments[:i], cf.Comments[i+1:]...)
+						break
+					}
 				}
-			}
-			if m.commentCursor >= len(m.commentFile.Comments) && m.commentCursor > 0 {
+			})
+			if m.commentCursor >= len(sorted)-1 && m.commentCursor > 0 {
 				m.commentCursor--
 			}
-			m.statusMessage = "" // Clear status message when deleting comment
 		}
+
+	case key.Matches(k, m.keys.Expand):
+		return m.openCommentDetail(), nil
+
+	case key.Matches(k, m.keys.Filter):
+		return m.startFilter()
+
+	case key.Matches(k, m.keys.Cancel) && m.filter.active():
+		m.setFilter(commentFilter{})
 	}
 
+	m.scrollComments()
 	return m, nil
 }
 
+// formatOutput formats the comments for copying, exposing the document's
+// front matter to the output templates.
+func (m Model) formatOutput() string {
+	out, _ := m.formatReport()
+	return out
+}
+
+// formatReport formats the comments like formatOutput, also reporting how
+// the output fits its size budget.
+func (m Model) formatReport() (string, output.Report) {
+	opts := m.opts.Output
+	if m.doc != nil && m.doc.FrontMatter != nil {
+		opts.Metadata = m.doc.FrontMatter.Data
+	}
+	return output.FormatReport(m.commentFile, m.source, m.filename, opts)
+}
+
+// submit ends the session with the comments as the review.
+func (m Model) submit() (Model, tea.Cmd) {
+	if !m.opts.Submits {
+		m.statusMessage = noSubmitMessage
+		return m, nil
+	}
+	if m.openComments() == 0 {
+		m.statusMessage = "No open comments to submit"
+		return m, nil
+	}
+	m.result = ResultSubmitted
+	return m, tea.Quit
+}
+
+// noSubmitMessage explains why a review can't be submitted or approved:
+// without --hook or --output nothing would receive it.
+const noSubmitMessage = "Nothing receives a submitted review without --hook or --output: copy it instead"
+
+// openComments counts the comments the review includes: those not yet
+// resolved.
+func (m Model) openComments() int {
+	n := 0
+	for _, c := range m.commentFile.Comments {
+		if !c.Resolved() {
+			n++
+		}
+	}
+	return n
+}
+
+// Output returns the formatted review, or "" when there are no comments.
+func (m Model) Output() string {
+	return m.formatOutput()
+}
+
+// Result reports how the session ended.
+func (m Model) Result() Result {
+	return m.result
+}
+
+// updateComments applies a change to the comments. With persistence
+// enabled the change is made to the store file and the comments reloaded
+// from it, keeping changes other processes made in the meantime, such as
+// resolutions from the MCP server.
+func (m *Model) updateComments(fn func(cf *store.CommentFile)) {
+	if m.opts.StorePath == "" {
+		fn(m.commentFile)
+		return
+	}
+
+	cf, err := store.Update(m.opts.StorePath, func(cf *store.CommentFile) error {
+		fn(cf)
+		return nil
+	})
+	if err != nil {
+		// Keep the change for this session at least
+		fn(m.commentFile)
+		m.statusMessage = "✗ Failed to save comments: " + err.Error()
+		return
+	}
+	*m.commentFile = *cf
+	m.storeModTime = modTime(m.opts.StorePath)
+}
+
 // scrollToCommentTarget scrolls the markdown pane to show the lines referenced by the focused comment.
 func (m *Model) scrollToCommentTarget() {
-	sorted := m.sortedComments()
+	sorted := m.listedComments()
 	if m.commentCursor >= len(sorted) {
 		return
 	}
@@ -356,28 +733,6 @@ func (m *Model) ensureCursorVisible() {
 	}
 }
 
-func (m Model) leftWidth() int {
-	if m.width <= 0 {
-		return 40
-	}
-	return int(float64(m.width) * 0.65)
-}
-
-func (m Model) rightWidth() int {
-	if m.width <= 0 {
-		return 20
-	}
-	return m.width - m.leftWidth()
-}
-
-func (m Model) contentHeight() int {
-	h := m.height - 4 // borders + status bar + title
-	if h < 1 {
-		h = 1
-	}
-	return h
-}
-
 func (m Model) View() string {
 	if m.width == 0 {
 		return "Loading..."
@@ -388,10 +743,23 @@ func (m Model) View() string {
 		return m.renderPreview()
 	}
 
-	// Render panes side by side
-	left := m.renderMarkdownPane()
-	right := m.renderCommentsPane()
-	panels := lipgloss.JoinHorizontal(lipgloss.Top, left, right)
+	if m.mode == modeHelp {
+		return m.renderHelp()
+	}
+
+	if m.mode == modeDetail {
+		return m.renderCommentDetail()
+	}
+
+	// Render panes side by side, or the comments below on narrow terminals
+	panels := m.renderMarkdownPane()
+	switch {
+	case m.layout.HideComments:
+	case m.stacked():
+		panels = lipgloss.JoinVertical(lipgloss.Left, panels, m.renderCommentsPane())
+	default:
+		panels = lipgloss.JoinHorizontal(lipgloss.Top, panels, m.renderCommentsPane())
+	}
 
 	// Status bar
 	statusBar := m.renderStatusBar()
@@ -1,10 +1,20 @@
 package tui
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
 	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/markdown/markdowntest"
 	"github.com/paulbuckley/mdmu/internal/store"
+	"github.com/paulbuckley/mdmu/internal/theme"
 )
 
 func TestSelectionRange(t *testing.T) {
@@ -143,3 +153,1011 @@ func TestSplitLines(t *testing.T) {
 		})
 	}
 }
+
+func TestLineWords(t *testing.T) {
+	// "  • item one" where only "item one" was rendered from source
+	segs := []markdown.Segment{{Col: 4, Width: 8, SourceStart: 2, SourceEnd: 10}}
+	words := lineWords("  • \033[1mitem\033[0m one", segs)
+
+	want := []colSpan{{4, 8}, {9, 12}}
+	if len(words) != len(want) {
+		t.Fatalf("lineWords returned %d words, want %d", len(words), len(want))
+	}
+	for i := range want {
+		if words[i] != want[i] {
+			t.Errorf("word %d = %v, want %v", i, words[i], want[i])
+		}
+	}
+}
+
+func TestCommentTargetWordSelection(t *testing.T) {
+	source := []byte("The quick brown fox.\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	m := Model{
+		doc:            doc,
+		source:         source,
+		selectionStart: -1,
+		wordMode:       true,
+		wordCol:        4,
+		wordAnchorLine: -1,
+	}
+	m.moveWord(1)
+	m.wordAnchorLine, m.wordAnchorCol = 0, 4
+
+	c := m.commentTarget()
+	if c.SelectedText != "quick brown" {
+		t.Errorf("SelectedText = %q, want %q", c.SelectedText, "quick brown")
+	}
+	if c.SourceStart != 1 || c.StartCol != 5 || c.EndCol != 15 {
+		t.Errorf("target = line %d cols %d-%d, want line 1 cols 5-15", c.SourceStart, c.StartCol, c.EndCol)
+	}
+}
+
+func TestJumpFootnote(t *testing.T) {
+	source := []byte("A claim[^src].\n\nMore text.\n\n[^src]: The source.\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+
+	m := Model{doc: doc, source: source, height: 40}
+	ref := doc.FootnoteRefs[0]
+	m.cursor = ref.RenderedLine
+
+	m.jumpFootnote()
+	if m.cursor != doc.FootnoteDefs[ref.Index] {
+		t.Fatalf("cursor = %d, want definition line %d", m.cursor, doc.FootnoteDefs[ref.Index])
+	}
+
+	m.jumpFootnote()
+	if m.cursor != ref.RenderedLine {
+		t.Errorf("cursor = %d, want reference line %d", m.cursor, ref.RenderedLine)
+	}
+}
+
+func TestKeyMapOverrides(t *testing.T) {
+	km, err := NewKeyMap("default", map[string][]string{"down": {"j"}})
+	if err != nil {
+		t.Fatalf("NewKeyMap failed: %v", err)
+	}
+	if got := km.Down.Keys(); len(got) != 1 || got[0] != "j" {
+		t.Errorf("Down keys = %v, want [j]", got)
+	}
+	if km.Down.Help().Key != "j" {
+		t.Errorf("Down help = %q, want it to follow the override", km.Down.Help().Key)
+	}
+
+	if _, err := NewKeyMap("default", map[string][]string{"dance": {"x"}}); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+	if _, err := NewKeyMap("default", map[string][]string{"down": {"up"}}); err == nil {
+		t.Error("expected an error for a key bound to two actions")
+	}
+	if _, err := NewKeyMap("teco", nil); err == nil {
+		t.Error("expected an error for an unknown preset")
+	}
+	for _, preset := range Keymaps {
+		if _, err := NewKeyMap(preset, nil); err != nil {
+			t.Errorf("preset %s has clashing keys: %v", preset, err)
+		}
+	}
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestVimKeys(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines: []string{"one", "two", "three", "four"},
+		Mappings: []markdown.LineMapping{
+			{SourceStart: 1, SourceEnd: 1}, {SourceStart: 2, SourceEnd: 2},
+			{SourceStart: 3, SourceEnd: 3}, {SourceStart: 4, SourceEnd: 4},
+		},
+	}
+	km := VimKeyMap()
+	m := NewModel(doc, &store.CommentFile{}, []byte("one\ntwo\nthree\nfour\n"), "test.md", Options{KeyMap: &km})
+	m.height = 40
+
+	m, _ = m.handleKeypress(runes("G"))
+	if m.cursor != 3 {
+		t.Fatalf("cursor = %d after G, want 3", m.cursor)
+	}
+
+	// "g g" is a sequence: the first g waits for the second
+	m, _ = m.handleKeypress(runes("g"))
+	if m.cursor != 3 || m.pendingKeys != "g" {
+		t.Fatalf("after g: cursor = %d, pending = %q", m.cursor, m.pendingKeys)
+	}
+	m, _ = m.handleKeypress(runes("g"))
+	if m.cursor != 0 {
+		t.Fatalf("cursor = %d after gg, want 0", m.cursor)
+	}
+
+	// V starts a line selection that j extends
+	m, _ = m.handleKeypress(runes("V"))
+	m, _ = m.handleKeypress(runes("j"))
+	if start, end := m.selectionRange(); start != 0 || end != 1 {
+		t.Errorf("selection = %d-%d, want 0-1", start, end)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"Intro", "the \x1b[1mAuth\x1b[0m flow", "other", "auth again"},
+		Mappings: make([]markdown.LineMapping, 4),
+	}
+	m := NewModel(doc, &store.CommentFile{}, nil, "test.md", Options{})
+	m.height = 40
+
+	m, _ = m.handleKeypress(runes("/"))
+	if m.mode != modeSearching {
+		t.Fatalf("mode = %v, want searching", m.mode)
+	}
+	m.searchInput.SetValue("auth")
+	m, _ = m.handleSearchInput(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.cursor != 1 {
+		t.Fatalf("cursor = %d, want the first match on line 1", m.cursor)
+	}
+
+	m, _ = m.handleKeypress(runes("n"))
+	if m.cursor != 3 {
+		t.Errorf("cursor = %d after n, want 3", m.cursor)
+	}
+	m, _ = m.handleKeypress(runes("n"))
+	if m.cursor != 1 {
+		t.Errorf("cursor = %d after n, want the search to wrap to 1", m.cursor)
+	}
+	m, _ = m.handleKeypress(runes("N"))
+	if m.cursor != 3 {
+		t.Errorf("cursor = %d after N, want 3", m.cursor)
+	}
+}
+
+func TestThemeColorsDocument(t *testing.T) {
+	source := []byte("# Title\n")
+	for _, th := range []*theme.Theme{theme.Dark(), theme.Light()} {
+		doc, _ := markdown.ParseAndRender(source, 80)
+		m := NewModel(doc, &store.CommentFile{}, source, "test.md", Options{Theme: th})
+		updated, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+		m = updated.(Model)
+		if want := theme.Fg(th.Heading1); !strings.HasPrefix(m.doc.Lines[0], want) {
+			t.Errorf("heading %q should be in the theme's color %q", m.doc.Lines[0], want)
+		}
+	}
+}
+
+func TestCommentsPersisted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.md.mdmu.json")
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	m := NewModel(doc, &store.CommentFile{}, []byte("one\n"), "test.md", Options{StorePath: path})
+
+	m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyEnter})
+	m.textarea.SetValue("needs work")
+	m, _ = m.handleCommentInput(tea.KeyMsg{Type: tea.KeyEnter})
+
+	cf, err := store.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cf.Comments) != 1 || cf.Comments[0].Comment != "needs work" {
+		t.Errorf("persisted comments = %+v, want the new comment", cf.Comments)
+	}
+}
+
+func TestReloadsCommentsFromStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.md.mdmu.json")
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	m := NewModel(doc, &store.CommentFile{}, []byte("one\n"), "test.md", Options{StorePath: path})
+	if m.Init() == nil {
+		t.Fatal("Init should start watching the comment file")
+	}
+
+	// Another session, such as mdmu serve, adds a comment
+	cf := &store.CommentFile{Comments: []store.Comment{{ID: "web", SourceStart: 1, SourceEnd: 1, Comment: "from the browser"}}}
+	if err := store.Save(path, cf); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, cmd := m.Update(fileCheckMsg{})
+	m = updated.(Model)
+	if cmd == nil {
+		t.Error("the comment file should still be watched")
+	}
+	if len(m.commentFile.Comments) != 1 || m.commentFile.Comments[0].ID != "web" {
+		t.Errorf("comments = %+v, want the one saved by the other session", m.commentFile.Comments)
+	}
+}
+
+// reloadFixture writes a document and returns a sized model reviewing it.
+func reloadFixture(t *testing.T, source string) (Model, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.md")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := markdown.ParseAndRender([]byte(source), 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	m := NewModel(doc, &store.CommentFile{}, []byte(source), "plan.md", Options{Path: path})
+	m.width, m.height = 80, 20
+	m.reRender()
+	return m, path
+}
+
+// editFile saves a new version of a file, as an editor or agent would.
+func editFile(t *testing.T, path, source string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadsEditedDocument(t *testing.T) {
+	m, path := reloadFixture(t, "# Plan\n\nFirst.\n\nSecond.\n\nUse a token here.\n")
+	if m.Init() == nil {
+		t.Fatal("Init should start watching the document")
+	}
+	m.commentFile.Comments = []store.Comment{
+		{ID: "line", SourceStart: 5, SourceEnd: 5, SelectedText: "Second."},
+		{ID: "word", SourceStart: 7, SourceEnd: 7, StartCol: 7, EndCol: 11, SelectedText: "token"},
+		{ID: "gone", SourceStart: 3, SourceEnd: 3, SelectedText: "First."},
+	}
+	m = m.moveToLine(5)
+
+	edited := "# Plan\n\nIntro.\n\nFirst, rewritten.\n\nSecond.\n\nUse a token here.\n"
+	editFile(t, path, edited)
+	updated, cmd := m.Update(fileCheckMsg{})
+	m = updated.(Model)
+	if cmd == nil {
+		t.Error("the document should still be watched")
+	}
+	if string(m.source) != edited || !strings.Contains(ansi.Strip(strings.Join(m.doc.Lines, "\n")), "Intro.") {
+		t.Errorf("the edited document should be shown, got %q", m.doc.Lines)
+	}
+	if got := m.sourceLine(m.cursor); got != 7 {
+		t.Errorf("cursor on source line %d, want it to follow its line to 7", got)
+	}
+
+	want := map[string][4]int{"line": {7, 7, 0, 0}, "word": {9, 9, 7, 11}, "gone": {3, 3, 0, 0}}
+	for _, c := range m.commentFile.Comments {
+		if got := [4]int{c.SourceStart, c.SourceEnd, c.StartCol, c.EndCol}; got != want[c.ID] {
+			t.Errorf("comment %s at %v, want %v", c.ID, got, want[c.ID])
+		}
+	}
+	if want := "Reloaded plan.md: the text of 1 comment changed, so check the lines they point at"; m.statusMessage != want {
+		t.Errorf("status = %q, want %q", m.statusMessage, want)
+	}
+}
+
+func TestReloadKeepsSectionFilter(t *testing.T) {
+	m, path := reloadFixture(t, "# Plan\n\n## Auth\n\nUse tokens.\n")
+	m.commentFile.Comments = []store.Comment{{ID: "c1", SourceStart: 5, SourceEnd: 5, SelectedText: "Use tokens."}}
+	f, err := parseFilter("section:auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.setFilter(f)
+	if len(m.listedComments()) != 1 {
+		t.Fatal("the filter should list the comment under Auth")
+	}
+
+	editFile(t, path, "# Plan\n\nIntro.\n\n## Auth\n\nUse tokens.\n")
+	updated, _ := m.Update(fileCheckMsg{})
+	if m = updated.(Model); len(m.listedComments()) != 1 {
+		t.Errorf("after a reload the filter lists %d comments, want the one under Auth", len(m.listedComments()))
+	}
+}
+
+func TestReloadWaitsForCommentInput(t *testing.T) {
+	m, path := reloadFixture(t, "# Plan\n\nFirst.\n")
+	m = m.moveToLine(3)
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	for _, r := range "Why?" {
+		updated, _ = updated.Update(runes(string(r)))
+	}
+	m = updated.(Model)
+	if m.mode != modeCommenting {
+		t.Fatalf("mode = %v, want a comment being typed", m.mode)
+	}
+
+	editFile(t, path, "# Plan\n\nIntro.\n\nFirst.\n")
+	updated, _ = m.Update(fileCheckMsg{})
+	m = updated.(Model)
+	if m.mode != modeCommenting || m.textarea.Value() != "Why?" {
+		t.Fatalf("reloading should leave the comment being typed alone, got mode %v and %q", m.mode, m.textarea.Value())
+	}
+	if string(m.source) != "# Plan\n\nFirst.\n" {
+		t.Error("the document should not change under the comment being typed")
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	updated, _ = updated.Update(fileCheckMsg{})
+	if m = updated.(Model); !strings.Contains(string(m.source), "Intro.") {
+		t.Fatal("the document should reload once the comment is saved")
+	}
+	if c := m.commentFile.Comments[0]; c.Comment != "Why?" || c.SourceStart != 5 {
+		t.Errorf("comment = %+v, want it saved and moved with its line to 5", c)
+	}
+}
+
+func TestHelpOverlay(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	m := NewModel(doc, &store.CommentFile{}, []byte("one\n"), "plan.md", Options{StorePath: "/tmp/plan.md.mdmu.json"})
+	m.width, m.height = 80, 10
+
+	m, _ = m.handleKeypress(runes("?"))
+	if m.mode != modeHelp {
+		t.Fatalf("mode = %v, want help", m.mode)
+	}
+
+	view := ansi.Strip(m.View())
+	for _, want := range []string{"plan.md", "/tmp/plan.md.mdmu.json", "Markdown pane  (current)"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("help should show %q, got:\n%s", want, view)
+		}
+	}
+
+	m, _ = m.handleHelpKeys(tea.KeyMsg{Type: tea.KeyEnd})
+	if want := len(m.helpLines()) - m.previewHeight(); m.helpScroll != want {
+		t.Errorf("helpScroll = %d, want %d", m.helpScroll, want)
+	}
+	m, _ = m.handleHelpKeys(tea.KeyMsg{Type: tea.KeyDown})
+	if want := len(m.helpLines()) - m.previewHeight(); m.helpScroll != want {
+		t.Errorf("helpScroll = %d, should stop at %d", m.helpScroll, want)
+	}
+
+	m, _ = m.handleHelpKeys(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != modeNormal {
+		t.Errorf("mode = %v, want normal after closing help", m.mode)
+	}
+}
+
+func TestSubmitAndApprove(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	cf := &store.CommentFile{}
+	m := NewModel(doc, cf, []byte("one\n"), "test.md", Options{Submits: true})
+
+	m, cmd := m.handleKeypress(runes("S"))
+	if cmd != nil || m.Result() != ResultQuit {
+		t.Errorf("submitting without comments should not exit")
+	}
+
+	m, cmd = m.handleKeypress(runes("A"))
+	if cmd == nil || m.Result() != ResultApproved {
+		t.Errorf("approving without comments should exit approved, got %v", m.Result())
+	}
+
+	cf.Comments = append(cf.Comments, store.Comment{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "fix"})
+	m = NewModel(doc, cf, []byte("one\n"), "test.md", Options{Submits: true})
+	m, cmd = m.handleKeypress(runes("A"))
+	if cmd != nil || m.Result() != ResultQuit {
+		t.Errorf("approving with comments should not exit")
+	}
+	m, cmd = m.handleKeypress(runes("S"))
+	if cmd == nil || m.Result() != ResultSubmitted {
+		t.Errorf("submitting with comments should exit submitted, got %v", m.Result())
+	}
+	if !strings.Contains(m.Output(), "**Comment:** fix") {
+		t.Errorf("Output() = %q, want the formatted review", m.Output())
+	}
+}
+
+func TestSubmitNeedsSomewhereToGo(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	cf := &store.CommentFile{Comments: []store.Comment{{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "fix"}}}
+	m := NewModel(doc, cf, []byte("one\n"), "test.md", Options{})
+	m.width, m.height = 300, 10
+
+	for _, k := range []string{"S", "A"} {
+		var cmd tea.Cmd
+		if m, cmd = m.handleKeypress(runes(k)); cmd != nil || m.Result() != ResultQuit {
+			t.Errorf("%s without --hook or --output should not end the session", k)
+		}
+		if m.statusMessage != noSubmitMessage {
+			t.Errorf("%s: status = %q, want why it can't submit", k, m.statusMessage)
+		}
+	}
+	m.statusMessage = ""
+	if bar := ansi.Strip(m.renderStatusBar()); strings.Contains(bar, "submit") {
+		t.Errorf("status bar %q should not offer to submit", bar)
+	}
+
+	m.opts.Submits = true
+	if bar := ansi.Strip(m.renderStatusBar()); !strings.Contains(bar, "submit") || !strings.Contains(bar, "approve") {
+		t.Errorf("status bar %q should offer to submit and approve with --output", bar)
+	}
+}
+
+func TestCommentCountsFollowTheList(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one", "two", "three"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}, {SourceStart: 2, SourceEnd: 2}, {SourceStart: 3, SourceEnd: 3}},
+	}
+	cf := &store.CommentFile{Comments: []store.Comment{
+		{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "fix this"},
+		{ID: "2", SourceStart: 2, SourceEnd: 2, Comment: "and that"},
+		{ID: "3", SourceStart: 3, SourceEnd: 3, Comment: "done", Status: store.StatusResolved},
+	}}
+	m := NewModel(doc, cf, []byte("one\ntwo\nthree\n"), "test.md", Options{})
+	m.width, m.height = 120, 20
+
+	f, err := parseFilter("this")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.setFilter(f)
+	m = m.openCommentDetail()
+	if detail := ansi.Strip(m.renderCommentDetail()); !strings.Contains(detail, "Comment 1 of 1") {
+		t.Errorf("detail should count the listed comments, got\n%s", detail)
+	}
+
+	m.mode = modeNormal
+	m.layout.HideComments = true
+	if border := ansi.Strip(m.markdownPaneBorder(100, 5, "")); !strings.Contains(border, "Comments (2)") {
+		t.Errorf("collapsed pane should count the open comments, got\n%s", border)
+	}
+}
+
+func TestResolvedCommentsAreNotReviewed(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	cf := &store.CommentFile{Comments: []store.Comment{
+		{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "fix", Status: store.StatusResolved},
+	}}
+	m := NewModel(doc, cf, []byte("one\n"), "test.md", Options{Submits: true})
+
+	m, cmd := m.handleKeypress(runes("S"))
+	if cmd != nil || m.Result() != ResultQuit {
+		t.Errorf("submitting only resolved comments should not exit")
+	}
+	m, _ = m.handleKeypress(runes("C"))
+	if m.statusMessage != "No open comments to copy" {
+		t.Errorf("copying only resolved comments: status %q", m.statusMessage)
+	}
+	m, _ = m.handleKeypress(runes("P"))
+	if m.mode == modePreview {
+		t.Errorf("there is no review to preview")
+	}
+	m, cmd = m.handleKeypress(runes("A"))
+	if cmd == nil || m.Result() != ResultApproved {
+		t.Errorf("approving with every comment resolved should exit approved, got %v", m.Result())
+	}
+}
+
+func TestPreviewFormats(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	cf := &store.CommentFile{Comments: []store.Comment{{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "needs work"}}}
+	m := NewModel(doc, cf, []byte("one\n"), "plan.md", Options{Path: "docs/plan.md"})
+	m.width, m.height = 80, 20
+
+	m, _ = m.handleKeypress(runes("p"))
+	if !strings.Contains(m.previewContent, "**Comment:** needs work") {
+		t.Fatalf("preview should start with the review, got:\n%s", m.previewContent)
+	}
+
+	m, _ = m.handlePreviewKeys(runes("f"))
+	if m.previewContent != "docs/plan.md:1:1: needs work\n" {
+		t.Errorf("quickfix preview = %q", m.previewContent)
+	}
+	if view := ansi.Strip(m.View()); !strings.Contains(view, "Comment Preview (quickfix · ") {
+		t.Errorf("title should name the format, got:\n%s", view)
+	}
+
+	for range previewFormats[1:] {
+		m, _ = m.handlePreviewKeys(runes("f"))
+	}
+	if previewFormats[m.previewFormat] != "review" {
+		t.Errorf("format = %s, want it to cycle back to the review", previewFormats[m.previewFormat])
+	}
+}
+
+func TestResizeRendersWhenSettled(t *testing.T) {
+	source := []byte("A paragraph long enough to wrap differently at each of the widths used here.\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	m := NewModel(doc, &store.CommentFile{}, source, "test.md", Options{})
+
+	resize := func(width int) tea.Cmd {
+		updated, cmd := m.Update(tea.WindowSizeMsg{Width: width, Height: 20})
+		m = updated.(Model)
+		return cmd
+	}
+	resize(120)
+	first := m.doc
+	if cmd := resize(60); cmd == nil || m.doc != first {
+		t.Fatal("a resize should be rendered later, not straight away")
+	}
+	resize(50)
+
+	updated, _ := m.Update(renderMsg{width: 60})
+	if m = updated.(Model); m.doc != first {
+		t.Error("a render for a width the terminal has left should be ignored")
+	}
+	updated, _ = m.Update(renderMsg{width: 50})
+	if m = updated.(Model); m.doc == first {
+		t.Error("the document should be rendered once the width settles")
+	}
+}
+
+func TestCycleViewKeepsSelection(t *testing.T) {
+	source := []byte("# Title\n\nSome **bold** text.\n\n- one\n- two\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	m := NewModel(doc, &store.CommentFile{}, source, "test.md", Options{})
+	m.width, m.height = 120, 20
+	m.reRender()
+
+	m.cursor = firstRow(m.doc, 3)
+	m.selectionStart = firstRow(m.doc, 5)
+	want := m.commentTarget()
+	for _, v := range []view{viewSource, viewSplit, viewRendered} {
+		m, _ = m.handleKeypress(runes("r"))
+		if m.view != v {
+			t.Fatalf("view = %v, want %v", m.view, v)
+		}
+		if got := m.commentTarget(); got.SourceStart != want.SourceStart || got.SourceEnd != want.SourceEnd {
+			t.Errorf("%v view: selection targets lines %d-%d, want %d-%d", v, got.SourceStart, got.SourceEnd, want.SourceStart, want.SourceEnd)
+		}
+	}
+
+	m.clearSelection()
+	m.cursor = firstRow(m.doc, 3)
+	m, _ = m.handleKeypress(runes("r"))
+	if !strings.Contains(ansi.Strip(m.View()), "3 │ Some **bold** text.") {
+		t.Errorf("source view should show numbered source lines, got:\n%s", ansi.Strip(m.View()))
+	}
+	if got := m.sourceLine(m.cursor); got != 3 {
+		t.Errorf("cursor on source line %d, want 3", got)
+	}
+
+	m, _ = m.handleKeypress(runes("r"))
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "Markdown (side by side)") || !strings.Contains(view, "Some bold text.") ||
+		!strings.Contains(view, "**bold**") {
+		t.Errorf("side-by-side view should show rendered and source text, got:\n%s", view)
+	}
+}
+
+func TestPaneLayout(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	cf := &store.CommentFile{Comments: []store.Comment{{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "fix"}}}
+	m := NewModel(doc, cf, []byte("one\n"), "test.md", Options{Layout: Layout{Split: 0.65}})
+	m.width, m.height = 120, 30
+
+	if m.stacked() || m.leftWidth() != 78 {
+		t.Fatalf("panes should start side by side with the markdown at 78 columns, got %d", m.leftWidth())
+	}
+	m, _ = m.handleKeypress(runes(">"))
+	m, _ = m.handleKeypress(runes(">"))
+	if m.leftWidth() != 90 || m.leftWidth()+m.rightWidth() != m.width {
+		t.Errorf("after growing twice the panes are %d and %d columns, want 90 and 30", m.leftWidth(), m.rightWidth())
+	}
+	for range 20 {
+		m, _ = m.handleKeypress(runes("<"))
+	}
+	if m.layout.Split != minSplit {
+		t.Errorf("split = %g, should stop at %g", m.layout.Split, minSplit)
+	}
+
+	m, _ = m.handleKeypress(runes("\\"))
+	view := ansi.Strip(m.View())
+	if m.leftWidth() != m.width || strings.Contains(view, "No comments yet") || !strings.Contains(view, "Markdown · Comments (1)") {
+		t.Errorf("a collapsed comments pane should leave a count in the markdown title, got:\n%s", view)
+	}
+	m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyTab})
+	if m.layout.HideComments || m.focusPane != paneComments {
+		t.Error("switching panes should show and focus the collapsed comments pane")
+	}
+
+	// Narrow terminals stack the panes, filling the screen above the status bar
+	m.width = 60
+	if !m.stacked() {
+		t.Fatal("a narrow terminal should stack the panes")
+	}
+	if rows := strings.Count(m.View(), "\n") + 1; rows != m.height {
+		t.Errorf("stacked view has %d rows, want %d\n%s", rows, m.height, ansi.Strip(m.View()))
+	}
+	m, _ = m.handleKeypress(runes("|"))
+	if m.stacked() || m.layout.Panes != "side" {
+		t.Errorf("toggling should put the panes side by side, got panes %q", m.layout.Panes)
+	}
+
+	layout, changed := m.Layout()
+	if changed != (LayoutChanges{Split: true, Panes: true, HideComments: true}) || layout.Split != minSplit || layout.Panes != "side" {
+		t.Errorf("Layout() = %+v, %v, want the layout chosen with keys", layout, changed)
+	}
+}
+
+func TestCommentsPane(t *testing.T) {
+	doc := &markdown.RenderedDocument{
+		Lines:    []string{"one"},
+		Mappings: []markdown.LineMapping{{SourceStart: 1, SourceEnd: 1}},
+	}
+	long := "This comment is long enough that it has to wrap over several rows of the comments pane to be read in full."
+	cf := &store.CommentFile{}
+	for i := range 10 {
+		cf.Comments = append(cf.Comments, store.Comment{ID: fmt.Sprint(i), SourceStart: i + 1, SourceEnd: i + 1,
+			SelectedText: fmt.Sprintf("line %d", i+1), Comment: fmt.Sprintf("comment %d", i+1)})
+	}
+	cf.Comments[0].Comment = long
+	cf.Comments[9].Replies = []store.Reply{{Author: "agent", Body: "Fixed in the next revision."}}
+	m := NewModel(doc, cf, []byte("one\n"), "test.md", Options{})
+	m.width, m.height = 100, 16
+
+	m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyTab})
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "│ line 1") || !strings.Contains(view, "read in full.") {
+		t.Errorf("comments should show their quote and wrapped body, got:\n%s", view)
+	}
+
+	for range 9 {
+		m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	view = ansi.Strip(m.View())
+	if !strings.Contains(view, "comment 10") || strings.Contains(view, "│ line 1 ") {
+		t.Errorf("the pane should scroll to the focused last comment, got:\n%s", view)
+	}
+
+	m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != modeDetail {
+		t.Fatalf("mode = %v, want the comment detail", m.mode)
+	}
+	view = ansi.Strip(m.View())
+	for _, want := range []string{"Comment 10 of 10", "comment 10", "↩ agent", "Fixed in the next revision."} {
+		if !strings.Contains(view, want) {
+			t.Errorf("detail should show %q, got:\n%s", want, view)
+		}
+	}
+	m, _ = m.handleDetailKeys(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != modeNormal || m.focusPane != paneComments {
+		t.Errorf("closing the detail should return to the comments pane")
+	}
+}
+
+func TestCommentFilter(t *testing.T) {
+	source := []byte("# Plan\n\nIntro.\n\n## Auth\n\nTokens.\n")
+	comments := []store.Comment{
+		{ID: "1", SourceStart: 3, SourceEnd: 3, SelectedText: "Intro.", Comment: "Too short"},
+		{ID: "2", SourceStart: 5, SourceEnd: 5, SelectedText: "Auth", Comment: "Rename", Status: store.StatusResolved},
+		{ID: "3", SourceStart: 7, SourceEnd: 7, SelectedText: "Tokens.", Comment: "Which tokens?",
+			Replies: []store.Reply{{Author: "agent", Body: "JWTs"}}},
+	}
+	m := NewModel(nil, &store.CommentFile{Comments: comments}, source, "plan.md", Options{})
+	m.headings = markdown.Headings(source)
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "123"},
+		{"short", "1"},
+		{"tokens", "3"},
+		{"Tokens", "3"},
+		{"jwts", "3"},
+		{"L4-7", "23"},
+		{"l3", "1"},
+		{"section:auth", "23"},
+		{"is:open", "13"},
+		{"is:resolved", "2"},
+		{"is:replied section:plan", "3"},
+	}
+	for _, tt := range tests {
+		f, err := parseFilter(tt.query)
+		if err != nil {
+			t.Fatalf("parseFilter(%q) failed: %v", tt.query, err)
+		}
+		m.filter = f
+		var got string
+		for _, c := range m.listedComments() {
+			got += c.ID
+		}
+		if got != tt.want {
+			t.Errorf("filter %q lists %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	if _, err := parseFilter("is:done"); err == nil {
+		t.Error("an unknown status should be an error")
+	}
+}
+
+func TestCommentFilterBar(t *testing.T) {
+	source := []byte("one\n\ntwo\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	cf := &store.CommentFile{Comments: []store.Comment{
+		{ID: "1", SourceStart: 1, SourceEnd: 1, Comment: "first"},
+		{ID: "2", SourceStart: 3, SourceEnd: 3, Comment: "second"},
+	}}
+	m := NewModel(doc, cf, source, "test.md", Options{})
+	m.width, m.height = 100, 12
+	m.reRender()
+
+	m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyTab})
+	m, _ = m.handleKeypress(runes("/"))
+	if m.mode != modeFiltering {
+		t.Fatalf("mode = %v, want filtering", m.mode)
+	}
+	for _, r := range "second" {
+		m, _ = m.handleFilterInput(runes(string(r)))
+	}
+	m, _ = m.handleFilterInput(tea.KeyMsg{Type: tea.KeyEnter})
+
+	view := ansi.Strip(m.View())
+	if !strings.Contains(view, "Comments (1 of 2 · second)") || strings.Contains(view, "first") {
+		t.Errorf("the pane should list only the matching comment, got:\n%s", view)
+	}
+	marks := m.commentMarks()
+	if marks[1] || !marks[3] {
+		t.Errorf("marks = %v, want line 1 dimmed and line 3 listed", marks)
+	}
+
+	m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.filter.active() || len(m.listedComments()) != 2 {
+		t.Error("cancel in the comments pane should clear the filter")
+	}
+}
+
+func TestFolding(t *testing.T) {
+	source := []byte("# Plan\n\nIntro.\n\n## Steps\n\n- one\n- two\n\n## Risks\n\nNone.\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	m := NewModel(doc, &store.CommentFile{}, source, "plan.md", Options{})
+	m.width, m.height = 120, 30
+	m.reRender()
+	rows := len(m.doc.Lines)
+
+	// Folding inside the list folds the list, and on its row unfolds it
+	m.cursor = firstRow(m.doc, 7)
+	m, _ = m.handleKeypress(runes("z"))
+	if got := m.doc.Mappings[m.cursor]; got.SourceStart != 7 || got.SourceEnd != 8 {
+		t.Fatalf("cursor on a row of lines %d-%d, want the folded list 7-8", got.SourceStart, got.SourceEnd)
+	}
+	m, _ = m.handleKeypress(runes("z"))
+	m.cursor = firstRow(m.doc, 5)
+	m, _ = m.handleKeypress(runes("z"))
+	if target := m.commentTarget(); target.SourceStart != 5 || target.SourceEnd != 8 {
+		t.Errorf("a comment on the folded section targets lines %d-%d, want 5-8", target.SourceStart, target.SourceEnd)
+	}
+	if view := ansi.Strip(m.View()); !strings.Contains(view, "Steps ⋯ 4 lines") || strings.Contains(view, "• one") {
+		t.Errorf("the section should show as one summary row, got:\n%s", view)
+	}
+
+	m, _ = m.handleKeypress(runes("2"))
+	if len(m.folds) != 2 || len(m.doc.Lines) >= rows-4 {
+		t.Errorf("folding level 2 should fold both subsections, got %+v", m.folds)
+	}
+	m, _ = m.handleKeypress(runes("0"))
+	if len(m.folds) != 0 || len(m.doc.Lines) != rows {
+		t.Errorf("unfolding should restore all %d rows, got %d", rows, len(m.doc.Lines))
+	}
+}
+
+func TestFollowLink(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "setup.md"), []byte("# Setup\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := []byte("See [setup](setup.md) and [the site](https://example.com).\n\n[Gone](missing.md)\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	var opened string
+	open := func(path string) *exec.Cmd {
+		opened = path
+		return exec.Command("true")
+	}
+	m := NewModel(doc, &store.CommentFile{}, source, "plan.md", Options{Path: filepath.Join(dir, "plan.md"), Open: open})
+	m.width, m.height = 120, 30
+	m.reRender()
+
+	// The first link on the row is followed
+	m, cmd := m.handleKeypress(runes("o"))
+	if cmd == nil || opened != filepath.Join(dir, "setup.md") {
+		t.Fatalf("following the link opened %q, want setup.md in the document's directory", opened)
+	}
+	updated, _ := m.Update(linkClosedMsg{path: opened})
+	if m = updated.(Model); m.statusMessage != "Back from setup.md" {
+		t.Errorf("status after the review = %q", m.statusMessage)
+	}
+
+	// In word mode the link under the cursor is followed
+	m, _ = m.handleKeypress(runes("v"))
+	for i := 0; i < 4; i++ {
+		m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyRight})
+	}
+	opened = ""
+	m, cmd = m.handleKeypress(runes("o"))
+	if cmd != nil || opened != "" || !strings.Contains(m.statusMessage, "Only links to markdown files") {
+		t.Errorf("a web link should not be followed, status %q", m.statusMessage)
+	}
+	m, _ = m.handleKeypress(runes("v"))
+
+	m.cursor = firstRow(m.doc, 3)
+	m, cmd = m.handleKeypress(runes("o"))
+	if cmd != nil || !strings.Contains(m.statusMessage, "missing.md") {
+		t.Errorf("a link to a missing file should be reported, status %q", m.statusMessage)
+	}
+}
+
+func TestAnchorJumps(t *testing.T) {
+	source := []byte("# Plan\n\nSee [rollout](#rollout) or [nowhere](#nowhere).\n\n## Steps\n\nText.\n\n## Rollout\n\nAfter the [steps](#steps).\n")
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		t.Fatalf("ParseAndRender failed: %v", err)
+	}
+	m := NewModel(doc, &store.CommentFile{}, source, "plan.md", Options{})
+	m.width, m.height = 120, 30
+	m.reRender()
+	back := tea.KeyMsg{Type: tea.KeyLeft, Alt: true}
+	forward := tea.KeyMsg{Type: tea.KeyRight, Alt: true}
+	at := func(want int) {
+		t.Helper()
+		if got := m.sourceLine(m.cursor); got != want {
+			t.Errorf("cursor on line %d, want %d", got, want)
+		}
+	}
+
+	m.cursor = firstRow(m.doc, 3)
+	m, _ = m.handleKeypress(runes("o"))
+	at(9)
+	m.cursor = firstRow(m.doc, 11)
+	m, _ = m.handleKeypress(runes("o"))
+	at(5)
+
+	// Back and forward retrace the jumps like a browser's history
+	m, _ = m.handleKeypress(back)
+	at(11)
+	m, _ = m.handleKeypress(back)
+	at(3)
+	m, _ = m.handleKeypress(back)
+	at(3)
+	if m.statusMessage != "No earlier jump" {
+		t.Errorf("status = %q at the start of the jump list", m.statusMessage)
+	}
+	m, _ = m.handleKeypress(forward)
+	at(11)
+
+	// A new jump drops the jumps forward
+	m.cursor = firstRow(m.doc, 11)
+	m, _ = m.handleKeypress(runes("o"))
+	m, _ = m.handleKeypress(forward)
+	at(5)
+	if m.statusMessage != "No later jump" {
+		t.Errorf("status = %q after a new jump", m.statusMessage)
+	}
+
+	// In word mode the link under the cursor is followed
+	m.cursor = firstRow(m.doc, 3)
+	m, _ = m.handleKeypress(runes("v"))
+	for i := 0; i < 3; i++ {
+		m, _ = m.handleKeypress(tea.KeyMsg{Type: tea.KeyRight})
+	}
+	m, _ = m.handleKeypress(runes("o"))
+	at(3)
+	if m.statusMessage != "✗ No heading #nowhere" {
+		t.Errorf("status = %q for a link to a missing heading", m.statusMessage)
+	}
+}
+
+// largeModel returns a model of a generated document of about 100k lines,
+// sized to a terminal.
+func largeModel(b *testing.B) Model {
+	source := markdowntest.Large(100_000)
+	doc, err := markdown.ParseAndRender(source, 80)
+	if err != nil {
+		b.Fatalf("ParseAndRender failed: %v", err)
+	}
+	m := NewModel(doc, &store.CommentFile{}, source, "large.md", Options{})
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	return updated.(Model)
+}
+
+func BenchmarkScroll(b *testing.B) {
+	m := largeModel(b)
+	m.cursor = len(m.doc.Lines) / 2
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	b.ResetTimer()
+	for b.Loop() {
+		updated, _ := m.Update(down)
+		m = updated.(Model)
+		m.View()
+	}
+}
+
+func TestSourceRow(t *testing.T) {
+	source := []byte("# Plan\n\nA paragraph long enough to wrap in a narrow source column.\n\n- one\n")
+	doc := markdown.Parse(source).RenderSource(20)
+	for n := 0; n <= 7; n++ {
+		if got, want := sourceRow(doc, n), firstRow(doc, n); got != want {
+			t.Errorf("sourceRow(%d) = %d, want %d as firstRow finds", n, got, want)
+		}
+	}
+}
+
+// BenchmarkScrollSideBySide measures scrolling with the source column shown
+// beside the rendered document.
+func BenchmarkScrollSideBySide(b *testing.B) {
+	m := largeModel(b)
+	m.view = viewSplit
+	m.reRender()
+	m.cursor = len(m.doc.Lines) / 2
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	b.ResetTimer()
+	for b.Loop() {
+		updated, _ := m.Update(down)
+		m = updated.(Model)
+		m.View()
+	}
+}
+
+// BenchmarkResize measures resizing between widths rendered before, as
+// when a terminal is resized back and forth.
+func BenchmarkResize(b *testing.B) {
+	m := largeModel(b)
+	for _, width := range []int{100, 120} {
+		m.width = width
+		m.reRender()
+	}
+	for i := 0; b.Loop(); i++ {
+		width := 100 + 20*(i%2)
+		updated, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: 40})
+		updated, _ = updated.Update(renderMsg{width: width})
+		m = updated.(Model)
+		m.View()
+	}
+}
+
+// BenchmarkResizeNewWidth measures resizing to widths not rendered before,
+// as when a terminal is dragged wider.
+func BenchmarkResizeNewWidth(b *testing.B) {
+	m := largeModel(b)
+	for i := 0; b.Loop(); i++ {
+		width := 121 + i
+		updated, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: 40})
+		updated, _ = updated.Update(renderMsg{width: width})
+		m = updated.(Model)
+		m.View()
+	}
+}
@@ -1,15 +1,21 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/paulbuckley/mdmu/internal/clipboard"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/paulbuckley/mdmu/internal/output"
 )
 
+// previewFormats are the formats the preview cycles through: the review,
+// then the diagnostic formats for editors.
+var previewFormats = append([]string{"review"}, output.DiagnosticFormats...)
+
 func (m Model) enterPreviewMode() Model {
-	m.previewContent = output.Format(m.commentFile, m.source, m.filename)
+	m.previewContent, m.previewReport = m.previewOutput()
 	m.previewScroll = 0
 	m.copiedMessage = false
 	m.mode = modePreview
@@ -17,18 +23,40 @@ func (m Model) enterPreviewMode() Model {
 }
 
 func (m Model) handlePreviewKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
-	switch msg.String() {
-	case "q":
+	k, ok := m.keySequence(msg)
+	if !ok {
+		return m, nil
+	}
+
+	maxScroll := len(strings.Split(m.previewContent, "\n")) - m.previewHeight()
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+
+	switch {
+	case key.Matches(k, m.keys.Quit):
 		return m, tea.Quit
 
-	case "esc":
+	case key.Matches(k, m.keys.Help):
+		return m.openHelp(), nil
+
+	case key.Matches(k, m.keys.Submit):
+		return m.submit()
+
+	case key.Matches(k, m.keys.Cancel):
 		m.mode = modeNormal
 		m.copiedMessage = false
 		m.statusMessage = ""
 		return m, nil
 
-	case "c", "C":
-		if err := clipboard.Copy(m.previewContent); err != nil {
+	case key.Matches(k, m.keys.Format):
+		m.previewFormat = (m.previewFormat + 1) % len(previewFormats)
+		m.previewContent, m.previewReport = m.previewOutput()
+		m.previewScroll = 0
+		return m, nil
+
+	case key.Matches(k, m.keys.Copy):
+		if err := m.opts.Clipboard.Copy(m.previewContent); err != nil {
 			m.statusMessage = "✗ Failed to copy: " + err.Error()
 		} else {
 			m.statusMessage = "✓ Copied to clipboard"
@@ -37,46 +65,73 @@ func (m Model) handlePreviewKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 		m.copiedMessage = false
 		return m, nil
 
-	case "up":
+	case key.Matches(k, m.keys.Up):
 		if m.previewScroll > 0 {
 			m.previewScroll--
 		}
 		return m, nil
 
-	case "down":
-		lines := strings.Split(m.previewContent, "\n")
-		maxScroll := len(lines) - m.previewHeight()
-		if maxScroll < 0 {
-			maxScroll = 0
-		}
+	case key.Matches(k, m.keys.Down):
 		if m.previewScroll < maxScroll {
 			m.previewScroll++
 		}
 		return m, nil
 
-	case "pgup":
+	case key.Matches(k, m.keys.PageUp):
 		m.previewScroll -= m.previewHeight()
 		if m.previewScroll < 0 {
 			m.previewScroll = 0
 		}
 		return m, nil
 
-	case "pgdown":
-		lines := strings.Split(m.previewContent, "\n")
-		maxScroll := len(lines) - m.previewHeight()
-		if maxScroll < 0 {
-			maxScroll = 0
-		}
+	case key.Matches(k, m.keys.PageDown):
 		m.previewScroll += m.previewHeight()
 		if m.previewScroll > maxScroll {
 			m.previewScroll = maxScroll
 		}
 		return m, nil
+
+	case key.Matches(k, m.keys.Top):
+		m.previewScroll = 0
+		return m, nil
+
+	case key.Matches(k, m.keys.Bottom):
+		m.previewScroll = maxScroll
+		return m, nil
 	}
 
 	return m, nil
 }
 
+// previewOutput formats the comments in the selected preview format. Only
+// the review is fitted to the size budget.
+func (m Model) previewOutput() (string, output.Report) {
+	format := previewFormats[m.previewFormat]
+	if format == "review" {
+		return m.formatReport()
+	}
+	path := m.opts.Path
+	if path == "" {
+		path = m.filename
+	}
+	out, err := output.Diagnostics(format, m.commentFile, m.source, path)
+	if err != nil {
+		out = "Failed to format comments: " + err.Error()
+	}
+	return out, output.Report{Size: output.Measure(out)}
+}
+
+// budgetNote describes how the previewed output fits its size budget.
+func (m Model) budgetNote() string {
+	switch {
+	case m.previewReport.OverBudget:
+		return "over budget"
+	case m.previewReport.Abbreviated:
+		return "quotes abbreviated to fit"
+	}
+	return ""
+}
+
 func (m Model) previewHeight() int {
 	h := m.height - 4 // title + status bar + borders
 	if h < 1 {
@@ -92,7 +147,8 @@ func (m Model) renderPreview() string {
 	}
 
 	// Title
-	title := previewTitleStyle.Render("Comment Preview")
+	title := m.styles.previewTitle.Render(fmt.Sprintf("Comment Preview (%s · %s)",
+		previewFormats[m.previewFormat], m.previewReport.Size))
 
 	// Content
 	lines := strings.Split(m.previewContent, "\n")
@@ -111,7 +167,7 @@ func (m Model) renderPreview() string {
 	content := strings.Join(visibleLines, "\n")
 
 	// Border around content
-	bordered := activeBorderStyle.Width(width - 2).Render(title + "\n" + content)
+	bordered := m.styles.activeBorder.Width(width - 2).Render(title + "\n" + content)
 
 	// Status bar
 	statusBar := m.renderPreviewStatusBar()
@@ -125,11 +181,31 @@ func (m Model) renderPreviewStatusBar() string {
 		width = 80
 	}
 
-	hints := " " +
-		statusKeyStyle.Render("C") + " copy  " +
-		statusKeyStyle.Render("↑↓") + " or " + statusKeyStyle.Render("PgUp/PgDn") + " scroll  " +
-		statusKeyStyle.Render("Esc") + " return  " +
-		statusKeyStyle.Render("q") + " quit"
+	km := m.keys
+	var submit hint
+	if m.opts.Submits {
+		submit = bindingHint(km.Submit, "submit")
+	}
+	hints := " " + m.styles.formatHints(
+		bindingHint(km.Copy, "copy"),
+		submit,
+		bindingHint(km.Format, "format"),
+		pairHint(km.Up, km.Down, "scroll"),
+		pairHint(km.PageUp, km.PageDown, "page"),
+		bindingHint(km.Cancel, "return"),
+		bindingHint(km.Help, "help"),
+		bindingHint(km.Quit, "quit"))
+
+	// The size, and how it fits the budget, goes on the right
+	size := m.previewReport.Size.String()
+	if note := m.budgetNote(); note != "" {
+		size = note + " · " + size
+	}
+	size += " "
+	gap := width - lipgloss.Width(hints) - lipgloss.Width(size)
+	if gap > 0 {
+		hints += strings.Repeat(" ", gap) + size
+	}
 
-	return statusBarStyle.Width(width).Render(hints)
+	return m.styles.statusBar.Width(width).Render(hints)
 }
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func (m Model) startSearch() (Model, tea.Cmd) {
+	m.clearSelection()
+	m.mode = modeSearching
+	m.searchInput = textinput.New()
+	m.searchInput.Prompt = "/"
+	m.searchInput.SetValue(m.searchQuery)
+	m.searchInput.CursorEnd()
+	return m, m.searchInput.Focus()
+}
+
+func (m Model) handleSearchInput(msg tea.KeyMsg) (Model, tea.Cmd) {
+	k := keySeq(msg.String())
+	switch {
+	case k.isText():
+
+	case key.Matches(k, m.keys.Cancel):
+		m.mode = modeNormal
+		return m, nil
+
+	case key.Matches(k, m.keys.Confirm):
+		m.mode = modeNormal
+		m.searchQuery = m.searchInput.Value()
+		if m.searchQuery != "" {
+			m.findMatch(1)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	return m, cmd
+}
+
+// findMatch moves the cursor to the next rendered line containing the search
+// query in the given direction, wrapping around the document.
+func (m *Model) findMatch(dir int) {
+	if m.searchQuery == "" {
+		m.statusMessage = "No search pattern"
+		return
+	}
+
+	n := len(m.doc.Lines)
+	for i := 1; i <= n; i++ {
+		line := ((m.cursor+dir*i)%n + n) % n
+		if lineMatches(m.doc.Lines[line], m.searchQuery) {
+			m.cursor = line
+			m.statusMessage = ""
+			m.ensureCursorVisible()
+			return
+		}
+	}
+	m.statusMessage = "Pattern not found: " + m.searchQuery
+}
+
+// lineMatches reports whether the visible text of a rendered line contains
+// the query, ignoring case unless the query has upper-case letters.
+func lineMatches(line, query string) bool {
+	text := ansi.Strip(line)
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		text = strings.ToLower(text)
+	}
+	return strings.Contains(text, query)
+}
@@ -1,6 +1,55 @@
 package tui
 
-import "fmt"
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// hint is a key label and what it does, shown in the status bar.
+type hint struct {
+	label, desc string
+}
+
+// bindingHint labels a hint with the binding's first key.
+func bindingHint(b key.Binding, desc string) hint {
+	return hint{shortLabel(b), desc}
+}
+
+// pairHint labels a hint with the first keys of two bindings, such as "↑↓".
+func pairHint(a, b key.Binding, desc string) hint {
+	la, lb := shortLabel(a), shortLabel(b)
+	if la == "" || lb == "" {
+		return hint{la + lb, desc}
+	}
+	if isArrow(la) && isArrow(lb) {
+		return hint{la + lb, desc}
+	}
+	if p := strings.TrimSuffix(la, "↑"); p != la && strings.TrimSuffix(lb, "↓") == p {
+		return hint{la + "↓", desc}
+	}
+	if p := strings.TrimSuffix(la, "←"); p != la && strings.TrimSuffix(lb, "→") == p {
+		return hint{la + "→", desc}
+	}
+	return hint{la + "/" + lb, desc}
+}
+
+func isArrow(label string) bool {
+	return strings.ContainsAny(label, "↑↓←→") && len([]rune(label)) == 1
+}
+
+// formatHints renders hints for the status bar, skipping unbound keys.
+func (s styles) formatHints(hints ...hint) string {
+	var parts []string
+	for _, h := range hints {
+		if h.label == "" {
+			continue
+		}
+		parts = append(parts, s.statusKey.Render(h.label)+" "+h.desc)
+	}
+	return strings.Join(parts, "  ")
+}
 
 func (m Model) renderStatusBar() string {
 	width := m.width
@@ -8,42 +57,86 @@ func (m Model) renderStatusBar() string {
 		width = 80
 	}
 
+	km := m.keys
+
+	// Where the review is delivered on exit, it's submitted or approved
+	var copyHint, approveHint hint
+	if m.opts.Submits {
+		copyHint = bindingHint(km.Submit, "submit")
+		approveHint = bindingHint(km.Approve, "approve")
+	} else {
+		copyHint = bindingHint(km.Copy, "copy")
+	}
+
 	var hints string
 	switch {
 	case m.mode == modeCommenting:
-		hints = fmt.Sprintf(" %s save  %s newline  %s cancel",
-			statusKeyStyle.Render("Enter"),
-			statusKeyStyle.Render("Alt+Enter"),
-			statusKeyStyle.Render("Esc"))
+		hints = m.styles.formatHints(
+			bindingHint(km.Confirm, "save"),
+			bindingHint(km.Newline, "newline"),
+			bindingHint(km.Cancel, "cancel"))
+
+	case m.mode == modeFiltering:
+		hints = m.filterInput.View() + "  " + m.styles.formatHints(
+			bindingHint(km.Confirm, "done"),
+			bindingHint(km.Cancel, "clear"))
+
+	case m.mode == modeSearching:
+		hints = m.searchInput.View() + "  " + m.styles.formatHints(
+			bindingHint(km.Confirm, "search"),
+			bindingHint(km.Cancel, "cancel"))
+
+	case m.mode == modeSelecting && m.lineSelect:
+		hints = m.styles.formatHints(
+			pairHint(km.Up, km.Down, "extend"),
+			bindingHint(km.Comment, "comment"),
+			bindingHint(km.SelectLines, "cancel"))
 
 	case m.mode == modeSelecting:
-		hints = fmt.Sprintf(" %s extend  %s comment  %s cancel",
-			statusKeyStyle.Render("Shift+↑↓"),
-			statusKeyStyle.Render("Enter"),
-			statusKeyStyle.Render("Esc"))
+		hints = m.styles.formatHints(
+			pairHint(km.SelectUp, km.SelectDown, "extend"),
+			bindingHint(km.Comment, "comment"),
+			bindingHint(km.Cancel, "cancel"))
+
+	case m.wordMode && m.focusPane == paneMarkdown:
+		hints = m.styles.formatHints(
+			pairHint(km.WordLeft, km.WordRight, "word"),
+			pairHint(km.SelectWordLeft, km.SelectWordRight, "select words"),
+			bindingHint(km.Comment, "comment"),
+			bindingHint(km.WordMode, "line mode"))
 
 	case m.focusPane == paneComments:
-		hints = fmt.Sprintf(" %s navigate  %s delete  %s markdown  %s quit",
-			statusKeyStyle.Render("↑↓"),
-			statusKeyStyle.Render("d"),
-			statusKeyStyle.Render("Tab"),
-			statusKeyStyle.Render("q"))
+		hints = m.styles.formatHints(
+			pairHint(km.Up, km.Down, "navigate"),
+			bindingHint(km.Expand, "expand"),
+			bindingHint(km.Filter, "filter"),
+			bindingHint(km.Delete, "delete"),
+			bindingHint(km.SwitchPane, "markdown"),
+			bindingHint(km.Help, "help"),
+			bindingHint(km.Quit, "quit"))
 
 	default:
-		hints = fmt.Sprintf(" %s navigate  %s select  %s comment  %s comments  %s preview  %s copy  %s quit",
-			statusKeyStyle.Render("↑↓"),
-			statusKeyStyle.Render("Shift+↑↓"),
-			statusKeyStyle.Render("Enter"),
-			statusKeyStyle.Render("Tab"),
-			statusKeyStyle.Render("P"),
-			statusKeyStyle.Render("C"),
-			statusKeyStyle.Render("q"))
+		hints = m.styles.formatHints(
+			pairHint(km.Up, km.Down, "navigate"),
+			pairHint(km.SelectUp, km.SelectDown, "select"),
+			bindingHint(km.WordMode, "words"),
+			bindingHint(km.ToggleView, "view"),
+			bindingHint(km.Comment, "comment"),
+			bindingHint(km.SwitchPane, "comments"),
+			bindingHint(km.Preview, "preview"),
+			copyHint,
+			approveHint,
+			bindingHint(km.Help, "help"),
+			bindingHint(km.Quit, "quit"))
 	}
+	hints = " " + hints
 
 	// Prepend status message if present
 	if m.statusMessage != "" {
 		hints = " " + m.statusMessage + "  |" + hints
 	}
 
-	return statusBarStyle.Width(width).Render(hints)
+	// Keep to one row on narrow terminals, rather than wrapping
+	hints = ansi.Truncate(hints, width-2, "…") // within the padding
+	return m.styles.statusBar.Width(width).Render(hints)
 }
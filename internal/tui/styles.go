@@ -1,73 +1,116 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
-
-var (
-	// Pane border styles
-	activeBorderStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("62"))
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/paulbuckley/mdmu/internal/theme"
+)
 
-	inactiveBorderStyle = lipgloss.NewStyle().
-				Border(lipgloss.RoundedBorder()).
-				BorderForeground(lipgloss.Color("240"))
+// styles are the interface styles of a theme.
+type styles struct {
+	// Pane borders
+	activeBorder   lipgloss.Style
+	inactiveBorder lipgloss.Style
 
 	// Line highlighting
-	cursorLineStyle = lipgloss.NewStyle().
-			Background(lipgloss.Color("236"))
-
-	selectedLineStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("24"))
+	cursorLine   lipgloss.Style
+	selectedLine lipgloss.Style
 
 	// Comment pane
-	commentHighlightStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("236"))
-
-	commentHeaderStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("62")).
-				Bold(true)
-
-	commentTextStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("252"))
-
-	commentLineRefStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("243"))
+	commentHighlight lipgloss.Style
+	commentHeader    lipgloss.Style
+	commentText      lipgloss.Style
+	commentLineRef   lipgloss.Style
 
 	// Status bar
-	statusBarStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("252")).
-			Background(lipgloss.Color("236")).
-			Padding(0, 1)
-
-	statusKeyStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("229")).
-			Background(lipgloss.Color("236")).
-			Bold(true)
-
-	// Title styles
-	paneTitle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("62")).
-			Bold(true).
-			Padding(0, 1)
+	statusBar lipgloss.Style
+	statusKey lipgloss.Style
 
-	// Comment input modal
-	modalStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("62")).
-			Padding(0, 1)
+	// Pane titles
+	paneTitle lipgloss.Style
 
-	modalTitleStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("229")).
-			Bold(true)
+	// Comment input modal
+	modal      lipgloss.Style
+	modalTitle lipgloss.Style
 
 	// Empty state
-	emptyStateStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("243")).
-			Italic(true)
+	emptyState lipgloss.Style
 
 	// Preview title
-	previewTitleStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("62")).
-				Bold(true).
-				Padding(0, 1)
-)
+	previewTitle lipgloss.Style
+}
+
+// newStyles builds the interface styles from a theme.
+func newStyles(t *theme.Theme) styles {
+	var s styles
+	s.activeBorder = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(t.Accent))
+
+	s.inactiveBorder = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(t.Border))
+
+	// Without background colors, fall back to attributes so the cursor and
+	// selection stay visible
+	s.cursorLine = lipgloss.NewStyle().
+		Background(lipgloss.Color(t.CursorBg))
+	if t.CursorBg == "" {
+		s.cursorLine = s.cursorLine.Bold(true)
+	}
+
+	s.selectedLine = lipgloss.NewStyle().
+		Background(lipgloss.Color(t.SelectionBg))
+	if t.SelectionBg == "" {
+		s.selectedLine = s.selectedLine.Reverse(true)
+	}
+
+	s.commentHighlight = lipgloss.NewStyle().
+		Background(lipgloss.Color(t.CursorBg))
+	if t.CursorBg == "" {
+		s.commentHighlight = s.commentHighlight.Reverse(true)
+	}
+
+	s.commentHeader = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.Accent)).
+		Bold(true)
+
+	s.commentText = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.Text))
+
+	s.commentLineRef = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.Subtle))
+
+	s.statusBar = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.StatusFg)).
+		Background(lipgloss.Color(t.StatusBg)).
+		Padding(0, 1)
+
+	s.statusKey = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.StatusKey)).
+		Background(lipgloss.Color(t.StatusBg)).
+		Bold(true)
+
+	s.paneTitle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.Accent)).
+		Bold(true).
+		Padding(0, 1)
+
+	s.modal = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color(t.Accent)).
+		Padding(0, 1)
+
+	s.modalTitle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.StatusKey)).
+		Bold(true)
+
+	s.emptyState = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.Subtle)).
+		Italic(true)
+
+	s.previewTitle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(t.Accent)).
+		Bold(true).
+		Padding(0, 1)
+	return s
+}
@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+)
+
+// view is how the markdown pane shows the document.
+type view int
+
+const (
+	viewRendered view = iota // the rendered markdown
+	viewSource               // the markdown source, with line numbers
+	viewSplit                // rendered and source side by side
+)
+
+func (v view) String() string {
+	switch v {
+	case viewSource:
+		return "source"
+	case viewSplit:
+		return "side by side"
+	}
+	return "rendered"
+}
+
+// splitGap separates the columns of the side-by-side view.
+const splitGap = " │ "
+
+// splitColumns returns the widths of the rendered and source columns of the
+// side-by-side view, within the markdown pane's padding.
+func (m Model) splitColumns() (int, int) {
+	inner := m.leftWidth() - 2
+	left := (inner - len([]rune(splitGap))) / 2
+	return left, inner - len([]rune(splitGap)) - left
+}
+
+// cycleView switches to the next view, keeping the cursor and any selection
+// on the same source text.
+func (m Model) cycleView() Model {
+	cursorLine := m.sourceLine(m.cursor)
+	selecting := m.selectionStart >= 0
+	selStart, selEnd := m.renderedToSourceRange(m.selectionRange())
+	cursorFirst := m.cursor < m.selectionStart
+
+	cursorOffset, cursorWord := m.wordOffset(m.cursor, m.wordCol)
+	anchorOffset, anchorWord := m.wordOffset(m.wordAnchorLine, m.wordAnchorCol)
+
+	m.view = (m.view + 1) % 3
+	m.reRender()
+
+	switch {
+	case m.wordMode && cursorWord:
+		m.cursor, m.wordCol = m.locateOffset(cursorOffset)
+		m.wordCol = m.snapWordCol(m.cursor, m.wordCol)
+		if anchorWord {
+			m.wordAnchorLine, m.wordAnchorCol = m.locateOffset(anchorOffset)
+			m.wordAnchorCol = m.snapWordCol(m.wordAnchorLine, m.wordAnchorCol)
+		}
+	case selecting:
+		first, last := firstRow(m.doc, selStart), lastRow(m.doc, selEnd)
+		if cursorFirst {
+			m.cursor, m.selectionStart = first, last
+		} else {
+			m.selectionStart, m.cursor = first, last
+		}
+	default:
+		m.cursor = firstRow(m.doc, cursorLine)
+		if m.wordMode {
+			m.wordCol = m.snapWordCol(m.cursor, 0)
+		}
+	}
+	m.ensureCursorVisible()
+	return m
+}
+
+// sourceLine returns the source line a row of the markdown pane shows.
+func (m Model) sourceLine(row int) int {
+	if row < 0 || row >= len(m.doc.Mappings) {
+		return 1
+	}
+	return m.doc.Mappings[row].SourceStart
+}
+
+// wordOffset returns the source offset of the word at a column of a row.
+func (m Model) wordOffset(row, col int) (int, bool) {
+	if row < 0 || row >= len(m.doc.Mappings) {
+		return 0, false
+	}
+	start, _, ok := markdown.ColumnSpan(m.source, m.doc.Mappings[row], col, m.wordEnd(row, col))
+	return start, ok
+}
+
+// locateOffset returns the row and column showing a source offset, or the
+// first source text shown after it, such as the text inside markup.
+func (m Model) locateOffset(offset int) (int, int) {
+	bestRow, bestCol, best := m.cursor, 0, -1
+	for i, mapping := range m.doc.Mappings {
+		for _, seg := range mapping.Segments {
+			if seg.SourceEnd <= offset {
+				continue
+			}
+			at := max(seg.SourceStart, offset)
+			if best >= 0 && at >= best {
+				continue
+			}
+			col, _ := markdown.OffsetColumn(m.source, mapping, at)
+			bestRow, bestCol, best = i, col, at
+		}
+		if best == offset {
+			break
+		}
+	}
+	return bestRow, bestCol
+}
+
+// firstRow returns the first row of a rendered document showing source
+// line n, or the first row after it.
+func firstRow(doc *markdown.RenderedDocument, n int) int {
+	after := -1
+	for i, mapping := range doc.Mappings {
+		if mapping.SourceStart <= n && n <= mapping.SourceEnd {
+			return i
+		}
+		if after < 0 && mapping.SourceStart > n {
+			after = i
+		}
+	}
+	if after < 0 {
+		return max(len(doc.Mappings)-1, 0)
+	}
+	return after
+}
+
+// lastRow returns the last row of a rendered document showing source line
+// n, or the last row before it.
+func lastRow(doc *markdown.RenderedDocument, n int) int {
+	before := -1
+	for i := len(doc.Mappings) - 1; i >= 0; i-- {
+		mapping := doc.Mappings[i]
+		if mapping.SourceStart <= n && n <= mapping.SourceEnd {
+			return i
+		}
+		if before < 0 && mapping.SourceEnd < n {
+			before = i
+		}
+	}
+	return max(before, 0)
+}
+
+// sourceRow returns the first row of the source view showing source line n,
+// like firstRow, but by binary search, since the source view shows the lines
+// in order.
+func sourceRow(doc *markdown.RenderedDocument, n int) int {
+	i := sort.Search(len(doc.Mappings), func(i int) bool {
+		return doc.Mappings[i].SourceEnd >= n
+	})
+	return min(i, max(len(doc.Mappings)-1, 0))
+}
+
+// sourceColumn renders the rows of the source column of the side-by-side
+// view, scrolled so the source of the cursor's row lines up with it, and
+// highlighting the source lines a comment would target.
+func (m Model) sourceColumn(width, height int) []string {
+	doc := m.sideDoc
+	top := sourceRow(doc, m.sourceLine(m.cursor)) - (m.cursor - m.scrollOffset)
+	top = max(min(top, len(doc.Lines)-height), 0)
+
+	targetStart, targetEnd := m.targetLines()
+	rows := make([]string, 0, height)
+	for i := top; i < top+height && i < len(doc.Lines); i++ {
+		line := doc.Lines[i]
+		line = ansi.Truncate(line, width, "")
+		line += strings.Repeat(" ", max(width-markdown.VisibleLen(line), 0))
+
+		mapping := doc.Mappings[i]
+		if mapping.SourceStart >= targetStart && mapping.SourceEnd <= targetEnd {
+			if m.selectionStart >= 0 || m.wordMode {
+				line = m.styles.selectedLine.Render(line)
+			} else if m.focusPane == paneMarkdown {
+				line = m.styles.cursorLine.Render(line)
+			}
+		}
+		rows = append(rows, line)
+	}
+	for len(rows) < height {
+		rows = append(rows, strings.Repeat(" ", width))
+	}
+	return rows
+}
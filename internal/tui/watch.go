@@ -0,0 +1,209 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+	"github.com/paulbuckley/mdmu/internal/store"
+)
+
+// pollInterval is how often the document and the comment file are checked
+// for changes made elsewhere: the document by an editor or an agent, and
+// comments by other sessions, such as mdmu serve or mdmu mcp.
+const pollInterval = time.Second
+
+type fileCheckMsg struct{}
+
+func checkFilesLater() tea.Cmd {
+	return tea.Tick(pollInterval, func(time.Time) tea.Msg {
+		return fileCheckMsg{}
+	})
+}
+
+// modTime returns the modification time of path, or the zero time when it
+// can't be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadStore picks up comments written to the comment file by another
+// session since it was last read.
+func (m Model) reloadStore() Model {
+	mtime := modTime(m.opts.StorePath)
+	if mtime.Equal(m.storeModTime) {
+		return m
+	}
+	cf, err := store.Load(m.opts.StorePath)
+	if err != nil {
+		m.statusMessage = "✗ Failed to load comments: " + err.Error()
+		return m
+	}
+	m.storeModTime = mtime
+	*m.commentFile = *cf
+	if n := len(m.listedComments()); m.commentCursor >= n {
+		m.commentCursor = max(n-1, 0)
+	}
+	return m
+}
+
+// reloadDocument picks up edits saved to the document since it was last
+// read. Only the blocks the edits changed are rendered again, and comments
+// and the cursor follow the lines they were on. The reload waits until no
+// comment is being typed and nothing else is open or selected.
+func (m Model) reloadDocument() Model {
+	if m.mode != modeNormal || m.selectionStart >= 0 || m.wordAnchorLine >= 0 {
+		return m
+	}
+	mtime := modTime(m.opts.Path)
+	if mtime.Equal(m.docModTime) {
+		return m
+	}
+	source, err := os.ReadFile(m.opts.Path)
+	if err != nil {
+		m.statusMessage = "✗ Failed to reload " + m.filename + ": " + err.Error()
+		return m
+	}
+	m.docModTime = mtime
+	if bytes.Equal(source, m.source) {
+		return m
+	}
+
+	edit := newLineShift(m.source, source)
+	line := edit.apply(m.sourceLine(m.cursor))
+	m.source = source
+	if m.parsed != nil {
+		m.parsed.Update(source)
+	}
+	m.headings = nil
+	if len(m.filter.sections) > 0 {
+		m.headings = markdown.Headings(source)
+	}
+	m.folds = nil
+
+	moved, stale := reanchorComments(m.commentFile, source, edit)
+	if moved {
+		m.updateComments(func(cf *store.CommentFile) {
+			reanchorComments(cf, source, edit)
+		})
+	}
+	m.statusMessage = "Reloaded " + m.filename
+	if stale > 0 {
+		noun := "comments"
+		if stale == 1 {
+			noun = "comment"
+		}
+		m.statusMessage += fmt.Sprintf(": the text of %d %s changed, so check the lines they point at", stale, noun)
+	}
+	if m.width > 0 {
+		m.reRender()
+		m = m.moveToLine(line)
+	}
+	return m
+}
+
+// lineShift describes how an edit moved the lines of a document: lines up
+// to the first changed one stay, and those after move by the change in the
+// number of lines.
+type lineShift struct {
+	unchanged int // lines before the first changed one
+	delta     int
+}
+
+func newLineShift(old, new []byte) lineShift {
+	n := 0
+	for n < len(old) && n < len(new) && old[n] == new[n] {
+		n++
+	}
+	return lineShift{
+		unchanged: bytes.Count(old[:n], []byte("\n")),
+		delta:     bytes.Count(new, []byte("\n")) - bytes.Count(old, []byte("\n")),
+	}
+}
+
+// apply returns where a line is expected to be after the edit.
+func (s lineShift) apply(line int) int {
+	if line <= s.unchanged {
+		return line
+	}
+	return max(line+s.delta, s.unchanged+1)
+}
+
+// reanchorComments moves each comment whose text is no longer on its lines
+// to where the text now is, and reports whether any moved and how many
+// comments' text can't be found.
+func reanchorComments(cf *store.CommentFile, source []byte, edit lineShift) (moved bool, stale int) {
+	lineStarts := []int{0}
+	for i, b := range source {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	for i, c := range cf.Comments {
+		r, ok := reanchor(c, source, lineStarts, edit.apply(c.SourceStart))
+		switch {
+		case !ok:
+			stale++
+		case r.SourceStart != c.SourceStart || r.SourceEnd != c.SourceEnd || r.StartCol != c.StartCol || r.EndCol != c.EndCol:
+			cf.Comments[i] = r
+			moved = true
+		}
+	}
+	return moved, stale
+}
+
+// reanchor moves a comment to the occurrence of its text nearest the line it
+// is expected on, reporting false when the text doesn't occur. Comments on
+// whole lines only match whole lines.
+func reanchor(c store.Comment, source []byte, lineStarts []int, expected int) (store.Comment, bool) {
+	text := []byte(c.SelectedText)
+	if len(text) == 0 {
+		return c, true
+	}
+	lineAt := func(offset int) int {
+		return sort.Search(len(lineStarts), func(i int) bool { return lineStarts[i] > offset })
+	}
+
+	best, found := c, false
+	for from := 0; from < len(source); {
+		i := bytes.Index(source[from:], text)
+		if i < 0 {
+			break
+		}
+		start, end := from+i, from+i+len(text)
+		from = start + 1
+		line := lineAt(start)
+		if c.StartCol == 0 && (lineStarts[line-1] != start || end < len(source) && source[end] != '\n') {
+			continue
+		}
+
+		r := c
+		r.SourceStart = line
+		if c.StartCol == 0 {
+			r.SourceEnd = line + c.SourceEnd - c.SourceStart
+		} else {
+			r.SourceEnd = lineAt(end - 1)
+			r.StartCol = start - lineStarts[line-1] + 1
+			r.EndCol = end - lineStarts[r.SourceEnd-1]
+		}
+		if !found || abs(line-expected) < abs(best.SourceStart-expected) {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
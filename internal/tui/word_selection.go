@@ -0,0 +1,250 @@
+package tui
+
+import (
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/mattn/go-runewidth"
+	"github.com/paulbuckley/mdmu/internal/markdown"
+)
+
+// colSpan is a range of visible columns [start, end) in a rendered line.
+type colSpan struct {
+	start, end int
+}
+
+// lineWords returns the words of a rendered line that were rendered from
+// source text, skipping decoration such as bullets and heading markers.
+func lineWords(line string, segs []markdown.Segment) []colSpan {
+	var words []colSpan
+	col := 0
+	start := -1
+	for _, r := range ansi.Strip(line) {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, colSpan{start, col})
+				start = -1
+			}
+		} else if start < 0 {
+			start = col
+		}
+		col += runewidth.RuneWidth(r)
+	}
+	if start >= 0 {
+		words = append(words, colSpan{start, col})
+	}
+
+	var sourced []colSpan
+	for _, w := range words {
+		for _, seg := range segs {
+			if seg.Col < w.end && seg.Col+seg.Width > w.start {
+				sourced = append(sourced, w)
+				break
+			}
+		}
+	}
+	return sourced
+}
+
+func (m Model) wordsAt(line int) []colSpan {
+	if line < 0 || line >= len(m.doc.Lines) {
+		return nil
+	}
+	return lineWords(m.doc.Lines[line], m.doc.Mappings[line].Segments)
+}
+
+// wordIndex returns the index of the word containing col, or of the nearest
+// word before it. It returns -1 for lines without words.
+func wordIndex(words []colSpan, col int) int {
+	idx := -1
+	for i, w := range words {
+		if w.start <= col {
+			idx = i
+		}
+	}
+	if idx < 0 && len(words) > 0 {
+		idx = 0
+	}
+	return idx
+}
+
+// snapWordCol returns the start column of the word nearest col on a line.
+func (m Model) snapWordCol(line, col int) int {
+	words := m.wordsAt(line)
+	idx := wordIndex(words, col)
+	if idx < 0 {
+		return 0
+	}
+	return words[idx].start
+}
+
+// wordEnd returns the end column of the word starting at or before col.
+func (m Model) wordEnd(line, col int) int {
+	words := m.wordsAt(line)
+	idx := wordIndex(words, col)
+	if idx < 0 {
+		return col
+	}
+	return words[idx].end
+}
+
+func (m Model) handleWordKeys(k keySeq) (Model, tea.Cmd, bool) {
+	switch {
+	case key.Matches(k, m.keys.WordMode):
+		m.wordMode = false
+		m.wordAnchorLine = -1
+		return m, nil, true
+
+	case key.Matches(k, m.keys.Cancel):
+		if m.wordAnchorLine >= 0 {
+			m.wordAnchorLine = -1
+		} else {
+			m.wordMode = false
+		}
+		return m, nil, true
+
+	case key.Matches(k, m.keys.Comment):
+		m.mode = modeCommenting
+		m.textarea = newCommentTextarea(m.opts.CommentHeight)
+		m.textarea.SetWidth(m.width - 6)
+		return m, m.textarea.Focus(), true
+	}
+
+	motions := []struct {
+		move, extend key.Binding
+		apply        func(*Model)
+	}{
+		{m.keys.WordLeft, m.keys.SelectWordLeft, func(m *Model) { m.moveWord(-1) }},
+		{m.keys.WordRight, m.keys.SelectWordRight, func(m *Model) { m.moveWord(1) }},
+		{m.keys.Up, m.keys.SelectUp, func(m *Model) { m.moveWordLine(-1) }},
+		{m.keys.Down, m.keys.SelectDown, func(m *Model) { m.moveWordLine(1) }},
+	}
+	for _, motion := range motions {
+		switch {
+		case key.Matches(k, motion.extend):
+			if m.wordAnchorLine < 0 {
+				m.wordAnchorLine = m.cursor
+				m.wordAnchorCol = m.wordCol
+			}
+		case key.Matches(k, motion.move):
+			m.wordAnchorLine = -1
+		default:
+			continue
+		}
+		motion.apply(&m)
+		m.ensureCursorVisible()
+		return m, nil, true
+	}
+
+	return m, nil, false
+}
+
+// moveWord moves the word cursor by one word, continuing onto neighbouring
+// lines at either end of the current one.
+func (m *Model) moveWord(dir int) {
+	words := m.wordsAt(m.cursor)
+	idx := wordIndex(words, m.wordCol)
+	if next := idx + dir; idx >= 0 && next >= 0 && next < len(words) {
+		m.wordCol = words[next].start
+		return
+	}
+
+	for line := m.cursor + dir; line >= 0 && line < len(m.doc.Lines); line += dir {
+		words := m.wordsAt(line)
+		if len(words) == 0 {
+			continue
+		}
+		m.cursor = line
+		if dir > 0 {
+			m.wordCol = words[0].start
+		} else {
+			m.wordCol = words[len(words)-1].start
+		}
+		return
+	}
+}
+
+// moveWordLine moves the cursor one line, keeping the word cursor as close
+// to its column as the new line allows.
+func (m *Model) moveWordLine(dir int) {
+	line := m.cursor + dir
+	if line < 0 || line >= len(m.doc.Lines) {
+		return
+	}
+	m.cursor = line
+	m.wordCol = m.snapWordCol(line, m.wordCol)
+}
+
+// wordSelection returns the ordered bounds of the word selection: from the
+// start of the first selected word to the end of the last one.
+func (m Model) wordSelection() (startLine, startCol, endLine, endCol int) {
+	startLine, startCol = m.cursor, m.wordCol
+	endLine, endCol = m.cursor, m.wordCol
+	if m.wordAnchorLine >= 0 {
+		aLine, aCol := m.wordAnchorLine, m.wordAnchorCol
+		if aLine < startLine || (aLine == startLine && aCol < startCol) {
+			startLine, startCol = aLine, aCol
+		} else {
+			endLine, endCol = aLine, aCol
+		}
+	}
+	return startLine, startCol, endLine, m.wordEnd(endLine, endCol)
+}
+
+// wordHighlight returns the columns of a rendered line covered by the word
+// selection, or by the word cursor when nothing is selected.
+func (m Model) wordHighlight(line int) (int, int, bool) {
+	if !m.wordMode {
+		return 0, 0, false
+	}
+	startLine, startCol, endLine, endCol := m.wordSelection()
+	if line < startLine || line > endLine {
+		return 0, 0, false
+	}
+	from, to := 0, markdown.VisibleLen(m.doc.Lines[line])
+	if line == startLine {
+		from = startCol
+	}
+	if line == endLine {
+		to = endCol
+	}
+	return from, to, from < to
+}
+
+// wordSourceSpan maps the word selection to a source byte range.
+func (m Model) wordSourceSpan() (int, int, bool) {
+	startLine, startCol, endLine, endCol := m.wordSelection()
+	start, end, found := 0, 0, false
+	for line := startLine; line <= endLine && line < len(m.doc.Mappings); line++ {
+		from, to := 0, markdown.VisibleLen(m.doc.Lines[line])
+		if line == startLine {
+			from = startCol
+		}
+		if line == endLine {
+			to = endCol
+		}
+		s, e, ok := markdown.ColumnSpan(m.source, m.doc.Mappings[line], from, to)
+		if !ok {
+			continue
+		}
+		if !found || s < start {
+			start = s
+		}
+		if !found || e > end {
+			end = e
+		}
+		found = true
+	}
+	return start, end, found
+}
+
+// highlightColumns renders the visible columns [from, to) of a line with the
+// selection style, keeping the line's own styling elsewhere.
+func (s styles) highlightColumns(line string, from, to int) string {
+	width := markdown.VisibleLen(line)
+	return ansi.Cut(line, 0, from) + "\033[0m" +
+		s.selectedLine.Render(ansi.Strip(ansi.Cut(line, from, to))) +
+		ansi.Cut(line, to, width)
+}
@@ -0,0 +1,33 @@
+// Package xdg locates mdmu's per-user directories following the XDG base
+// directory conventions.
+package xdg
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDir returns the directory holding mdmu's user configuration:
+// $XDG_CONFIG_HOME/mdmu, or ~/.config/mdmu when that is unset.
+func ConfigDir() string {
+	return filepath.Join(baseDir("XDG_CONFIG_HOME", ".config"), "mdmu")
+}
+
+// StateDir returns the directory holding mdmu's persistent state:
+// $XDG_STATE_HOME/mdmu, or ~/.local/state/mdmu when that is unset.
+func StateDir() string {
+	return filepath.Join(baseDir("XDG_STATE_HOME", filepath.Join(".local", "state")), "mdmu")
+}
+
+// baseDir returns the value of an XDG variable, falling back to a directory
+// under the user's home.
+func baseDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fallback
+	}
+	return filepath.Join(home, fallback)
+}
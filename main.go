@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 
 	"github.com/paulbuckley/mdmu/cmd"
@@ -11,6 +13,11 @@ var version = "dev"
 func main() {
 	cmd.SetVersion(version)
 	if err := cmd.Execute(); err != nil {
-		os.Exit(1)
+		var exit *cmd.ExitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.Code)
+		}
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(cmd.ExitFailure)
 	}
 }